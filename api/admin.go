@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/TheChosenGay/coffee/service"
+)
+
+type AdminServerOpts struct {
+	ListenAddr string
+	// Tokens maps the bearer tokens of the operators to their names, every request
+	// must carry one of them.
+	Tokens map[string]string
+}
+
+// AdminServer serves the admin api to the operators on its own listener, so that it can
+// be kept off the public network. Its routes are registered under /v1 like the json api.
+type AdminServer struct {
+	opts       AdminServerOpts
+	handlers   []JsonServerHandler
+	router     *Router
+	httpServer *http.Server
+	listening  atomic.Bool
+}
+
+func NewAdminServer(opts AdminServerOpts) *AdminServer {
+	return &AdminServer{
+		opts:       opts,
+		router:     NewRouter(),
+		httpServer: &http.Server{Addr: opts.ListenAddr},
+	}
+}
+
+// RegisterHandler registers the routes of handler, it must be called before Run.
+func (s *AdminServer) RegisterHandler(handler JsonServerHandler) {
+	logger.Infof("register admin service: %s", reflect.TypeOf(handler).Elem().Name())
+	s.handlers = append(s.handlers, handler)
+}
+
+func (s *AdminServer) Run() error {
+	group := s.router.Group(V1.Prefix())
+	for _, handler := range s.handlers {
+		handler.MakeJsonServiceHandler(group)
+	}
+	spec, err := OpenAPI(V1, group.Routes())
+	if err != nil {
+		return err
+	}
+	group.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+
+	s.httpServer.Handler = withTracing(withRequestId(withMetrics(withAdminAuth(s.opts.Tokens, s.router))))
+	lis, err := net.Listen("tcp", s.opts.ListenAddr)
+	if err != nil {
+		return err
+	}
+	s.listening.Store(true)
+	defer s.listening.Store(false)
+	logger.Infof("starting admin server on %s", s.opts.ListenAddr)
+	if err := s.httpServer.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Ready is a health check of the listener of the server.
+func (s *AdminServer) Ready(ctx context.Context) error {
+	if !s.listening.Load() {
+		return errNotListening
+	}
+	return nil
+}
+
+// Shutdown stops accepting new requests and waits for in-flight requests until ctx is done.
+func (s *AdminServer) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+// withAdminAuth authenticates the operators by the "Authorization: Bearer <token>" header,
+// the operator is the actor of the request, e.g. "admin:alice".
+func withAdminAuth(tokens map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			WriteError(w, r, service.Unauthenticated("missing bearer token"))
+			return
+		}
+		for t, name := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				ctx := service.WithActor(r.Context(), service.Actor("admin:"+name))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		WriteError(w, r, service.Unauthenticated("invalid bearer token"))
+	})
+}
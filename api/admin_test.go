@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/service"
+)
+
+func TestAdminAuth(t *testing.T) {
+	var actor service.Actor
+	handler := withAdminAuth(map[string]string{"secret": "alice"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = service.ActorFrom(r.Context())
+	}))
+
+	for header, code := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Basic secret":  http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/online/users", nil)
+		r.Header.Set("Authorization", header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != code {
+			t.Fatalf("authorization %q: expected %d, got %d", header, code, rec.Code)
+		}
+	}
+	if actor != "admin:alice" {
+		t.Fatalf("unexpected actor: %s", actor)
+	}
+}
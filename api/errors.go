@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"github.com/TheChosenGay/coffee/service"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ErrorResponse is the body of every failed json request.
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestId string `json:"request_id,omitempty"`
+}
+
+var httpStatuses = map[service.ErrorKind]int{
+	service.KindInternal:          http.StatusInternalServerError,
+	service.KindNotFound:          http.StatusNotFound,
+	service.KindConflict:          http.StatusConflict,
+	service.KindForbidden:         http.StatusForbidden,
+	service.KindInvalidArgument:   http.StatusBadRequest,
+	service.KindResourceExhausted: http.StatusTooManyRequests,
+	service.KindUnauthenticated:   http.StatusUnauthorized,
+}
+
+var grpcCodes = map[service.ErrorKind]codes.Code{
+	service.KindInternal:          codes.Internal,
+	service.KindNotFound:          codes.NotFound,
+	service.KindConflict:          codes.AlreadyExists,
+	service.KindForbidden:         codes.PermissionDenied,
+	service.KindInvalidArgument:   codes.InvalidArgument,
+	service.KindResourceExhausted: codes.ResourceExhausted,
+	service.KindUnauthenticated:   codes.Unauthenticated,
+}
+
+// WriteError writes err as an ErrorResponse with the http status of its kind.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	kind := service.KindOf(err)
+	if kind == service.KindInternal {
+		logger.Ctx(r.Context()).WithError(err).Errorf("%s %s failed", r.Method, r.URL.Path)
+	}
+	if retryAfter, ok := retryAfterOf(err); ok {
+		setRetryAfter(w, retryAfter)
+	}
+	writeErrorResponse(w, r, httpStatuses[kind], kind.String(), service.MessageOf(err))
+}
+
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	WriteToJson(w, status, ErrorResponse{
+		Code:      code,
+		Message:   message,
+		RequestId: string(logging.RequestIdFrom(r.Context())),
+	})
+}
+
+// GrpcError converts err to a grpc status error with the code of its kind.
+func GrpcError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	kind := service.KindOf(err)
+	if kind == service.KindInternal {
+		logger.Ctx(ctx).WithError(err).Error("rpc failed")
+	}
+	st := status.New(grpcCodes[kind], service.MessageOf(err))
+	if retryAfter, ok := retryAfterOf(err); ok {
+		if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounded up so that
+// clients do not come back too early.
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+}
+
+// retryAfterOf returns when a request failed by a rate limit may be retried.
+func retryAfterOf(err error) (time.Duration, bool) {
+	var exceeded *ratelimit.ExceededError
+	if errors.As(err, &exceeded) {
+		return exceeded.RetryAfter, true
+	}
+	return 0, false
+}
+
+func errorUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	return resp, GrpcError(ctx, err)
+}
+
+func errorStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return GrpcError(ss.Context(), handler(srv, ss))
+}
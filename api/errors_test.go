@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/service/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorMapping(t *testing.T) {
+	for _, tc := range []struct {
+		err     error
+		status  int
+		code    codes.Code
+		message string
+	}{
+		{service.NotFound("coffee %d not found", 4), http.StatusNotFound, codes.NotFound, "coffee 4 not found"},
+		{fmt.Errorf("get room: %w", store.ErrNotFound), http.StatusNotFound, codes.NotFound, "record not found"},
+		{service.Conflict("room 1 is full"), http.StatusConflict, codes.AlreadyExists, "room 1 is full"},
+		{service.Forbidden("room 1 is banned"), http.StatusForbidden, codes.PermissionDenied, "room 1 is banned"},
+		{service.InvalidArgument("invalid id"), http.StatusBadRequest, codes.InvalidArgument, "invalid id"},
+		{service.Unauthenticated("missing bearer token"), http.StatusUnauthorized, codes.Unauthenticated, "missing bearer token"},
+		{fmt.Errorf("dial tcp: connection refused"), http.StatusInternalServerError, codes.Internal, "internal error"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(logging.WithRequestId(r.Context(), "abc"))
+		rec := httptest.NewRecorder()
+		WriteError(rec, r, tc.err)
+
+		var resp ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode error response: %v", err)
+		}
+		if rec.Code != tc.status || resp.Message != tc.message || resp.RequestId != "abc" {
+			t.Fatalf("%v: unexpected response %d %+v", tc.err, rec.Code, resp)
+		}
+
+		st := status.Convert(GrpcError(context.Background(), tc.err))
+		if st.Code() != tc.code || st.Message() != tc.message {
+			t.Fatalf("%v: unexpected status %v", tc.err, st)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// GatewayHandler is implemented by the grpc handlers whose service has http annotations,
+// the gateway serves these as json routes by calling the grpc server.
+type GatewayHandler interface {
+	RegisterGatewayHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+}
+
+// Gateway translates json requests to calls of the grpc server, so requests go through
+// the same interceptors as grpc clients. The authorization header is forwarded, json
+// clients authenticate with the same tokens.
+type Gateway struct {
+	mux  *runtime.ServeMux
+	conn *grpc.ClientConn
+}
+
+// NewGateway returns a gateway for the handlers of s implementing GatewayHandler.
+func (s *GrpcServer) NewGateway(ctx context.Context) (*Gateway, error) {
+	creds := insecure.NewCredentials()
+	if s.tlsConfig != nil {
+		creds = credentials.NewTLS(loopbackTLS(s.tlsConfig))
+	}
+	conn, err := grpc.NewClient(dialAddr(s.listenAddr),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	mux := runtime.NewServeMux(
+		// keep the field names of the json api
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithIncomingHeaderMatcher(gatewayHeaderMatcher),
+		runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+			return metadata.Pairs(strings.ToLower(logging.RequestIdHeader), string(logging.RequestIdFrom(r.Context())))
+		}),
+		runtime.WithErrorHandler(gatewayErrorHandler),
+		runtime.WithRoutingErrorHandler(gatewayRoutingErrorHandler),
+	)
+	for name, handler := range s.handlers {
+		if gh, ok := handler.(GatewayHandler); ok {
+			if err := gh.RegisterGatewayHandler(ctx, mux, conn); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			logger.Infof("serve grpc service %s through gateway", name)
+		}
+	}
+	return &Gateway{mux: mux, conn: conn}, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	announceLegacyGateway(w, r)
+	g.mux.ServeHTTP(w, r)
+}
+
+func (g *Gateway) Close() error {
+	return g.conn.Close()
+}
+
+// gatewayHeaderMatcher forwards the headers of the clients like runtime.DefaultHeaderMatcher,
+// but a Grpc-Metadata-X-Forwarded-For, the gateway sets the x-forwarded-for itself.
+func gatewayHeaderMatcher(key string) (string, bool) {
+	name, ok := runtime.DefaultHeaderMatcher(key)
+	if ok && strings.EqualFold(name, "x-forwarded-for") {
+		return "", false
+	}
+	return name, ok
+}
+
+// gatewayErrorHandler writes grpc errors as ErrorResponses like the json handlers do.
+func gatewayErrorHandler(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	// routing errors of the gateway come with their own http status
+	httpStatus := 0
+	var statusErr *runtime.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		err, httpStatus = statusErr.Err, statusErr.HTTPStatus
+	}
+	st := status.Convert(err)
+	if httpStatus == 0 {
+		httpStatus = runtime.HTTPStatusFromCode(st.Code())
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			setRetryAfter(w, info.RetryDelay.AsDuration())
+		}
+	}
+	code := codeName(st.Code())
+	for kind, c := range grpcCodes {
+		if c == st.Code() {
+			code = kind.String()
+		}
+	}
+	writeErrorResponse(w, r, httpStatus, code, st.Message())
+}
+
+// gatewayRoutingErrorHandler answers unknown paths and methods like the router does.
+func gatewayRoutingErrorHandler(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, httpStatus int) {
+	switch httpStatus {
+	case http.StatusMethodNotAllowed:
+		writeErrorResponse(w, r, httpStatus, "method_not_allowed", fmt.Sprintf("method %s is not allowed", r.Method))
+	case http.StatusNotFound:
+		WriteError(w, r, service.NotFound("no route for %s", r.URL.Path))
+	default:
+		writeErrorResponse(w, r, httpStatus, service.KindInvalidArgument.String(), http.StatusText(httpStatus))
+	}
+}
+
+// codeName returns the snake case name of c, e.g. resource_exhausted.
+func codeName(c codes.Code) string {
+	var b strings.Builder
+	for i, r := range c.String() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// dialAddr returns an address to dial the listen address addr, e.g. "localhost:8081" for ":8081".
+func dialAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/proto/coffee_service"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+)
+
+// peerIpHandler records the ip its calls are attributed to.
+type peerIpHandler struct {
+	coffee_service.UnimplementedCoffeeServiceServer
+	ips chan string
+}
+
+func (h *peerIpHandler) RegisterGrpcService(server *grpc.Server) {
+	coffee_service.RegisterCoffeeServiceServer(server, h)
+}
+
+func (h *peerIpHandler) RegisterGatewayHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return coffee_service.RegisterCoffeeServiceHandler(ctx, mux, conn)
+}
+
+func (h *peerIpHandler) ListCoffees(ctx context.Context, req *coffee_service.ListCoffeesRequest) (*coffee_service.CoffeesResponse, error) {
+	h.ips <- peerIp(ctx)
+	return &coffee_service.CoffeesResponse{}, nil
+}
+
+func TestGatewayForwardedFor(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a port: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	handler := &peerIpHandler{ips: make(chan string, 1)}
+	server := NewGrpcServer(GrpcServerOpts{ListenAddr: addr})
+	if err := server.RegisterHandler("coffee", handler); err != nil {
+		t.Fatalf("failed to register handler: %v", err)
+	}
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	for server.Ready(context.Background()) != nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	gateway, err := server.NewGateway(context.Background())
+	if err != nil {
+		t.Fatalf("failed to create gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	for name, header := range map[string]string{
+		"metadata":        "Grpc-Metadata-X-Forwarded-For",
+		"x-forwarded-for": "X-Forwarded-For",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/coffees", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		req.Header.Set(header, "1.2.3.4")
+		rec := httptest.NewRecorder()
+		gateway.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", name, rec.Code, rec.Body)
+		}
+		if ip := <-handler.ips; ip != "203.0.113.7" {
+			t.Fatalf("%s: call attributed to %s, want the address of the client", name, ip)
+		}
+	}
+}
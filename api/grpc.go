@@ -1,30 +1,110 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
-	"log"
 	"net"
 	"reflect"
+	"sync/atomic"
 
+	"github.com/TheChosenGay/coffee/internal/health"
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 type GrpcServerHandler interface {
 	RegisterGrpcService(server *grpc.Server)
 }
 
+type GrpcServerOpts struct {
+	ListenAddr string
+	// TLS is nil for plaintext connections
+	TLS *tls.Config
+	// Tokens maps the bearer tokens accepted from clients to the client names,
+	// authentication is disabled when it is empty.
+	Tokens map[string]string
+	// RateLimit limits the calls per client, nil disables the limit.
+	RateLimit       ratelimit.Limiter
+	Keepalive       keepalive.ServerParameters
+	KeepalivePolicy keepalive.EnforcementPolicy
+	// Health is published by the grpc.health.v1 service, which is served without
+	// authentication. Without it the health service is not registered.
+	Health *health.Checker
+
+	// UnaryInterceptors and StreamInterceptors run after the built-in ones, right before the handler.
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+}
+
 type GrpcServer struct {
 	listenAddr string
+	tlsConfig  *tls.Config
 	grpcServer *grpc.Server
 	handlers   map[string]GrpcServerHandler
+	health     *grpcHealth
+	listening  atomic.Bool
 }
 
-func NewGrpcServer(listenAddr string) *GrpcServer {
-	return &GrpcServer{
-		listenAddr: listenAddr,
-		grpcServer: grpc.NewServer(),
+// NewGrpcServer creates a grpc server whose calls go through, in order: request id,
+// deprecation of legacy service names, access log, metrics, panic recovery, error mapping, authentication, rate limit and
+// validation of the requests.
+func NewGrpcServer(opts GrpcServerOpts) *GrpcServer {
+	unary := []grpc.UnaryServerInterceptor{
+		requestIdUnaryInterceptor,
+		deprecationUnaryInterceptor,
+		accessLogUnaryInterceptor,
+		metricsUnaryInterceptor,
+		recoveryUnaryInterceptor,
+		errorUnaryInterceptor,
+	}
+	stream := []grpc.StreamServerInterceptor{
+		requestIdStreamInterceptor,
+		accessLogStreamInterceptor,
+		metricsStreamInterceptor,
+		recoveryStreamInterceptor,
+		errorStreamInterceptor,
+	}
+	if len(opts.Tokens) > 0 {
+		auth := &tokenAuth{tokens: make(map[string]string, len(opts.Tokens))}
+		for token, name := range opts.Tokens {
+			auth.tokens[token] = name
+		}
+		unary = append(unary, auth.unary)
+		stream = append(stream, auth.stream)
+	}
+	if opts.RateLimit != nil {
+		limit := &clientRateLimit{limiter: opts.RateLimit}
+		unary = append(unary, limit.unary)
+		stream = append(stream, limit.stream)
+	}
+	unary = append(append(unary, validationUnaryInterceptor), opts.UnaryInterceptors...)
+	stream = append(append(stream, validationStreamInterceptor), opts.StreamInterceptors...)
+
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+		grpc.KeepaliveParams(opts.Keepalive),
+		grpc.KeepaliveEnforcementPolicy(opts.KeepalivePolicy),
+	}
+	if opts.TLS != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(opts.TLS)))
+	}
+	s := &GrpcServer{
+		listenAddr: opts.ListenAddr,
+		tlsConfig:  opts.TLS,
+		grpcServer: grpc.NewServer(serverOpts...),
 		handlers:   make(map[string]GrpcServerHandler),
 	}
+	if opts.Health != nil {
+		s.health = newGrpcHealth(opts.Health)
+	}
+	return s
 }
 
 func (s *GrpcServer) RegisterHandler(name string, handler GrpcServerHandler) error {
@@ -47,12 +127,51 @@ func (s *GrpcServer) RegisterHandlers([]GrpcServerHandler) error {
 func (s *GrpcServer) Run() error {
 	for name, handler := range s.handlers {
 		handler.RegisterGrpcService(s.grpcServer)
-		log.Printf("start service: %s", name)
+		logger.Infof("start service: %s", name)
 
 	}
+	if s.health != nil {
+		s.health.register(s.grpcServer)
+	}
+	// lets grpcurl and other tools list the services without their proto files
+	reflection.Register(s.grpcServer)
 	lis, err := net.Listen("tcp", s.listenAddr)
 	if err != nil {
 		return err
 	}
+	s.listening.Store(true)
+	defer s.listening.Store(false)
+	if s.health != nil {
+		go s.health.watch()
+	}
+	logger.Infof("starting grpc server on %s", s.listenAddr)
 	return s.grpcServer.Serve(lis)
 }
+
+// Ready is a health check of the listener of the server.
+func (s *GrpcServer) Ready(ctx context.Context) error {
+	if !s.listening.Load() {
+		return errNotListening
+	}
+	return nil
+}
+
+// Shutdown stops accepting new rpcs and waits for pending ones, when ctx is done
+// before that the remaining rpcs are cancelled.
+func (s *GrpcServer) Shutdown(ctx context.Context) error {
+	if s.health != nil {
+		s.health.shutdown()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
@@ -2,14 +2,13 @@ package grpc_handler
 
 import (
 	"context"
-	"math/rand/v2"
 
 	"github.com/TheChosenGay/coffee/api"
 	"github.com/TheChosenGay/coffee/proto/coffee_service"
 	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/types"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 type GrpcCoffeeServiceHandler struct {
@@ -23,56 +22,49 @@ func NewGrpcCoffeeServiceHandler(svc service.CoffeeService) api.GrpcServerHandle
 
 func (s *GrpcCoffeeServiceHandler) RegisterGrpcService(server *grpc.Server) {
 	coffee_service.RegisterCoffeeServiceServer(server, s)
+	// clients built before the proto package was versioned call CoffeeService
+	api.RegisterLegacyService(server, &coffee_service.CoffeeService_ServiceDesc, "CoffeeService", s)
+}
+
+func (s *GrpcCoffeeServiceHandler) RegisterGatewayHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return coffee_service.RegisterCoffeeServiceHandler(ctx, mux, conn)
 }
 
 func (s *GrpcCoffeeServiceHandler) ListCoffees(ctx context.Context, req *coffee_service.ListCoffeesRequest) (*coffee_service.CoffeesResponse, error) {
-	reqId := rand.Int64N(1000000)
-	ctx = context.WithValue(ctx, "requestId", reqId)
 	coffees, err := s.svc.ListCoffees(ctx)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to list coffees: %v", err)
+		return nil, err
 	}
 
-	proto_coffees := make([]*coffee_service.Coffee, len(coffees))
+	proto_coffees := make([]*coffee_service.Coffee, 0, len(coffees))
 	for _, coffee := range coffees {
-		proto_coffees = append(proto_coffees, &coffee_service.Coffee{
-			Id:           int32(coffee.Id),
-			Name:         coffee.Name,
-			CoverUrl:     coffee.CoverUrl,
-			Category:     coffee.Category,
-			ProdLocation: coffee.ProdLocation,
-		})
+		proto_coffees = append(proto_coffees, toProtoCoffee(coffee))
 	}
 	return &coffee_service.CoffeesResponse{Coffee: proto_coffees}, nil
-
 }
 
 func (s *GrpcCoffeeServiceHandler) GetCoffeeById(ctx context.Context, req *coffee_service.CoffeeByIdRequest) (*coffee_service.CoffeeResponse, error) {
-	reqId := rand.Int64N(1000000)
-	ctx = context.WithValue(ctx, "requestId", reqId)
-	coffee, err := s.svc.GetCoffeeById(ctx, int(req.Id))
+	coffee, err := s.svc.GetCoffeeById(ctx, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to get coffee by id: %v", err)
+		return nil, err
 	}
-	return &coffee_service.CoffeeResponse{Coffee: &coffee_service.Coffee{
-		Id:       int32(coffee.Id),
-		Name:     coffee.Name,
-		CoverUrl: coffee.CoverUrl,
-		Category: coffee.Category,
-	}}, nil
+	return &coffee_service.CoffeeResponse{Coffee: toProtoCoffee(coffee)}, nil
 }
 
 func (s *GrpcCoffeeServiceHandler) GetCoffeeByName(ctx context.Context, req *coffee_service.CoffeeByNameRequest) (*coffee_service.CoffeeResponse, error) {
-	reqId := rand.Int64N(1000000)
-	ctx = context.WithValue(ctx, "requestId", reqId)
 	coffee, err := s.svc.GetCoffeeByName(ctx, req.Name)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to get coffee by name: %v", err)
+		return nil, err
+	}
+	return &coffee_service.CoffeeResponse{Coffee: toProtoCoffee(coffee)}, nil
+}
+
+func toProtoCoffee(coffee types.Coffee) *coffee_service.Coffee {
+	return &coffee_service.Coffee{
+		Id:           coffee.Id,
+		Name:         coffee.Name,
+		CoverUrl:     coffee.CoverUrl,
+		Category:     coffee.Category,
+		ProdLocation: coffee.ProdLocation,
 	}
-	return &coffee_service.CoffeeResponse{Coffee: &coffee_service.Coffee{
-		Id:       int32(coffee.Id),
-		Name:     coffee.Name,
-		CoverUrl: coffee.CoverUrl,
-		Category: coffee.Category,
-	}}, nil
 }
@@ -0,0 +1,52 @@
+package grpc_handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/proto/coffee_service"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+type fakeCoffeeService struct {
+	service.CoffeeService
+	coffees []types.Coffee
+}
+
+func (s fakeCoffeeService) ListCoffees(ctx context.Context) ([]types.Coffee, error) {
+	return s.coffees, nil
+}
+
+func (s fakeCoffeeService) GetCoffeeById(ctx context.Context, id int64) (types.Coffee, error) {
+	return s.coffees[0], nil
+}
+
+func TestCoffeeConversion(t *testing.T) {
+	svc := fakeCoffeeService{coffees: []types.Coffee{
+		{Id: 1, Name: "Latte", ProdLocation: "Yunnan"},
+		{Id: 2, Name: "Mocha", ProdLocation: "Kenya"},
+	}}
+	handler := &GrpcCoffeeServiceHandler{svc: svc}
+
+	list, err := handler.ListCoffees(context.Background(), &coffee_service.ListCoffeesRequest{})
+	if err != nil {
+		t.Fatalf("failed to list coffees: %v", err)
+	}
+	if len(list.Coffee) != len(svc.coffees) {
+		t.Fatalf("expected %d coffees, got %d", len(svc.coffees), len(list.Coffee))
+	}
+	for i, coffee := range list.Coffee {
+		if coffee == nil || coffee.Id != svc.coffees[i].Id {
+			t.Fatalf("unexpected coffee %d: %v", i, coffee)
+		}
+	}
+
+	resp, err := handler.GetCoffeeById(context.Background(), &coffee_service.CoffeeByIdRequest{Id: 1})
+	if err != nil {
+		t.Fatalf("failed to get coffee: %v", err)
+	}
+	if resp.Coffee.ProdLocation != "Yunnan" {
+		t.Fatalf("prod location is not set: %v", resp.Coffee)
+	}
+}
@@ -0,0 +1,81 @@
+package grpc_handler
+
+import (
+	"context"
+
+	"github.com/TheChosenGay/coffee/api"
+	"github.com/TheChosenGay/coffee/proto/user_service"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/types"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+)
+
+type GrpcUserServiceHandler struct {
+	svc service.UserService
+	user_service.UnimplementedUserServiceServer
+}
+
+func NewGrpcUserServiceHandler(svc service.UserService) api.GrpcServerHandler {
+	return &GrpcUserServiceHandler{svc: svc}
+}
+
+func (s *GrpcUserServiceHandler) RegisterGrpcService(server *grpc.Server) {
+	user_service.RegisterUserServiceServer(server, s)
+}
+
+func (s *GrpcUserServiceHandler) RegisterGatewayHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return user_service.RegisterUserServiceHandler(ctx, mux, conn)
+}
+
+func (s *GrpcUserServiceHandler) GetUser(ctx context.Context, req *user_service.GetUserRequest) (*user_service.User, error) {
+	user, err := s.svc.GetUser(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *GrpcUserServiceHandler) UpdateProfile(ctx context.Context, req *user_service.UpdateProfileRequest) (*user_service.User, error) {
+	update := types.ProfileUpdate{
+		Nickname:  req.Nickname,
+		Birthday:  req.Birthday,
+		AvatarUrl: req.AvatarUrl,
+		Bio:       req.Bio,
+		Status:    req.Status,
+	}
+	if req.Sex != nil {
+		sex := types.Sex(*req.Sex)
+		update.Sex = &sex
+	}
+	if req.Age != nil {
+		age := int(*req.Age)
+		update.Age = &age
+	}
+	user, err := s.svc.UpdateProfile(ctx, req.UserId, update)
+	if err != nil {
+		return nil, err
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *GrpcUserServiceHandler) UploadAvatar(ctx context.Context, req *user_service.UploadAvatarRequest) (*user_service.User, error) {
+	user, err := s.svc.UploadAvatar(ctx, req.UserId, req.Data)
+	if err != nil {
+		return nil, err
+	}
+	return toProtoUser(user), nil
+}
+
+func toProtoUser(user types.User) *user_service.User {
+	return &user_service.User{
+		UserId:    user.UserId,
+		Nickname:  user.Nickname,
+		Sex:       int32(user.Sex),
+		Age:       int32(user.Age),
+		Birthday:  user.Birthday,
+		AvatarUrl: user.AvatarUrl,
+		Bio:       user.Bio,
+		Status:    user.Status,
+	}
+}
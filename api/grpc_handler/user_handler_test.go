@@ -0,0 +1,145 @@
+package grpc_handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/api"
+	"github.com/TheChosenGay/coffee/api/json_handler"
+	"github.com/TheChosenGay/coffee/proto/user_service"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/types"
+	"google.golang.org/protobuf/proto"
+)
+
+type fakeUserService struct {
+	service.UserService
+	user types.User
+}
+
+func (s *fakeUserService) GetUser(ctx context.Context, id int64) (types.User, error) {
+	return s.user, nil
+}
+
+func (s *fakeUserService) UploadAvatar(ctx context.Context, id int64, data []byte) (types.User, error) {
+	s.user.AvatarUrl = fmt.Sprintf("/v2/users/%d/avatar?v=%d", id, len(data))
+	return s.user, nil
+}
+
+func (s *fakeUserService) UpdateProfile(ctx context.Context, id int64, update types.ProfileUpdate) (types.User, error) {
+	if update.Nickname != nil {
+		s.user.Nickname = *update.Nickname
+	}
+	if update.Sex != nil {
+		s.user.Sex = *update.Sex
+	}
+	if update.Age != nil {
+		s.user.Age = *update.Age
+	}
+	if update.Bio != nil {
+		s.user.Bio = *update.Bio
+	}
+	return s.user, nil
+}
+
+func TestUpdateProfileConversion(t *testing.T) {
+	svc := &fakeUserService{user: types.User{UserId: 1, Nickname: "latte", Age: 20, Bio: "milk first", Status: "brewing"}}
+	handler := &GrpcUserServiceHandler{svc: svc}
+
+	// the fields which are not set are kept, an empty bio clears it
+	user, err := handler.UpdateProfile(context.Background(), &user_service.UpdateProfileRequest{
+		UserId: 1,
+		Sex:    proto.Int32(int32(types.Female)),
+		Age:    proto.Int32(30),
+		Bio:    proto.String(""),
+	})
+	if err != nil {
+		t.Fatalf("failed to update profile: %v", err)
+	}
+	want := &user_service.User{UserId: 1, Nickname: "latte", Sex: int32(types.Female), Age: 30, Status: "brewing"}
+	if !proto.Equal(user, want) {
+		t.Fatalf("expected %v, got %v", want, user)
+	}
+}
+
+// serveUser answers req with handler and returns the fields of the user of the response,
+// their values as their json text without quotes.
+func serveUser(t *testing.T, handler http.Handler, req *http.Request) map[string]string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s %s: unexpected status %d: %s", req.Method, req.URL, rec.Code, rec.Body)
+	}
+	var user map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatalf("%s %s: invalid user: %v", req.Method, req.URL, err)
+	}
+	fields := make(map[string]string, len(user))
+	for name, value := range user {
+		fields[name] = strings.Trim(string(value), `"`)
+	}
+	return fields
+}
+
+// TestUserGatewayMatchesJson checks that the gateway answers with the fields of the json
+// api v2, the gateway sends every int64 as a string where v2 only sends ids as strings.
+func TestUserGatewayMatchesJson(t *testing.T) {
+	svc := &fakeUserService{user: types.User{UserId: 1, Nickname: "latte", Age: 20, Birthday: 946684800, Bio: "milk first"}}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a port: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	server := api.NewGrpcServer(api.GrpcServerOpts{ListenAddr: addr})
+	if err := server.RegisterHandler("user", NewGrpcUserServiceHandler(svc)); err != nil {
+		t.Fatalf("failed to register handler: %v", err)
+	}
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	for server.Ready(context.Background()) != nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	gateway, err := server.NewGateway(context.Background())
+	if err != nil {
+		t.Fatalf("failed to create gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	router := api.NewRouter()
+	json_handler.NewJsonUserServiceHandlerV2(svc).MakeJsonServiceHandler(router.Group(api.V2.Prefix()))
+
+	avatar := []byte("\x89PNG\r\n\x1a\n")
+	for _, c := range []struct {
+		method, path, body, gatewayBody string
+	}{
+		{method: http.MethodGet, path: "/users/1"},
+		{method: http.MethodPatch, path: "/users/1", body: `{"age":31,"bio":"oat milk"}`},
+		{method: http.MethodPut, path: "/users/1/avatar", body: string(avatar), gatewayBody: `"` + base64.StdEncoding.EncodeToString(avatar) + `"`},
+	} {
+		if c.gatewayBody == "" {
+			c.gatewayBody = c.body
+		}
+		req := httptest.NewRequest(c.method, "/v2"+c.path, strings.NewReader(c.body))
+		if c.method == http.MethodPatch {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		want := serveUser(t, router, req)
+		got := serveUser(t, gateway, httptest.NewRequest(c.method, "/api/v1"+c.path, bytes.NewReader([]byte(c.gatewayBody))))
+		if !maps.Equal(got, want) {
+			t.Fatalf("%s %s: the gateway answered %v, v2 %v", c.method, c.path, got, want)
+		}
+	}
+}
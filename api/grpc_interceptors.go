@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type clientKey struct{}
+
+// ClientFrom returns the name of the authenticated client of a grpc call, it is empty
+// when authentication is disabled.
+func ClientFrom(ctx context.Context) string {
+	name, _ := ctx.Value(clientKey{}).(string)
+	return name
+}
+
+// tokenAuth authenticates calls by the "authorization: Bearer <token>" metadata.
+type tokenAuth struct {
+	// client name by token
+	tokens map[string]string
+}
+
+func (a *tokenAuth) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ctx, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, "invalid authorization scheme")
+	}
+	for t, name := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return context.WithValue(ctx, clientKey{}, name), nil
+		}
+	}
+	return ctx, status.Error(codes.Unauthenticated, "invalid bearer token")
+}
+
+func (a *tokenAuth) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isHealthMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *tokenAuth) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+}
+
+// clientRateLimit limits the calls per client, clients are identified by their name
+// when authenticated and by their ip otherwise.
+type clientRateLimit struct {
+	limiter ratelimit.Limiter
+}
+
+func (l *clientRateLimit) allow(ctx context.Context) error {
+	if err := l.limiter.Allow(ctx, clientKeyOf(ctx)); err != nil {
+		return service.Wrap(service.KindResourceExhausted, err, "rate limit exceeded")
+	}
+	return nil
+}
+
+func (l *clientRateLimit) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isHealthMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	if err := l.allow(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (l *clientRateLimit) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isHealthMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	if err := l.allow(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// clientKeyOf identifies the client of a call for rate limits and logs.
+func clientKeyOf(ctx context.Context) string {
+	if name := ClientFrom(ctx); name != "" {
+		return "client:" + name
+	}
+	return "ip:" + peerIp(ctx)
+}
+
+// peerIp returns the ip of the caller, calls from the gateway on the loopback
+// are attributed to the ip it forwards. The gateway appends the address it sees
+// to the x-forwarded-for of the client, only that last one is trusted. It is the
+// last value of the metadata too, after the ones forwarded from the headers.
+func peerIp(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.IsLoopback() {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if forwarded := md.Get("x-forwarded-for"); len(forwarded) > 0 {
+			ips := strings.Split(forwarded[len(forwarded)-1], ",")
+			return strings.TrimSpace(ips[len(ips)-1])
+		}
+	}
+	return ip
+}
+
+// recoveryUnaryInterceptor turns panics of handlers into internal errors.
+func recoveryUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ctx, info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoveryStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ss.Context(), info.FullMethod, r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recovered(ctx context.Context, method string, r any) error {
+	logger.Ctx(ctx).WithFields(logrus.Fields{
+		"method": method,
+		"panic":  r,
+		"stack":  string(debug.Stack()),
+	}).Error("grpc handler panicked")
+	return status.Error(codes.Internal, "internal error")
+}
+
+func accessLogUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logAccess(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func accessLogStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logAccess(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+func logAccess(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	entry := logger.Ctx(ctx).WithFields(logrus.Fields{
+		"method":     method,
+		"code":       code.String(),
+		"peer":       peerIp(ctx),
+		"elapsed_us": time.Since(start).Microseconds(),
+	})
+	if client := ClientFrom(ctx); client != "" {
+		entry = entry.WithField("client", client)
+	}
+	if code == codes.Internal || code == codes.Unknown {
+		entry.WithError(err).Error("grpc request")
+		return
+	}
+	entry.Info("grpc request")
+}
+
+// validator is implemented by request messages which check their fields.
+type validator interface {
+	Validate() error
+}
+
+func validationUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if v, ok := req.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return handler(ctx, req)
+}
+
+func validationStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &validatingStream{ServerStream: ss})
+}
+
+// validatingStream validates every message received from the client.
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if v, ok := m.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return nil
+}
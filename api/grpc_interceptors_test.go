@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var testInfo = &grpc.UnaryServerInfo{FullMethod: "/CoffeeService/GetCoffeeById"}
+
+func TestTokenAuth(t *testing.T) {
+	auth := &tokenAuth{tokens: map[string]string{"secret": "frontend"}}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return ClientFrom(ctx), nil
+	}
+	for header, code := range map[string]codes.Code{
+		"":              codes.Unauthenticated,
+		"Basic secret":  codes.Unauthenticated,
+		"Bearer wrong":  codes.Unauthenticated,
+		"Bearer secret": codes.OK,
+	} {
+		ctx := context.Background()
+		if header != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", header))
+		}
+		client, err := auth.unary(ctx, nil, testInfo, handler)
+		if status.Code(err) != code {
+			t.Fatalf("%q: expected %v, got %v", header, code, err)
+		}
+		if code == codes.OK && client != "frontend" {
+			t.Fatalf("unexpected client: %v", client)
+		}
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	limit := &clientRateLimit{limiter: ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 1, Burst: 2})}
+	handler := func(ctx context.Context, req any) (any, error) { return nil, nil }
+	peerCtx := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4000}})
+	}
+
+	for i := range 3 {
+		_, err := limit.unary(peerCtx("10.0.0.1"), nil, testInfo, handler)
+		if i < 2 && err != nil {
+			t.Fatalf("call %d rejected: %v", i, err)
+		}
+		if i == 2 && status.Code(GrpcError(context.Background(), err)) != codes.ResourceExhausted {
+			t.Fatalf("expected the burst to be exhausted, got %v", err)
+		}
+	}
+	if _, err := limit.unary(peerCtx("10.0.0.2"), nil, testInfo, handler); err != nil {
+		t.Fatalf("another client is limited: %v", err)
+	}
+}
+
+type testRequest struct{ valid bool }
+
+func (r testRequest) Validate() error {
+	if !r.valid {
+		return errors.New("invalid request")
+	}
+	return nil
+}
+
+func TestRecoveryAndValidation(t *testing.T) {
+	_, err := recoveryUnaryInterceptor(context.Background(), nil, testInfo, func(ctx context.Context, req any) (any, error) {
+		panic("boom")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected internal error, got %v", err)
+	}
+
+	handler := func(ctx context.Context, req any) (any, error) { return nil, nil }
+	if _, err := validationUnaryInterceptor(context.Background(), testRequest{}, testInfo, handler); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected invalid argument, got %v", err)
+	}
+	if _, err := validationUnaryInterceptor(context.Background(), testRequest{valid: true}, testInfo, handler); err != nil {
+		t.Fatalf("valid request rejected: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/health"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var errNotListening = errors.New("not listening")
+
+// grpcHealthInterval is how often the status of the grpc health service is refreshed.
+const grpcHealthInterval = 5 * time.Second
+
+// HealthResponse is the body of /healthz.
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// serveHealth serves the liveness probe /healthz, which passes as long as the process
+// serves requests, and the readiness probe /readyz, which runs the checks of checker
+// and fails with 503 when one of them fails.
+func serveHealth(router *Router, checker *health.Checker) {
+	router.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		WriteToJson(w, http.StatusOK, HealthResponse{Status: health.StatusOk})
+	})
+	router.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Check(r.Context())
+		status := http.StatusOK
+		if !report.Ready {
+			status = http.StatusServiceUnavailable
+			logger.Ctx(r.Context()).WithField("checks", report.Checks).Warn("not ready")
+		}
+		WriteToJson(w, status, report)
+	})
+}
+
+// grpcHealth publishes the readiness of checker as the status of the grpc.health.v1
+// service, for the server as a whole ("") and for every registered service.
+type grpcHealth struct {
+	server   *grpchealth.Server
+	checker  *health.Checker
+	services []string
+	stop     chan struct{}
+}
+
+func newGrpcHealth(checker *health.Checker) *grpcHealth {
+	return &grpcHealth{
+		server:  grpchealth.NewServer(),
+		checker: checker,
+		stop:    make(chan struct{}),
+	}
+}
+
+// register adds the health service to server, the services registered so far are reported.
+func (h *grpcHealth) register(server *grpc.Server) {
+	for name := range server.GetServiceInfo() {
+		h.services = append(h.services, name)
+	}
+	healthpb.RegisterHealthServer(server, h.server)
+}
+
+// watch refreshes the status until shutdown is called.
+func (h *grpcHealth) watch() {
+	ticker := time.NewTicker(grpcHealthInterval)
+	defer ticker.Stop()
+	for {
+		h.update()
+		select {
+		case <-ticker.C:
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+func (h *grpcHealth) update() {
+	report := h.checker.Check(context.Background())
+	status := healthpb.HealthCheckResponse_SERVING
+	if !report.Ready {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.server.SetServingStatus("", status)
+	for _, name := range h.services {
+		h.server.SetServingStatus(name, status)
+	}
+}
+
+// shutdown reports every service as not serving, later updates are ignored.
+func (h *grpcHealth) shutdown() {
+	close(h.stop)
+	h.server.Shutdown()
+}
+
+// isHealthMethod tells whether method belongs to the health service, which is served
+// without authentication and rate limit since probes cannot send tokens.
+func isHealthMethod(method string) bool {
+	return strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadyz(t *testing.T) {
+	checker := health.NewChecker(50 * time.Millisecond)
+	var dbErr error
+	checker.Add("database", func(ctx context.Context) error { return dbErr })
+	router := NewRouter()
+	serveHealth(router, checker)
+
+	ready := func() (int, health.Report) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var report health.Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("invalid report: %v", err)
+		}
+		return rec.Code, report
+	}
+
+	if code, report := ready(); code != http.StatusOK || report.Checks["database"] != health.StatusOk {
+		t.Fatalf("expected ready, got %d %v", code, report)
+	}
+	dbErr = errors.New("connection refused")
+	if code, report := ready(); code != http.StatusServiceUnavailable || report.Checks["database"] != "connection refused" {
+		t.Fatalf("expected not ready, got %d %v", code, report)
+	}
+	dbErr = nil
+	checker.Shutdown(context.Background())
+	if code, _ := ready(); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready on shutdown, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the process to be live, got %d", rec.Code)
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	checker := health.NewChecker(10 * time.Millisecond)
+	checker.Add("redis", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	if report := checker.Check(context.Background()); report.Ready {
+		t.Fatalf("expected a slow check to fail: %v", report)
+	}
+}
+
+func TestHealthSkipsAuth(t *testing.T) {
+	auth := &tokenAuth{tokens: map[string]string{"secret": "frontend"}}
+	handler := func(ctx context.Context, req any) (any, error) { return nil, nil }
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	if _, err := auth.unary(context.Background(), nil, info, handler); err != nil {
+		t.Fatalf("health check rejected: %v", err)
+	}
+	if _, err := auth.unary(context.Background(), nil, testInfo, handler); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected other methods to be authenticated, got %v", err)
+	}
+}
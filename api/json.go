@@ -1,43 +1,67 @@
 package api
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
 	"errors"
-	"log"
+	"net"
 	"net/http"
 	"reflect"
+	"sync/atomic"
 
+	"github.com/TheChosenGay/coffee/internal/health"
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"github.com/TheChosenGay/coffee/service"
 	"github.com/rs/cors"
 )
 
+var logger = logging.Package("api")
+
+//go:embed docs.html
+var docsPage []byte
+
 type JsonServerHandler interface {
-	MakeJsonServiceHandler()
+	// MakeJsonServiceHandler registers the routes of the handler on router.
+	MakeJsonServiceHandler(router *Router)
 }
 
 type JsonServer struct {
 	listenAddr string
-	handlers   map[string]JsonServerHandler
+	handlers   map[Version]map[string]JsonServerHandler
+	router     *Router
+	httpServer *http.Server
+	health     *health.Checker
+	rateLimit  RateLimitOpts
+	listening  atomic.Bool
 }
 
 func NewJsonServer(listenAddr string) *JsonServer {
 	return &JsonServer{
 		listenAddr: listenAddr,
-		handlers:   make(map[string]JsonServerHandler),
+		handlers:   make(map[Version]map[string]JsonServerHandler),
+		router:     NewRouter(),
+		httpServer: &http.Server{Addr: listenAddr},
 	}
 }
 
-func (s *JsonServer) RegisterHandler(name string, handler JsonServerHandler) error {
-	if _, ok := s.handlers[name]; ok {
+// RegisterHandler registers the routes of handler under the prefix of version, e.g. /v1.
+func (s *JsonServer) RegisterHandler(version Version, name string, handler JsonServerHandler) error {
+	if _, ok := s.handlers[version][name]; ok {
 		return errors.New("service already registered")
 	}
-	log.Printf("register service: %s", name)
-	s.handlers[name] = handler
+	logger.Infof("register service: %s %s", version, name)
+	if s.handlers[version] == nil {
+		s.handlers[version] = make(map[string]JsonServerHandler)
+	}
+	s.handlers[version][name] = handler
 	return nil
 }
 
-func (s *JsonServer) RegisterHandlers([]JsonServerHandler) error {
-	for _, handler := range s.handlers {
-		if err := s.RegisterHandler(reflect.TypeOf(handler).Elem().Name(), handler); err != nil {
+func (s *JsonServer) RegisterHandlers(version Version, handlers []JsonServerHandler) error {
+	for _, handler := range handlers {
+		if err := s.RegisterHandler(version, reflect.TypeOf(handler).Elem().Name(), handler); err != nil {
 			return err
 		}
 	}
@@ -45,22 +69,136 @@ func (s *JsonServer) RegisterHandlers([]JsonServerHandler) error {
 }
 
 func (s *JsonServer) Run() error {
-	for name, handler := range s.handlers {
-		handler.MakeJsonServiceHandler()
-		log.Printf("start service: %s", name)
+	if len(s.rateLimit.Routes) > 0 {
+		s.router.Use(limitRoutes(s.rateLimit.Routes))
+	}
+	for _, version := range Versions {
+		group := s.router.Group(version.Prefix())
+		for name, handler := range s.handlers[version] {
+			handler.MakeJsonServiceHandler(group)
+			logger.Infof("start service: %s %s", version, name)
+		}
+	}
+	// the clients deployed before the api was versioned call the v1 routes without prefix
+	legacy := s.router.Group("")
+	legacy.Deprecate(legacyDeprecation)
+	for _, handler := range s.handlers[V1] {
+		handler.MakeJsonServiceHandler(legacy)
 	}
+	s.checkRouteLimits()
+	s.router.Handle(http.MethodGet, "/metrics", metrics.Handler())
+	if s.health != nil {
+		serveHealth(s.router, s.health)
+	}
+	if err := s.serveOpenAPI(); err != nil {
+		return err
+	}
+
 	// allow cross-origin requests
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{logging.RequestIdHeader, "Retry-After", "Deprecation", "Sunset", "Link"},
 	})
-	handler := c.Handler(http.DefaultServeMux)
-	log.Println("Starting JSON server on port 8080")
-	return http.ListenAndServe(s.listenAddr, handler)
+	s.httpServer.Handler = withTracing(withRequestId(withClientActor(withMetrics(withRateLimit(s.rateLimit.IP, c.Handler(s.router))))))
+	lis, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		return err
+	}
+	s.listening.Store(true)
+	defer s.listening.Store(false)
+	logger.Infof("starting json server on %s", s.listenAddr)
+	if err := s.httpServer.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// RateLimit limits the requests of clients by opts, it must be called before Run.
+func (s *JsonServer) RateLimit(opts RateLimitOpts) {
+	s.rateLimit = opts
+}
+
+// checkRouteLimits warns about limits of routes which do not exist, they are likely typos.
+func (s *JsonServer) checkRouteLimits() {
+	routes := make(map[string]bool)
+	for _, route := range s.router.Routes() {
+		routes[route.Key()] = true
+	}
+	for route := range s.rateLimit.Routes {
+		if !routes[route] {
+			logger.Warnf("rate limit of unknown route %s", route)
+		}
+	}
+}
+
+// ServeHealth serves /healthz and /readyz by checker, it must be called before Run.
+func (s *JsonServer) ServeHealth(checker *health.Checker) {
+	s.health = checker
+}
+
+// Ready is a health check of the listener of the server.
+func (s *JsonServer) Ready(ctx context.Context) error {
+	if !s.listening.Load() {
+		return errNotListening
+	}
+	return nil
+}
+
+// Mount serves every request under prefix by handler, it must be called before Run.
+func (s *JsonServer) Mount(prefix string, handler http.Handler) {
+	s.router.Mount(prefix, handler)
+}
+
+// serveOpenAPI serves the OpenAPI document of the routes of every version, e.g. at
+// /v1/openapi.json, and a page to read it at /v1/docs.
+func (s *JsonServer) serveOpenAPI() error {
+	for _, version := range Versions {
+		group := s.router.Group(version.Prefix())
+		spec, err := OpenAPI(version, group.Routes())
+		if err != nil {
+			return err
+		}
+		group.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(spec)
+		})
+		group.Get("/docs", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(docsPage)
+		})
+	}
+	// the unversioned documents describe v1, which the unversioned routes are aliases of
+	for _, path := range []string{"/openapi.json", "/docs"} {
+		s.router.Get(path, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, V1.Prefix()+path, http.StatusFound)
+		})
+	}
+	return nil
+}
+
+// Shutdown stops accepting new requests and waits for in-flight requests until ctx is done.
+func (s *JsonServer) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+// withClientActor makes the ip of the client the actor of the request, the json api
+// does not authenticate its clients.
+func withClientActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := service.WithActor(r.Context(), service.Actor("ip:"+clientIp(r)))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// MessageResponse is the body of requests which only report their success.
+type MessageResponse struct {
+	Message string `json:"message"`
 }
 
 func WriteToJson(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(v)
 }
@@ -0,0 +1,286 @@
+package json_handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheChosenGay/coffee/api"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/service/manage"
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// maxAnnouncementLength bounds the text of announcements, they are sent to every room.
+const maxAnnouncementLength = 1000
+
+// JsonAdminServiceHandler serves the admin api, it is registered on the admin server only.
+type JsonAdminServiceHandler struct {
+	svc manage.AdminService
+}
+
+func NewJsonAdminServiceHandler(svc manage.AdminService) api.JsonServerHandler {
+	return &JsonAdminServiceHandler{svc: svc}
+}
+
+func (s *JsonAdminServiceHandler) MakeJsonServiceHandler(router *api.Router) {
+	// online users and their connections
+	router.Get("/online/users", WithLogTime(s.listOnlineUsers)).Describe(api.RouteDoc{
+		OperationId: "listOnlineUsers",
+		Summary:     "List the online users and their connections",
+		Tag:         "admin",
+		Response:    OnlineUsersResponse{},
+		Errors:      []int{http.StatusUnauthorized},
+	})
+
+	// force disconnect
+	router.Delete("/online/users/{id}", WithLogTime(s.disconnectUser)).Describe(api.RouteDoc{
+		OperationId: "disconnectUser",
+		Summary:     "Disconnect an online user",
+		Tag:         "admin",
+		PathParams:  []api.Param{userIdParam},
+		Response:    api.MessageResponse{},
+		Errors:      []int{http.StatusUnauthorized, http.StatusNotFound},
+	})
+
+	// online rooms and their members
+	router.Get("/online/rooms", WithLogTime(s.listOnlineRooms)).Describe(api.RouteDoc{
+		OperationId: "listOnlineRooms",
+		Summary:     "List the online rooms and their members",
+		Tag:         "admin",
+		Response:    OnlineRoomsResponse{},
+		Errors:      []int{http.StatusUnauthorized},
+	})
+
+	// system announcement to every online room
+	router.Post("/announcements", WithLogTime(s.announce)).Describe(api.RouteDoc{
+		OperationId: "announce",
+		Summary:     "Broadcast a system announcement to every online room",
+		Tag:         "admin",
+		Body:        AnnounceRequest{},
+		Response:    AnnounceResponse{},
+		Errors:      []int{http.StatusUnauthorized},
+	})
+
+	// ban or unban a room
+	router.Put("/rooms/{id}/ban", WithLogTime(s.setRoomBanned)).Describe(api.RouteDoc{
+		OperationId: "setRoomBanned",
+		Summary:     "Ban or unban a room",
+		Tag:         "admin",
+		PathParams:  []api.Param{roomIdParam},
+		Body:        RoomBanRequest{},
+		Response:    api.MessageResponse{},
+		Errors:      []int{http.StatusUnauthorized, http.StatusNotFound},
+	})
+
+	// audit trail
+	router.Get("/audit", WithLogTime(s.listAudit)).Describe(api.RouteDoc{
+		OperationId: "listAudit",
+		Summary:     "List the audit trail of administrative and moderation actions, newest first",
+		Tag:         "admin",
+		QueryParams: []api.Param{
+			{Name: "actor", Description: "e.g. admin:alice"},
+			{Name: "action", Description: "e.g. ban_room"},
+			{Name: "target", Description: "e.g. room:42"},
+			{Name: "since", Description: "entries at or after this time", Format: "date-time"},
+			{Name: "until", Description: "entries before this time", Format: "date-time"},
+			{Name: "before_id", Description: "id of the last entry of the previous page", Type: "integer", Format: "int64"},
+			{Name: "limit", Description: fmt.Sprintf("at most %d, %d by default", service.MaxAuditLimit, service.DefaultAuditLimit), Type: "integer"},
+		},
+		Response: AuditResponse{},
+		Errors:   []int{http.StatusUnauthorized},
+	})
+}
+
+type OnlineUserResponse struct {
+	UserId     int64  `json:"user_id"`
+	Nickname   string `json:"nickname"`
+	RemoteAddr string `json:"remote_addr"`
+	// ConnectedAt is a unix timestamp in seconds
+	ConnectedAt int64 `json:"connected_at"`
+}
+
+type OnlineUsersResponse struct {
+	Users []OnlineUserResponse `json:"users"`
+}
+
+type OnlineRoomResponse struct {
+	RoomId  int64   `json:"room_id"`
+	Members []int64 `json:"members"`
+}
+
+type OnlineRoomsResponse struct {
+	Rooms []OnlineRoomResponse `json:"rooms"`
+}
+
+type AnnounceRequest struct {
+	Text string `json:"text"`
+}
+
+func (r AnnounceRequest) Validate() error {
+	if strings.TrimSpace(r.Text) == "" {
+		return errors.New("text is required")
+	}
+	if len(r.Text) > maxAnnouncementLength {
+		return fmt.Errorf("text must be at most %d bytes", maxAnnouncementLength)
+	}
+	return nil
+}
+
+type AnnounceResponse struct {
+	// Rooms is the number of online rooms the announcement was sent to
+	Rooms int `json:"rooms"`
+}
+
+type RoomBanRequest struct {
+	Banned bool `json:"banned"`
+}
+
+type AuditResponse struct {
+	Entries []types.AuditEntry `json:"entries"`
+}
+
+func (s *JsonAdminServiceHandler) listAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := store.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+	}
+	var err error
+	if filter.Since, err = queryTime(r, "since"); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if filter.Until, err = queryTime(r, "until"); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if filter.BeforeId, err = queryInt64(r, "before_id"); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	limit, err := queryInt64(r, "limit")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	filter.Limit = int(limit)
+
+	entries, err := s.svc.ListAudit(r.Context(), filter)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusOK, AuditResponse{Entries: entries})
+}
+
+// queryTime parses the RFC 3339 time of the query parameter name, it is zero when missing.
+func queryTime(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, service.InvalidArgument("invalid %s, expected an RFC 3339 time", name)
+	}
+	return t, nil
+}
+
+// queryInt64 parses the query parameter name, it is zero when missing.
+func queryInt64(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, service.InvalidArgument("invalid %s", name)
+	}
+	return n, nil
+}
+
+func (s *JsonAdminServiceHandler) listOnlineUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.svc.ListOnlineUsers(r.Context())
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	resp := OnlineUsersResponse{Users: make([]OnlineUserResponse, 0, len(users))}
+	for _, user := range users {
+		resp.Users = append(resp.Users, OnlineUserResponse{
+			UserId:      user.UserId,
+			Nickname:    user.Nickname,
+			RemoteAddr:  user.RemoteAddr,
+			ConnectedAt: user.ConnectedAt.Unix(),
+		})
+	}
+	api.WriteToJson(w, http.StatusOK, resp)
+}
+
+func (s *JsonAdminServiceHandler) disconnectUser(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if err := s.svc.DisconnectUser(r.Context(), userId); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusOK, api.MessageResponse{Message: fmt.Sprintf("user %d disconnected", userId)})
+}
+
+func (s *JsonAdminServiceHandler) listOnlineRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := s.svc.ListOnlineRooms(r.Context())
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	resp := OnlineRoomsResponse{Rooms: make([]OnlineRoomResponse, 0, len(rooms))}
+	for _, room := range rooms {
+		resp.Rooms = append(resp.Rooms, OnlineRoomResponse{RoomId: room.RoomId, Members: room.Members})
+	}
+	api.WriteToJson(w, http.StatusOK, resp)
+}
+
+func (s *JsonAdminServiceHandler) announce(w http.ResponseWriter, r *http.Request) {
+	var req AnnounceRequest
+	if err := api.DecodeJson(r, &req); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	rooms, err := s.svc.Announce(r.Context(), req.Text)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusOK, AnnounceResponse{Rooms: rooms})
+}
+
+func (s *JsonAdminServiceHandler) setRoomBanned(w http.ResponseWriter, r *http.Request) {
+	roomId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	var req RoomBanRequest
+	if err := api.DecodeJson(r, &req); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if err := s.svc.SetRoomBanned(r.Context(), roomId, req.Banned); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	state := "banned"
+	if !req.Banned {
+		state = "unbanned"
+	}
+	api.WriteToJson(w, http.StatusOK, api.MessageResponse{Message: fmt.Sprintf("room %d %s", roomId, state)})
+}
@@ -1,12 +1,7 @@
 package json_handler
 
 import (
-	"context"
-	"encoding/json"
-	"errors"
-	"math/rand/v2"
 	"net/http"
-	"strconv"
 
 	"github.com/TheChosenGay/coffee/api"
 	"github.com/TheChosenGay/coffee/service"
@@ -21,50 +16,47 @@ func NewJsonCoffeeServiceHandler(svc service.CoffeeService) api.JsonServerHandle
 	return &JsonCoffeeServiceHandler{svc: svc}
 }
 
-func (s *JsonCoffeeServiceHandler) MakeJsonServiceHandler() {
+func (s *JsonCoffeeServiceHandler) MakeJsonServiceHandler(router *api.Router) {
 	// list coffees
-	http.HandleFunc("/coffee/list", WithLogTime(s.listCoffees))
+	router.Get("/coffees", WithLogTime(s.listCoffees)).Describe(api.RouteDoc{
+		OperationId: "listCoffees",
+		Summary:     "List coffees",
+		Tag:         "coffee",
+		Response:    types.CoffeeListResponse{},
+	})
 
 	// get coffee by id
-	http.HandleFunc("/coffee/get", WithLogTime(s.getCoffeeById))
+	router.Get("/coffees/{id}", WithLogTime(s.getCoffeeById)).Describe(api.RouteDoc{
+		OperationId: "getCoffeeById",
+		Summary:     "Get a coffee",
+		Tag:         "coffee",
+		PathParams:  []api.Param{coffeeIdParam},
+		Response:    types.CoffeeResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
 }
 
-func (s *JsonCoffeeServiceHandler) listCoffees(w http.ResponseWriter, r *http.Request) {
-	reqId := rand.Int64N(1000000)
-	ctx := context.WithValue(r.Context(), "requestId", reqId)
-	// call real service
-	if err := s.listCoffeesWith(ctx, w, r); err != nil {
-		api.WriteToJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-	}
-}
+var coffeeIdParam = api.Param{Name: "id", Description: "coffee id", Type: "integer", Format: "int64"}
 
-func (s *JsonCoffeeServiceHandler) getCoffeeById(w http.ResponseWriter, r *http.Request) {
-	reqId := rand.Int64N(1000000)
-	ctx := context.WithValue(r.Context(), "requestId", reqId)
-	// call real service
-	if err := s.getCoffeeByIdWith(ctx, w, r); err != nil {
-		api.WriteToJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-		return
-	}
-}
-
-func (s *JsonCoffeeServiceHandler) listCoffeesWith(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
-	coffees, err := s.svc.ListCoffees(ctx)
+func (s *JsonCoffeeServiceHandler) listCoffees(w http.ResponseWriter, r *http.Request) {
+	coffees, err := s.svc.ListCoffees(r.Context())
 	if err != nil {
-		return err
+		api.WriteError(w, r, err)
+		return
 	}
-	return json.NewEncoder(w).Encode(types.CoffeeListResponse{Coffees: coffees})
+	api.WriteToJson(w, http.StatusOK, types.CoffeeListResponse{Coffees: coffees})
 }
 
-func (s *JsonCoffeeServiceHandler) getCoffeeByIdWith(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	id := r.URL.Query().Get("id")
-	idInt, err := strconv.Atoi(id)
+func (s *JsonCoffeeServiceHandler) getCoffeeById(w http.ResponseWriter, r *http.Request) {
+	id, err := api.PathInt64(r, "id")
 	if err != nil {
-		return errors.New("invalid id")
+		api.WriteError(w, r, err)
+		return
 	}
-	coffee, err := s.svc.GetCoffeeById(ctx, idInt)
+	coffee, err := s.svc.GetCoffeeById(r.Context(), id)
 	if err != nil {
-		return err
+		api.WriteError(w, r, err)
+		return
 	}
-	return json.NewEncoder(w).Encode(types.CoffeeResponse{Coffee: coffee})
+	api.WriteToJson(w, http.StatusOK, types.CoffeeResponse{Coffee: coffee})
 }
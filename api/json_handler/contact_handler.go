@@ -0,0 +1,283 @@
+package json_handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/TheChosenGay/coffee/api"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// JsonContactServiceHandler serves the contacts of the users, it is part of api v2 so
+// ids are encoded as strings.
+type JsonContactServiceHandler struct {
+	svc service.ContactService
+}
+
+func NewJsonContactServiceHandler(svc service.ContactService) api.JsonServerHandler {
+	return &JsonContactServiceHandler{svc: svc}
+}
+
+func (s *JsonContactServiceHandler) MakeJsonServiceHandler(router *api.Router) {
+	router.Get("/users/{id}/contacts", WithLogTime(s.listContacts)).Describe(api.RouteDoc{
+		OperationId: "listContacts",
+		Summary:     "List the contacts of a user ordered by id",
+		Tag:         "contact",
+		PathParams:  []api.Param{userIdParamV2},
+		QueryParams: []api.Param{
+			{Name: "state", Description: "friend (default), requested for the pending requests of the user, or blocked"},
+		},
+		Response: ListContactsResponse{},
+		Errors:   []int{http.StatusNotFound},
+	})
+
+	router.Post("/users/{id}/contacts", WithLogTime(s.sendContactRequest)).Describe(api.RouteDoc{
+		OperationId: "sendContactRequest",
+		Summary:     "Ask a user to be a contact, accepted right away when that user asked already",
+		Tag:         "contact",
+		PathParams:  []api.Param{userIdParamV2},
+		Body:        ContactRequest{},
+		Response:    ContactResponse{},
+		Status:      http.StatusCreated,
+		Errors:      []int{http.StatusNotFound, http.StatusConflict, http.StatusForbidden},
+	})
+
+	router.Delete("/users/{id}/contacts/{contact_id}", WithLogTime(s.removeContact)).Describe(api.RouteDoc{
+		OperationId: "removeContact",
+		Summary:     "Remove a contact of both users, or cancel a pending request",
+		Tag:         "contact",
+		PathParams:  []api.Param{userIdParamV2, contactIdParam},
+		Status:      http.StatusNoContent,
+		Errors:      []int{http.StatusNotFound},
+	})
+
+	router.Get("/users/{id}/contact_requests", WithLogTime(s.listContactRequests)).Describe(api.RouteDoc{
+		OperationId: "listContactRequests",
+		Summary:     "List the pending contact requests to a user",
+		Tag:         "contact",
+		PathParams:  []api.Param{userIdParamV2},
+		Response:    ListContactRequestsResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
+
+	router.Post("/users/{id}/contact_requests/{requester_id}/accept", WithLogTime(s.acceptContactRequest)).Describe(api.RouteDoc{
+		OperationId: "acceptContactRequest",
+		Summary:     "Accept a contact request",
+		Tag:         "contact",
+		PathParams:  []api.Param{userIdParamV2, requesterIdParam},
+		Response:    ContactResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
+
+	router.Delete("/users/{id}/contact_requests/{requester_id}", WithLogTime(s.declineContactRequest)).Describe(api.RouteDoc{
+		OperationId: "declineContactRequest",
+		Summary:     "Decline a contact request, the requester is not told",
+		Tag:         "contact",
+		PathParams:  []api.Param{userIdParamV2, requesterIdParam},
+		Status:      http.StatusNoContent,
+		Errors:      []int{http.StatusNotFound},
+	})
+
+	router.Put("/users/{id}/blocks/{contact_id}", WithLogTime(s.blockUser)).Describe(api.RouteDoc{
+		OperationId: "blockUser",
+		Summary:     "Block a user, ending any contact, its requests and direct messages are rejected",
+		Tag:         "contact",
+		PathParams:  []api.Param{userIdParamV2, contactIdParam},
+		Response:    ContactResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
+
+	router.Delete("/users/{id}/blocks/{contact_id}", WithLogTime(s.unblockUser)).Describe(api.RouteDoc{
+		OperationId: "unblockUser",
+		Summary:     "Unblock a user",
+		Tag:         "contact",
+		PathParams:  []api.Param{userIdParamV2, contactIdParam},
+		Status:      http.StatusNoContent,
+		Errors:      []int{http.StatusNotFound},
+	})
+}
+
+var (
+	contactIdParam   = api.Param{Name: "contact_id", Description: "user id of the contact", Type: "string", Format: "int64"}
+	requesterIdParam = api.Param{Name: "requester_id", Description: "user id of the requester", Type: "string", Format: "int64"}
+)
+
+type ContactRequest struct {
+	ContactId int64 `json:"contact_id,string"`
+}
+
+func (r ContactRequest) Validate() error {
+	if r.ContactId <= 0 {
+		return errors.New("contact_id is required")
+	}
+	return nil
+}
+
+type ContactResponse struct {
+	ContactId int64 `json:"contact_id,string"`
+	// State is friend, requested or blocked
+	State     string    `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newContactResponse(contact types.Contact) ContactResponse {
+	return ContactResponse{ContactId: contact.ContactId, State: contact.State.String(), UpdatedAt: contact.UpdatedAt}
+}
+
+type ListContactsResponse struct {
+	Contacts []ContactResponse `json:"contacts"`
+}
+
+type ContactRequestResponse struct {
+	RequesterId int64     `json:"requester_id,string"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+type ListContactRequestsResponse struct {
+	Requests []ContactRequestResponse `json:"requests"`
+}
+
+// pathIds parses the id of the user and the id of the other user of the path.
+func pathIds(r *http.Request, other string) (int64, int64, error) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		return 0, 0, err
+	}
+	otherId, err := api.PathInt64(r, other)
+	if err != nil {
+		return 0, 0, err
+	}
+	return userId, otherId, nil
+}
+
+func (s *JsonContactServiceHandler) listContacts(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	state := types.ContactFriend
+	if name := r.URL.Query().Get("state"); name != "" {
+		var ok bool
+		if state, ok = types.ParseContactState(name); !ok {
+			api.WriteError(w, r, service.InvalidArgument("invalid state %q", name))
+			return
+		}
+	}
+	contacts, err := s.svc.ListContacts(r.Context(), userId, state)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	resp := ListContactsResponse{Contacts: make([]ContactResponse, 0, len(contacts))}
+	for _, contact := range contacts {
+		resp.Contacts = append(resp.Contacts, newContactResponse(contact))
+	}
+	api.WriteToJson(w, http.StatusOK, resp)
+}
+
+func (s *JsonContactServiceHandler) sendContactRequest(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	var req ContactRequest
+	if err := api.DecodeJson(r, &req); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	contact, err := s.svc.SendContactRequest(r.Context(), userId, req.ContactId)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusCreated, newContactResponse(contact))
+}
+
+func (s *JsonContactServiceHandler) removeContact(w http.ResponseWriter, r *http.Request) {
+	userId, contactId, err := pathIds(r, "contact_id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if err := s.svc.RemoveContact(r.Context(), userId, contactId); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *JsonContactServiceHandler) listContactRequests(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	requests, err := s.svc.ListContactRequests(r.Context(), userId)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	resp := ListContactRequestsResponse{Requests: make([]ContactRequestResponse, 0, len(requests))}
+	for _, request := range requests {
+		resp.Requests = append(resp.Requests, ContactRequestResponse{RequesterId: request.UserId, RequestedAt: request.UpdatedAt})
+	}
+	api.WriteToJson(w, http.StatusOK, resp)
+}
+
+func (s *JsonContactServiceHandler) acceptContactRequest(w http.ResponseWriter, r *http.Request) {
+	userId, requesterId, err := pathIds(r, "requester_id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	contact, err := s.svc.AcceptContactRequest(r.Context(), userId, requesterId)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusOK, newContactResponse(contact))
+}
+
+func (s *JsonContactServiceHandler) declineContactRequest(w http.ResponseWriter, r *http.Request) {
+	userId, requesterId, err := pathIds(r, "requester_id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if err := s.svc.DeclineContactRequest(r.Context(), userId, requesterId); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *JsonContactServiceHandler) blockUser(w http.ResponseWriter, r *http.Request) {
+	userId, contactId, err := pathIds(r, "contact_id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	contact, err := s.svc.BlockUser(r.Context(), userId, contactId)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusOK, newContactResponse(contact))
+}
+
+func (s *JsonContactServiceHandler) unblockUser(w http.ResponseWriter, r *http.Request) {
+	userId, contactId, err := pathIds(r, "contact_id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if err := s.svc.UnblockUser(r.Context(), userId, contactId); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
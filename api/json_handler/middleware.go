@@ -1,26 +1,31 @@
 package json_handler
 
 import (
-	"fmt"
 	"net/http"
 	"reflect"
 	"runtime"
 	"strings"
 	"time"
 
+	"github.com/TheChosenGay/coffee/internal/logging"
 	"github.com/sirupsen/logrus"
 )
 
+var logger = logging.Package("json_handler")
+
 type HttpHandlerFunc func(w http.ResponseWriter, r *http.Request)
 
 func WithLogTime(handler HttpHandlerFunc) HttpHandlerFunc {
+	apiName := getFunctionName(handler)
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		handler(w, r)
-		duration := time.Since(start)
-		logrus.WithField("json_handler", logrus.Fields{
-			"api_name": getFunctionName(handler),
-		}).Info(fmt.Sprintf("Json Http Server. consume: %d us", duration.Nanoseconds()/1000))
+		logger.Ctx(r.Context()).WithFields(logrus.Fields{
+			"api_name":   apiName,
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"elapsed_us": time.Since(start).Microseconds(),
+		}).Info("json request")
 	}
 }
 
@@ -40,7 +45,7 @@ func getFunctionName(fn interface{}) string {
 	// 4. 提取方法名（最后一部分）
 	parts := strings.Split(fullName, ".")
 	if len(parts) > 0 {
-		return parts[len(parts)-1] // 返回: createRoom
+		return strings.TrimSuffix(parts[len(parts)-1], "-fm") // 返回: createRoom
 	}
 	return fullName
 }
@@ -0,0 +1,63 @@
+package json_handler
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/api"
+)
+
+var update = flag.Bool("update", false, "update the golden OpenAPI documents")
+
+// handlers are the json handlers of every version, like main registers them
+var handlers = map[api.Version][]api.JsonServerHandler{
+	api.V1: {
+		NewJsonCoffeeServiceHandler(nil),
+		NewJsonRoomServiceHandler(nil),
+		NewJsonUserServiceHandler(nil),
+	},
+	api.V2: {
+		NewJsonUserServiceHandlerV2(nil),
+		NewJsonContactServiceHandler(nil),
+	},
+}
+
+// TestOpenAPI keeps docs/openapi.<version>.json in sync with the routes, run
+// `go test ./api/json_handler -update` after changing them.
+func TestOpenAPI(t *testing.T) {
+	router := api.NewRouter()
+	for _, version := range api.Versions {
+		group := router.Group(version.Prefix())
+		for _, handler := range handlers[version] {
+			handler.MakeJsonServiceHandler(group)
+		}
+		for _, route := range group.Routes() {
+			if route.Doc == nil {
+				t.Errorf("route %s is not documented", route)
+			}
+		}
+
+		spec, err := api.OpenAPI(version, group.Routes())
+		if err != nil {
+			t.Fatalf("failed to build OpenAPI document: %v", err)
+		}
+		spec = append(spec, '\n')
+		golden := fmt.Sprintf("../../docs/openapi.%s.json", version)
+		if *update {
+			if err := os.WriteFile(golden, spec, 0o644); err != nil {
+				t.Fatalf("failed to update %s: %v", golden, err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", golden, err)
+		}
+		if !bytes.Equal(spec, want) {
+			t.Fatalf("%s is out of date, run `go test ./api/json_handler -update`", golden)
+		}
+	}
+}
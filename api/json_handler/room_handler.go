@@ -1,15 +1,13 @@
 package json_handler
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand/v2"
 	"net/http"
-	"strconv"
 
 	"github.com/TheChosenGay/coffee/api"
 	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/types"
 )
 
 type JsonRoomServiceHandler struct {
@@ -20,123 +18,185 @@ func NewJsonRoomServiceHandler(svc service.RoomService) api.JsonServerHandler {
 	return &JsonRoomServiceHandler{svc: svc}
 }
 
-func (s *JsonRoomServiceHandler) MakeJsonServiceHandler() {
+func (s *JsonRoomServiceHandler) MakeJsonServiceHandler(router *api.Router) {
 	// create room with max unit size
-	http.HandleFunc("/room/create", WithLogTime(s.createRoom))
+	router.Post("/rooms", WithLogTime(s.createRoom)).Describe(api.RouteDoc{
+		OperationId: "createRoom",
+		Summary:     "Create a room",
+		Tag:         "room",
+		Body:        CreateRoomRequest{},
+		Response:    CreateRoomResponse{},
+		Status:      http.StatusCreated,
+	})
 
 	// delete room
-	http.HandleFunc("/room/delete", WithLogTime(s.deleteRoom))
+	router.Delete("/rooms/{id}", WithLogTime(s.deleteRoom)).Describe(api.RouteDoc{
+		OperationId: "deleteRoom",
+		Summary:     "Delete a room",
+		Tag:         "room",
+		PathParams:  []api.Param{roomIdParam},
+		Response:    api.MessageResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
 
 	// list rooms
-	http.HandleFunc("/room/list", WithLogTime(s.listRooms))
+	router.Get("/rooms", WithLogTime(s.listRooms)).Describe(api.RouteDoc{
+		OperationId: "listRooms",
+		Summary:     "List rooms",
+		Tag:         "room",
+		Response:    []*types.Room{},
+	})
 
 	// join room
-	http.HandleFunc("/room/join", WithLogTime(s.joinRoom))
+	router.Post("/rooms/{id}/units", WithLogTime(s.joinRoom)).Describe(api.RouteDoc{
+		OperationId: "joinRoom",
+		Summary:     "Join an online user to a room",
+		Tag:         "room",
+		PathParams:  []api.Param{roomIdParam},
+		Body:        JoinRoomRequest{},
+		Response:    api.MessageResponse{},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict, http.StatusForbidden},
+	})
 
 	// quit room
-	http.HandleFunc("/room/quit", WithLogTime(s.quitRoom))
+	router.Delete("/rooms/{id}/units/{user_id}", WithLogTime(s.quitRoom)).Describe(api.RouteDoc{
+		OperationId: "quitRoom",
+		Summary:     "Remove a user from a room",
+		Tag:         "room",
+		PathParams:  []api.Param{roomIdParam, {Name: "user_id", Description: "user id", Type: "integer", Format: "int64"}},
+		Response:    api.MessageResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
 
 	// get room units
-	http.HandleFunc("/room/get_units", WithLogTime(s.getRoomUnits))
+	router.Get("/rooms/{id}/units", WithLogTime(s.getRoomUnits)).Describe(api.RouteDoc{
+		OperationId: "getRoomUnits",
+		Summary:     "List the online units of a room",
+		Tag:         "room",
+		PathParams:  []api.Param{roomIdParam},
+		Response:    RoomUnitsResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
+}
+
+var roomIdParam = api.Param{Name: "id", Description: "room id", Type: "integer", Format: "int64"}
+
+type CreateRoomRequest struct {
+	MaxUnitSize int `json:"max_unit_size"`
+}
+
+type CreateRoomResponse struct {
+	RoomId int64 `json:"room_id"`
+}
+
+func (r CreateRoomRequest) Validate() error {
+	if r.MaxUnitSize <= 0 {
+		return errors.New("max_unit_size must be positive")
+	}
+	return nil
+}
+
+type JoinRoomRequest struct {
+	UserId int64 `json:"user_id"`
+}
+
+func (r JoinRoomRequest) Validate() error {
+	if r.UserId <= 0 {
+		return errors.New("user_id is required")
+	}
+	return nil
 }
 
 func (s *JsonRoomServiceHandler) createRoom(w http.ResponseWriter, r *http.Request) {
-	maxUnitSize := r.URL.Query().Get("max_unit_size")
-	maxUnitSizeInt, err := strconv.Atoi(maxUnitSize)
-	if err != nil {
-		api.WriteToJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	var req CreateRoomRequest
+	if err := api.DecodeJson(r, &req); err != nil {
+		api.WriteError(w, r, err)
 		return
 	}
-	ctx := context.WithValue(r.Context(), "requestId", rand.Int64N(1000000))
-	roomId, err := s.svc.CreateRoomBySize(ctx, maxUnitSizeInt)
+	roomId, err := s.svc.CreateRoomBySize(r.Context(), req.MaxUnitSize)
 	if err != nil {
-		api.WriteToJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		api.WriteError(w, r, err)
 		return
 	}
-	api.WriteToJson(w, http.StatusOK, map[string]int{"room_id": roomId})
+	api.WriteToJson(w, http.StatusCreated, CreateRoomResponse{RoomId: roomId})
 }
 
 func (s *JsonRoomServiceHandler) deleteRoom(w http.ResponseWriter, r *http.Request) {
-	roomId := r.URL.Query().Get("room_id")
-	roomIdInt, err := strconv.Atoi(roomId)
+	roomId, err := api.PathInt64(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		api.WriteError(w, r, err)
+		return
 	}
-	ctx := context.WithValue(r.Context(), "requestId", rand.Int64N(1000000))
-	err = s.svc.DeleteRoom(ctx, roomIdInt)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.svc.DeleteRoom(r.Context(), roomId); err != nil {
+		api.WriteError(w, r, err)
 		return
 	}
-	api.WriteToJson(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("room %d deleted successfully", roomIdInt)})
+	api.WriteToJson(w, http.StatusOK, api.MessageResponse{Message: fmt.Sprintf("room %d deleted successfully", roomId)})
 }
 
 func (s *JsonRoomServiceHandler) listRooms(w http.ResponseWriter, r *http.Request) {
-	ctx := context.WithValue(r.Context(), "requestId", rand.Int64N(1000000))
-	rooms, err := s.svc.ListRoom(ctx)
+	rooms, err := s.svc.ListRoom(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		api.WriteError(w, r, err)
 		return
 	}
-
-	json.NewEncoder(w).Encode(rooms)
+	api.WriteToJson(w, http.StatusOK, rooms)
 }
 
 func (s *JsonRoomServiceHandler) joinRoom(w http.ResponseWriter, r *http.Request) {
-	roomIdStr := r.URL.Query().Get("room_id")
-	userIdStr := r.URL.Query().Get("user_id")
-	roomId, err := strconv.Atoi(roomIdStr)
+	roomId, err := api.PathInt64(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		api.WriteError(w, r, err)
+		return
 	}
-	userId, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req JoinRoomRequest
+	if err := api.DecodeJson(r, &req); err != nil {
+		api.WriteError(w, r, err)
+		return
 	}
-	ctx := context.WithValue(r.Context(), "requestId", rand.Int64N(1000000))
-	err = s.svc.JoinRoom(ctx, roomId, userId)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.svc.JoinRoom(r.Context(), roomId, req.UserId); err != nil {
+		api.WriteError(w, r, err)
 		return
 	}
-	api.WriteToJson(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("user %d joined room %d successfully", userId, roomId)})
+	api.WriteToJson(w, http.StatusOK, api.MessageResponse{Message: fmt.Sprintf("user %d joined room %d successfully", req.UserId, roomId)})
 }
 
 func (s *JsonRoomServiceHandler) quitRoom(w http.ResponseWriter, r *http.Request) {
-	roomIdStr := r.URL.Query().Get("room_id")
-	userIdStr := r.URL.Query().Get("user_id")
-	roomId, err := strconv.Atoi(roomIdStr)
+	roomId, err := api.PathInt64(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		api.WriteError(w, r, err)
+		return
 	}
-	userId, err := strconv.Atoi(userIdStr)
+	userId, err := api.PathInt64(r, "user_id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		api.WriteError(w, r, err)
+		return
 	}
-	ctx := context.WithValue(r.Context(), "requestId", rand.Int64N(1000000))
-	if err := s.svc.QuitRoom(ctx, roomId, userId); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.svc.QuitRoom(r.Context(), roomId, userId); err != nil {
+		api.WriteError(w, r, err)
 		return
 	}
-	api.WriteToJson(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("user %d quit room %d successfully", userId, roomId)})
+	api.WriteToJson(w, http.StatusOK, api.MessageResponse{Message: fmt.Sprintf("user %d quit room %d successfully", userId, roomId)})
 }
 
 type UnitResponse struct {
-	Id       int    `json:"id"`
+	Id       int64  `json:"id"`
 	Nickname string `json:"nickname"`
 }
 
+type RoomUnitsResponse struct {
+	Units []UnitResponse `json:"units"`
+}
+
 func (s *JsonRoomServiceHandler) getRoomUnits(w http.ResponseWriter, r *http.Request) {
-	roomIdStr := r.URL.Query().Get("room_id")
-	roomId, err := strconv.Atoi(roomIdStr)
+	roomId, err := api.PathInt64(r, "id")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		api.WriteError(w, r, err)
 		return
 	}
-	ctx := context.WithValue(r.Context(), "requestId", rand.Int64N(1000000))
-	units, err := s.svc.GetRoomUnits(ctx, roomId)
+	units, err := s.svc.GetRoomUnits(r.Context(), roomId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		api.WriteError(w, r, err)
 		return
 	}
 
@@ -149,5 +209,5 @@ func (s *JsonRoomServiceHandler) getRoomUnits(w http.ResponseWriter, r *http.Req
 		})
 	}
 
-	api.WriteToJson(w, http.StatusOK, map[string]interface{}{"units": unitResponses})
+	api.WriteToJson(w, http.StatusOK, RoomUnitsResponse{Units: unitResponses})
 }
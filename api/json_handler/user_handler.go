@@ -1,12 +1,9 @@
 package json_handler
 
 import (
-	"context"
+	"errors"
 	"fmt"
-	"log"
-	"math/rand/v2"
 	"net/http"
-	"strconv"
 
 	"github.com/TheChosenGay/coffee/api"
 	"github.com/TheChosenGay/coffee/service"
@@ -21,87 +18,108 @@ func NewJsonUserServiceHandler(svc service.UserService) api.JsonServerHandler {
 	return &JsonUserServiceHandler{svc: svc}
 }
 
-func (s *JsonUserServiceHandler) MakeJsonServiceHandler() {
-	http.HandleFunc("/user/register", WithLogTime(s.registerUser))
+func (s *JsonUserServiceHandler) MakeJsonServiceHandler(router *api.Router) {
+	router.Post("/users", WithLogTime(s.registerUser)).Describe(api.RouteDoc{
+		OperationId: "registerUser",
+		Summary:     "Register a user",
+		Tag:         "user",
+		Body:        RegisterUserRequest{},
+		Response:    api.MessageResponse{},
+		Status:      http.StatusCreated,
+	})
 
 	// delete user
-	http.HandleFunc("/user/delete", WithLogTime(s.deleteUser))
+	router.Delete("/users/{id}", WithLogTime(s.deleteUser)).Describe(api.RouteDoc{
+		OperationId: "deleteUser",
+		Summary:     "Delete a user",
+		Tag:         "user",
+		PathParams:  []api.Param{userIdParam},
+		Response:    api.MessageResponse{},
+	})
 
 	// list users
-	http.HandleFunc("/user/list", WithLogTime(s.listUsers))
+	router.Get("/users", WithLogTime(s.listUsers)).Describe(api.RouteDoc{
+		OperationId: "listUsers",
+		Summary:     "List users",
+		Tag:         "user",
+		Response:    []types.User{},
+	})
 
 	// get user by id
-	http.HandleFunc("/user/get", WithLogTime(s.getUserById))
+	router.Get("/users/{id}", WithLogTime(s.getUserById)).Describe(api.RouteDoc{
+		OperationId: "getUserById",
+		Summary:     "Get a user",
+		Tag:         "user",
+		PathParams:  []api.Param{userIdParam},
+		Response:    types.User{},
+		Errors:      []int{http.StatusNotFound},
+	})
 }
 
-func (s *JsonUserServiceHandler) registerUser(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	ctx = context.WithValue(ctx, "requestId", rand.Int64N(1000000))
-	nickName := r.URL.Query().Get("nickname")
-	sex := r.URL.Query().Get("sex")
+var userIdParam = api.Param{Name: "id", Description: "user id", Type: "integer", Format: "int64"}
 
-	if nickName == "" {
-		api.WriteToJson(w, http.StatusBadRequest, map[string]string{"error": "nickname is required"})
-		return
+type RegisterUserRequest struct {
+	Nickname string    `json:"nickname"`
+	Sex      types.Sex `json:"sex"`
+}
+
+func (r RegisterUserRequest) Validate() error {
+	if r.Nickname == "" {
+		return errors.New("nickname is required")
+	}
+	if r.Sex != types.Male && r.Sex != types.Female {
+		return errors.New("invalid sex")
 	}
+	return nil
+}
 
-	sexInt, err := strconv.Atoi(sex)
-	if err != nil {
-		api.WriteToJson(w, http.StatusBadRequest, map[string]string{"error": "invalid sex"})
+func (s *JsonUserServiceHandler) registerUser(w http.ResponseWriter, r *http.Request) {
+	var req RegisterUserRequest
+	if err := api.DecodeJson(r, &req); err != nil {
+		api.WriteError(w, r, err)
 		return
 	}
-	userId, err := s.svc.RegisterUser(ctx, types.User{Nickname: nickName, Sex: types.Sex(sexInt)})
+	userId, err := s.svc.RegisterUser(r.Context(), types.User{Nickname: req.Nickname, Sex: req.Sex})
 	if err != nil {
-		api.WriteToJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		api.WriteError(w, r, err)
 		return
 	}
-	api.WriteToJson(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("user(userId:%d) registered successfully", userId)})
+	api.WriteToJson(w, http.StatusCreated, api.MessageResponse{Message: fmt.Sprintf("user(userId:%d) registered successfully", userId)})
 }
 
 func (s *JsonUserServiceHandler) deleteUser(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	ctx = context.WithValue(ctx, "requestId", rand.Int64N(1000000))
-	userId := r.URL.Query().Get("user_id")
-	if userId == "" {
-		api.WriteToJson(w, http.StatusBadRequest, map[string]string{"error": "user id is required"})
-		return
-	}
-	userIdInt, err := strconv.Atoi(userId)
+	userId, err := api.PathInt64(r, "id")
 	if err != nil {
-		api.WriteToJson(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		api.WriteError(w, r, err)
 		return
 	}
-	err = s.svc.DeleteUser(ctx, userIdInt)
-	if err != nil {
-		api.WriteToJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := s.svc.DeleteUser(r.Context(), userId); err != nil {
+		api.WriteError(w, r, err)
 		return
 	}
-	api.WriteToJson(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("user(userId:%d) deleted successfully", userIdInt)})
+	api.WriteToJson(w, http.StatusOK, api.MessageResponse{Message: fmt.Sprintf("user(userId:%d) deleted successfully", userId)})
 }
 
 func (s *JsonUserServiceHandler) listUsers(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	ctx = context.WithValue(ctx, "requestId", rand.Int64N(1000000))
 	users, err := s.svc.ListUser(ctx)
 	if err != nil {
-		log.Println("list users error:", err)
-		api.WriteToJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		logger.Ctx(ctx).WithError(err).Error("list users error")
+		api.WriteError(w, r, err)
 		return
 	}
 	api.WriteToJson(w, http.StatusOK, users)
 }
 
 func (s *JsonUserServiceHandler) getUserById(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	userIdStr := r.URL.Query().Get("id")
-	userIdInt, err := strconv.Atoi(userIdStr)
+	userId, err := api.PathInt64(r, "id")
 	if err != nil {
-		api.WriteToJson(w, http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+		api.WriteError(w, r, err)
 		return
 	}
-	user, err := s.svc.GetUser(ctx, userIdInt)
+	user, err := s.svc.GetUser(r.Context(), userId)
 	if err != nil {
-		api.WriteToJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		api.WriteError(w, r, err)
 		return
 	}
 	api.WriteToJson(w, http.StatusOK, user)
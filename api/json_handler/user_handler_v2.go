@@ -0,0 +1,275 @@
+package json_handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/TheChosenGay/coffee/api"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// JsonUserServiceHandlerV2 serves the users of api v2. Unlike v1, ids are encoded as
+// strings, snowflake ids do not fit the numbers of javascript, fields are snake case
+// and the registered user is returned.
+type JsonUserServiceHandlerV2 struct {
+	svc service.UserService
+}
+
+func NewJsonUserServiceHandlerV2(svc service.UserService) api.JsonServerHandler {
+	return &JsonUserServiceHandlerV2{svc: svc}
+}
+
+func (s *JsonUserServiceHandlerV2) MakeJsonServiceHandler(router *api.Router) {
+	router.Post("/users", WithLogTime(s.registerUser)).Describe(api.RouteDoc{
+		OperationId: "registerUser",
+		Summary:     "Register a user",
+		Tag:         "user",
+		Body:        RegisterUserRequest{},
+		Response:    UserResponse{},
+		Status:      http.StatusCreated,
+	})
+
+	router.Delete("/users/{id}", WithLogTime(s.deleteUser)).Describe(api.RouteDoc{
+		OperationId: "deleteUser",
+		Summary:     "Delete a user",
+		Tag:         "user",
+		PathParams:  []api.Param{userIdParamV2},
+		Status:      http.StatusNoContent,
+	})
+
+	router.Get("/users", WithLogTime(s.listUsers)).Describe(api.RouteDoc{
+		OperationId: "listUsers",
+		Summary:     "List users ordered by id, all of them unless a limit is given",
+		Tag:         "user",
+		QueryParams: []api.Param{
+			{Name: "after_id", Description: "the next_after_id of the previous page", Format: "int64"},
+			{Name: "limit", Description: fmt.Sprintf("users of a page, at most %d", service.MaxUserPageLimit), Type: "integer"},
+		},
+		Response: ListUsersResponse{},
+	})
+
+	router.Get("/users/{id}", WithLogTime(s.getUserById)).Describe(api.RouteDoc{
+		OperationId: "getUserById",
+		Summary:     "Get a user",
+		Tag:         "user",
+		PathParams:  []api.Param{userIdParamV2},
+		Response:    UserResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
+
+	router.Patch("/users/{id}", WithLogTime(s.updateProfile)).Describe(api.RouteDoc{
+		OperationId: "updateProfile",
+		Summary:     "Update the profile of a user, fields which are not given are kept",
+		Tag:         "user",
+		PathParams:  []api.Param{userIdParamV2},
+		Body:        UpdateProfileRequest{},
+		Response:    UserResponse{},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict},
+	})
+
+	router.Put("/users/{id}/avatar", WithLogTime(s.uploadAvatar)).Describe(api.RouteDoc{
+		OperationId: "uploadAvatar",
+		Summary:     fmt.Sprintf("Upload the avatar of a user, an image of at most %d bytes", service.MaxAvatarSize),
+		Tag:         "user",
+		PathParams:  []api.Param{userIdParamV2},
+		BodyTypes:   service.AvatarContentTypes,
+		Response:    UserResponse{},
+		Errors:      []int{http.StatusNotFound},
+	})
+
+	router.Get("/users/{id}/avatar", WithLogTime(s.getAvatar)).Describe(api.RouteDoc{
+		OperationId:   "getAvatar",
+		Summary:       "Get the uploaded avatar of a user",
+		Tag:           "user",
+		PathParams:    []api.Param{userIdParamV2},
+		ResponseTypes: service.AvatarContentTypes,
+		Errors:        []int{http.StatusNotFound},
+	})
+}
+
+var userIdParamV2 = api.Param{Name: "id", Description: "user id", Type: "string", Format: "int64"}
+
+type UserResponse struct {
+	UserId    int64     `json:"user_id,string"`
+	Nickname  string    `json:"nickname"`
+	Sex       types.Sex `json:"sex"`
+	Age       int       `json:"age"`
+	Birthday  int64     `json:"birthday"`
+	AvatarUrl string    `json:"avatar_url"`
+	Bio       string    `json:"bio"`
+	Status    string    `json:"status"`
+}
+
+func newUserResponse(user types.User) UserResponse {
+	return UserResponse{
+		UserId:    user.UserId,
+		Nickname:  user.Nickname,
+		Sex:       user.Sex,
+		Age:       user.Age,
+		Birthday:  user.Birthday,
+		AvatarUrl: user.AvatarUrl,
+		Bio:       user.Bio,
+		Status:    user.Status,
+	}
+}
+
+// UpdateProfileRequest sets the fields of the profile which are given, an empty
+// avatar_url, bio or status clears it.
+type UpdateProfileRequest struct {
+	Nickname  *string    `json:"nickname"`
+	Sex       *types.Sex `json:"sex"`
+	Age       *int       `json:"age"`
+	Birthday  *int64     `json:"birthday"`
+	AvatarUrl *string    `json:"avatar_url"`
+	Bio       *string    `json:"bio"`
+	Status    *string    `json:"status"`
+}
+
+type ListUsersResponse struct {
+	Users []UserResponse `json:"users"`
+	// NextAfterId is the after_id of the next page, it is only set when the page is full
+	NextAfterId int64 `json:"next_after_id,omitempty,string"`
+}
+
+func (s *JsonUserServiceHandlerV2) registerUser(w http.ResponseWriter, r *http.Request) {
+	var req RegisterUserRequest
+	if err := api.DecodeJson(r, &req); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	user := types.User{Nickname: req.Nickname, Sex: req.Sex}
+	userId, err := s.svc.RegisterUser(r.Context(), user)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	user.UserId = userId
+	api.WriteToJson(w, http.StatusCreated, newUserResponse(user))
+}
+
+func (s *JsonUserServiceHandlerV2) deleteUser(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	if err := s.svc.DeleteUser(r.Context(), userId); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *JsonUserServiceHandlerV2) listUsers(w http.ResponseWriter, r *http.Request) {
+	afterId, err := queryInt64(r, "after_id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	limit, err := queryInt64(r, "limit")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	var users []types.User
+	if limit == 0 && afterId == 0 {
+		users, err = s.svc.ListUser(r.Context())
+	} else {
+		users, err = s.svc.ListUserPage(r.Context(), afterId, int(limit))
+	}
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	resp := ListUsersResponse{Users: make([]UserResponse, 0, len(users))}
+	for _, user := range users {
+		resp.Users = append(resp.Users, newUserResponse(user))
+	}
+	if limit > 0 && len(users) == int(limit) {
+		resp.NextAfterId = users[len(users)-1].UserId
+	}
+	api.WriteToJson(w, http.StatusOK, resp)
+}
+
+func (s *JsonUserServiceHandlerV2) getUserById(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	user, err := s.svc.GetUser(r.Context(), userId)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusOK, newUserResponse(user))
+}
+
+func (s *JsonUserServiceHandlerV2) updateProfile(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	var req UpdateProfileRequest
+	if err := api.DecodeJson(r, &req); err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	user, err := s.svc.UpdateProfile(r.Context(), userId, types.ProfileUpdate{
+		Nickname:  req.Nickname,
+		Sex:       req.Sex,
+		Age:       req.Age,
+		Birthday:  req.Birthday,
+		AvatarUrl: req.AvatarUrl,
+		Bio:       req.Bio,
+		Status:    req.Status,
+	})
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusOK, newUserResponse(user))
+}
+
+func (s *JsonUserServiceHandlerV2) uploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	// one byte more than allowed, so that the service rejects larger avatars
+	data, err := io.ReadAll(io.LimitReader(r.Body, service.MaxAvatarSize+1))
+	if err != nil {
+		api.WriteError(w, r, service.InvalidArgument("failed to read avatar: %v", err))
+		return
+	}
+	user, err := s.svc.UploadAvatar(r.Context(), userId, data)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	api.WriteToJson(w, http.StatusOK, newUserResponse(user))
+}
+
+func (s *JsonUserServiceHandlerV2) getAvatar(w http.ResponseWriter, r *http.Request) {
+	userId, err := api.PathInt64(r, "id")
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	avatar, err := s.svc.GetAvatar(r.Context(), userId)
+	if err != nil {
+		api.WriteError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", avatar.ContentType)
+	// the avatar url changes with every upload, a versioned url can be kept
+	if r.URL.Query().Has("v") {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	http.ServeContent(w, r, "", avatar.UpdatedAt, bytes.NewReader(avatar.Data))
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OpenAPIVersion = "3.0.3"
+	APITitle       = "coffee json api"
+)
+
+// RouteDoc is the OpenAPI description of a route.
+type RouteDoc struct {
+	OperationId string
+	Summary     string
+	Tag         string
+	// PathParams describes the parameters of the pattern, undescribed parameters are strings.
+	PathParams []Param
+	// QueryParams describes the optional query parameters.
+	QueryParams []Param
+	// Body is a value of the json request body, e.g. CreateRoomRequest{}.
+	Body any
+	// BodyTypes are the media types of a binary request body, e.g. an uploaded image,
+	// which is documented instead of Body.
+	BodyTypes []string
+	// Response is a value of the json response body, nil when the route has no body.
+	Response any
+	// ResponseTypes are the media types of a binary response body, which is documented
+	// instead of Response.
+	ResponseTypes []string
+	// Status is the status of a successful response, 200 by default.
+	Status int
+	// Errors are the statuses of the ErrorResponses the route returns besides
+	// 400 for invalid parameters, 429 for rate limited clients and 500.
+	Errors []int
+}
+
+type Param struct {
+	Name        string
+	Description string
+	// Type is a json schema type, "string" by default.
+	Type   string
+	Format string
+}
+
+var pathParamRegexp = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// OpenAPI builds the OpenAPI document of the documented routes of version.
+func OpenAPI(version Version, routes []Route) ([]byte, error) {
+	b := &openAPIBuilder{schemas: map[string]any{}}
+	b.schema(reflect.TypeOf(ErrorResponse{}))
+
+	paths := map[string]map[string]any{}
+	for _, route := range routes {
+		if route.Doc == nil {
+			continue
+		}
+		path := pathParamRegexp.ReplaceAllString(route.Pattern, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(route.Method)] = b.operation(route)
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi": OpenAPIVersion,
+		"info": map[string]any{
+			"title":   APITitle,
+			"version": string(version),
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+		},
+	}, "", "  ")
+}
+
+type openAPIBuilder struct {
+	schemas map[string]any
+}
+
+func (b *openAPIBuilder) operation(route Route) map[string]any {
+	doc := route.Doc
+	op := map[string]any{}
+	if doc.OperationId != "" {
+		op["operationId"] = doc.OperationId
+	}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+	if doc.Tag != "" {
+		op["tags"] = []string{doc.Tag}
+	}
+	if route.Deprecation != nil {
+		op["deprecated"] = true
+	}
+
+	var params []any
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(route.Pattern, -1) {
+		param := Param{Name: match[1]}
+		for _, p := range doc.PathParams {
+			if p.Name == param.Name {
+				param = p
+			}
+		}
+		params = append(params, param.parameter("path"))
+	}
+	for _, param := range doc.QueryParams {
+		params = append(params, param.parameter("query"))
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if len(doc.BodyTypes) > 0 {
+		op["requestBody"] = map[string]any{"required": true, "content": binaryContent(doc.BodyTypes)}
+	} else if doc.Body != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(doc.Body))},
+			},
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if len(doc.ResponseTypes) > 0 {
+		success["content"] = binaryContent(doc.ResponseTypes)
+	} else if doc.Response != nil {
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(doc.Response))},
+		}
+	}
+	responses := map[string]any{strconv.Itoa(status): success}
+
+	errors := append([]int{http.StatusTooManyRequests, http.StatusInternalServerError}, doc.Errors...)
+	if len(params) > 0 || doc.Body != nil || len(doc.BodyTypes) > 0 {
+		errors = append(errors, http.StatusBadRequest)
+	}
+	for _, status := range errors {
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": http.StatusText(status),
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"}},
+			},
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// binaryContent returns the OpenAPI content of a binary body of the media types.
+func binaryContent(mediaTypes []string) map[string]any {
+	content := make(map[string]any, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		content[mediaType] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+	}
+	return content
+}
+
+// parameter returns the OpenAPI parameter of p, path parameters are required.
+func (p Param) parameter(in string) map[string]any {
+	if p.Type == "" {
+		p.Type = "string"
+	}
+	schema := map[string]any{"type": p.Type}
+	if p.Format != "" {
+		schema["format"] = p.Format
+	}
+	param := map[string]any{
+		"name":     p.Name,
+		"in":       in,
+		"required": in == "path",
+		"schema":   schema,
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	return param
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the json schema of t, named structs are added to the components
+// and referenced.
+func (b *openAPIBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		// any json value
+		return map[string]any{}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := b.schemas[t.Name()]; !ok {
+			// reserve the name first for recursive types
+			b.schemas[t.Name()] = nil
+			b.schemas[t.Name()] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return b.object(t)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *openAPIBuilder) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	b.fields(t, properties)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// fields adds the properties of the json encoding of t, embedded structs are inlined.
+func (b *openAPIBuilder) fields(t reflect.Type, properties map[string]any) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.fields(ft, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if slices.Contains(strings.Split(options, ","), "string") && isNumber(field.Type.Kind()) {
+			// encoded as a json string by the string option
+			properties[name] = map[string]any{"type": "string", "format": b.schema(field.Type)["format"]}
+			continue
+		}
+		properties[name] = b.schema(field.Type)
+	}
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
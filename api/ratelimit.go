@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"github.com/TheChosenGay/coffee/service"
+)
+
+// RateLimitOpts are the limits of the json server, nil limiters are disabled.
+type RateLimitOpts struct {
+	// IP limits the requests per client ip, the probes and metrics are not limited.
+	IP ratelimit.Limiter
+	// Routes limits single routes per client ip, keyed by Route.Key, e.g. "POST /users".
+	// The limit of a route is shared by all its versions.
+	Routes map[string]ratelimit.Limiter
+}
+
+// unlimitedPaths are scraped and probed by the infrastructure, which must not be throttled.
+var unlimitedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// withRateLimit answers requests over the limit of their client ip with 429.
+func withRateLimit(limiter ratelimit.Limiter, next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !unlimitedPaths[r.URL.Path] {
+			if err := limiter.Allow(r.Context(), clientIp(r)); err != nil {
+				WriteError(w, r, service.Wrap(service.KindResourceExhausted, err, "rate limit exceeded"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitRoutes limits the routes of limiters per client ip.
+func limitRoutes(limiters map[string]ratelimit.Limiter) RouteMiddleware {
+	return func(route Route, next http.Handler) http.Handler {
+		limiter, ok := limiters[route.Key()]
+		if !ok {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := limiter.Allow(r.Context(), clientIp(r)); err != nil {
+				WriteError(w, r, service.Wrap(service.KindResourceExhausted, err, "rate limit of %s exceeded", route))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIp returns the ip of the client of r, like peerIp the last x-forwarded-for
+// entry is trusted only from a proxy on the loopback.
+func clientIp(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.IsLoopback() {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ips := strings.Split(forwarded, ",")
+			return strings.TrimSpace(ips[len(ips)-1])
+		}
+	}
+	return ip
+}
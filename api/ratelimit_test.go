@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+)
+
+func TestRouteRateLimit(t *testing.T) {
+	router := NewRouter()
+	router.Use(limitRoutes(map[string]ratelimit.Limiter{
+		"POST /users": ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 0.1, Burst: 1}),
+	}))
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router.Post("/users", ok)
+	router.Get("/users", ok)
+
+	serve := func(method, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users", nil)
+		req.RemoteAddr = ip + ":4000"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := serve(http.MethodPost, "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Fatalf("first registration rejected: %d", rec.Code)
+	}
+	rec := serve(http.MethodPost, "10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "10" {
+		t.Fatalf("expected 429 retrying after 10s, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := serve(http.MethodGet, "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Fatalf("other routes are limited: %d", rec.Code)
+	}
+	if rec := serve(http.MethodPost, "10.0.0.2"); rec.Code != http.StatusOK {
+		t.Fatalf("other clients are limited: %d", rec.Code)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// withRequestId takes the request id from the X-Request-ID header or generates one,
+// stores it in the request context and echoes it in the response.
+func withRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := logging.RequestId(r.Header.Get(logging.RequestIdHeader))
+		if !logging.ValidRequestId(string(id)) {
+			id = logging.NewRequestId()
+		}
+		w.Header().Set(logging.RequestIdHeader, string(id))
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", string(id)))
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestId(r.Context(), id)))
+	})
+}
+
+// grpcRequestId does the same as withRequestId with the x-request-id metadata.
+func grpcRequestId(ctx context.Context) context.Context {
+	var id logging.RequestId
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(strings.ToLower(logging.RequestIdHeader)); len(values) > 0 && logging.ValidRequestId(values[0]) {
+			id = logging.RequestId(values[0])
+		}
+	}
+	if id == "" {
+		id = logging.NewRequestId()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(logging.RequestIdHeader), string(id)))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", string(id)))
+	return logging.WithRequestId(ctx, id)
+}
+
+func requestIdUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(grpcRequestId(ctx), req)
+}
+
+func requestIdStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: grpcRequestId(ss.Context())})
+}
+
+// wrappedStream replaces the context of a server stream.
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedStream) Context() context.Context {
+	return s.ctx
+}
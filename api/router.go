@@ -0,0 +1,262 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/TheChosenGay/coffee/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxJsonBodySize limits the size of json request bodies.
+const maxJsonBodySize = 1 << 20
+
+type Route struct {
+	Method string
+	// Pattern includes the prefix of the group the route is registered in, e.g. /v1/users/{id}.
+	Pattern string
+	// Prefix is the prefix of the group the route is registered in, e.g. /v1.
+	Prefix string
+	// Doc describes the route in the OpenAPI document, undocumented routes are left out of it.
+	Doc *RouteDoc
+	// Deprecation is set when the route is deprecated, its responses tell clients so.
+	Deprecation *Deprecation
+}
+
+// String returns the route as "METHOD pattern", e.g. "POST /v1/users".
+func (r Route) String() string {
+	return r.Method + " " + r.Pattern
+}
+
+// Key returns the route without the prefix of its group, e.g. "POST /users" for
+// POST /v1/users, it is the same for a route in every version.
+func (r Route) Key() string {
+	return r.Method + " " + strings.TrimPrefix(r.Pattern, r.Prefix)
+}
+
+// Describe documents the route, it is meant to be chained to the registration:
+//
+//	router.Get("/rooms/{id}", s.getRoom).Describe(api.RouteDoc{...})
+func (r *Route) Describe(doc RouteDoc) *Route {
+	r.Doc = &doc
+	return r
+}
+
+// Deprecate marks the route as deprecated, d.Successor is the path of the route replacing it.
+func (r *Route) Deprecate(d Deprecation) *Route {
+	r.Deprecation = &d
+	return r
+}
+
+// RouteMiddleware wraps the handler of a route, e.g. to apply a limit of the route.
+type RouteMiddleware func(route Route, next http.Handler) http.Handler
+
+// Router dispatches requests by method and path pattern. Patterns follow http.ServeMux,
+// so path parameters like /rooms/{id} are read with r.PathValue("id").
+// Unknown paths are answered with 404 and known paths with an unregistered method with 405.
+//
+// Groups of a router, e.g. the versions of the api, register their routes under their
+// prefix in the same table.
+type Router struct {
+	*routeTable
+	prefix      string
+	uses        []RouteMiddleware
+	deprecation *Deprecation
+}
+
+type routeTable struct {
+	mux      *http.ServeMux
+	handlers map[string]map[string]routeHandler // pattern -> method -> handler
+	routes   []*Route
+}
+
+type routeHandler struct {
+	route   *Route
+	handler http.Handler
+}
+
+func NewRouter() *Router {
+	r := &Router{routeTable: &routeTable{
+		mux:      http.NewServeMux(),
+		handlers: make(map[string]map[string]routeHandler),
+	}}
+	r.mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		// unknown paths are not a route, keep them out of the route labels of metrics and traces
+		req.Pattern = ""
+		WriteError(w, req, service.NotFound("no route for %s", req.URL.Path))
+	})
+	return r
+}
+
+// Group returns a router registering its routes under prefix, e.g. "/v1". The group
+// starts with the middlewares and deprecation of r.
+func (r *Router) Group(prefix string) *Router {
+	return &Router{
+		routeTable:  r.routeTable,
+		prefix:      r.prefix + prefix,
+		uses:        slices.Clone(r.uses),
+		deprecation: r.deprecation,
+	}
+}
+
+// Deprecate marks the routes registered on r afterwards as deprecated. d.Successor is
+// the prefix of the group replacing r, e.g. "/v2", the successor of every route is the
+// same route in that group.
+func (r *Router) Deprecate(d Deprecation) {
+	r.deprecation = &d
+}
+
+func (r *Router) Handle(method, pattern string, handler http.Handler) *Route {
+	pattern = r.prefix + pattern
+	methods, ok := r.handlers[pattern]
+	if !ok {
+		methods = make(map[string]routeHandler)
+		r.handlers[pattern] = methods
+		r.mux.Handle(pattern, r.dispatch(pattern))
+	}
+	if _, ok := methods[method]; ok {
+		panic(fmt.Sprintf("route %s %s already registered", method, pattern))
+	}
+	route := &Route{Method: method, Pattern: pattern, Prefix: r.prefix}
+	if r.deprecation != nil {
+		d := *r.deprecation
+		if d.Successor != "" {
+			d.Successor += strings.TrimPrefix(pattern, r.prefix)
+		}
+		route.Deprecation = &d
+	}
+	for i := len(r.uses) - 1; i >= 0; i-- {
+		handler = r.uses[i](*route, handler)
+	}
+	methods[method] = routeHandler{route: route, handler: handler}
+	r.routes = append(r.routes, route)
+	return route
+}
+
+func (r *Router) HandleFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request)) *Route {
+	return r.Handle(method, pattern, http.HandlerFunc(handler))
+}
+
+func (r *Router) Get(pattern string, handler func(http.ResponseWriter, *http.Request)) *Route {
+	return r.HandleFunc(http.MethodGet, pattern, handler)
+}
+
+func (r *Router) Post(pattern string, handler func(http.ResponseWriter, *http.Request)) *Route {
+	return r.HandleFunc(http.MethodPost, pattern, handler)
+}
+
+func (r *Router) Put(pattern string, handler func(http.ResponseWriter, *http.Request)) *Route {
+	return r.HandleFunc(http.MethodPut, pattern, handler)
+}
+
+func (r *Router) Patch(pattern string, handler func(http.ResponseWriter, *http.Request)) *Route {
+	return r.HandleFunc(http.MethodPatch, pattern, handler)
+}
+
+func (r *Router) Delete(pattern string, handler func(http.ResponseWriter, *http.Request)) *Route {
+	return r.HandleFunc(http.MethodDelete, pattern, handler)
+}
+
+// Use wraps the handlers of the routes registered on r afterwards by mw, middlewares
+// used first run first.
+func (r *Router) Use(mw RouteMiddleware) {
+	r.uses = append(r.uses, mw)
+}
+
+// Mount serves every request under prefix, which must end with a slash, by handler.
+// Mounted handlers do their own routing, so they are not part of Routes.
+func (r *Router) Mount(prefix string, handler http.Handler) {
+	prefix = r.prefix + prefix
+	if !strings.HasSuffix(prefix, "/") {
+		panic(fmt.Sprintf("mount prefix %s must end with a slash", prefix))
+	}
+	r.mux.Handle(prefix, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		trace.SpanFromContext(req.Context()).SetName(req.Method + " " + prefix)
+		handler.ServeHTTP(w, req)
+	}))
+}
+
+// Routes returns the routes registered on r and its groups in order of registration.
+func (r *Router) Routes() []Route {
+	routes := make([]Route, 0, len(r.routes))
+	for _, route := range r.routes {
+		if route.Prefix == r.prefix || strings.HasPrefix(route.Prefix, r.prefix+"/") {
+			routes = append(routes, *route)
+		}
+	}
+	return routes
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+func (r *Router) dispatch(pattern string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		methods := r.handlers[pattern]
+		span := trace.SpanFromContext(req.Context())
+		span.SetName(req.Method + " " + pattern)
+		span.SetAttributes(attribute.String("http.route", pattern))
+		h, ok := methods[req.Method]
+		if !ok && req.Method == http.MethodHead {
+			h, ok = methods[http.MethodGet]
+		}
+		if ok {
+			if h.route.Deprecation != nil {
+				h.route.Deprecation.announce(w, req, h.route.String())
+			}
+			h.handler.ServeHTTP(w, req)
+			return
+		}
+		allowed := make([]string, 0, len(methods))
+		for method := range methods {
+			allowed = append(allowed, method)
+		}
+		slices.Sort(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeErrorResponse(w, req, http.StatusMethodNotAllowed, "method_not_allowed", fmt.Sprintf("method %s is not allowed", req.Method))
+	})
+}
+
+// Validator is implemented by request bodies which check their fields after decoding.
+type Validator interface {
+	Validate() error
+}
+
+// DecodeJson decodes the json body of r into v and validates it when v implements Validator,
+// the returned errors are of kind service.KindInvalidArgument.
+func DecodeJson(r *http.Request, v any) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+		return service.InvalidArgument("unsupported content type: %s", ct)
+	}
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxJsonBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return service.InvalidArgument("request body is required")
+		}
+		return service.InvalidArgument("invalid request body: %v", err)
+	}
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return service.InvalidArgument("%v", err)
+		}
+	}
+	return nil
+}
+
+// PathInt64 parses the path parameter name as an int64.
+func PathInt64(r *http.Request, name string) (int64, error) {
+	value, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		return 0, service.InvalidArgument("invalid %s", name)
+	}
+	return value, nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testBody struct {
+	Name string `json:"name"`
+}
+
+func (b testBody) Validate() error {
+	if b.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestRouterMethodsAndParams(t *testing.T) {
+	router := NewRouter()
+	router.Get("/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get " + r.PathValue("id")))
+	})
+	router.Delete("/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("delete " + r.PathValue("id")))
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/rooms/42", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "delete 42" {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rooms/42", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "DELETE, GET" {
+		t.Fatalf("unexpected allow header: %s", allow)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestRouterGroups(t *testing.T) {
+	router := NewRouter()
+	getRoom := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("room " + r.PathValue("id")))
+	}
+	v1 := router.Group(V1.Prefix())
+	v1.Get("/rooms/{id}", getRoom)
+	legacy := router.Group("")
+	legacy.Deprecate(legacyDeprecation)
+	legacy.Get("/rooms/{id}", getRoom)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/rooms/42", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "room 42" || rec.Header().Get("Deprecation") != "" {
+		t.Fatalf("unexpected response: %d %s %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rooms/42", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "room 42" {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Deprecation") == "" {
+		t.Fatal("expected a deprecation header")
+	}
+	if link := rec.Header().Get("Link"); link != `</v1/rooms/42>; rel="successor-version"` {
+		t.Fatalf("unexpected link header: %s", link)
+	}
+
+	routes := v1.Routes()
+	if len(routes) != 1 || routes[0].String() != "GET /v1/rooms/{id}" || routes[0].Key() != "GET /rooms/{id}" {
+		t.Fatalf("unexpected routes of the group: %v", routes)
+	}
+}
+
+func TestDecodeJson(t *testing.T) {
+	for body, ok := range map[string]bool{
+		`{"name":"latte"}`:              true,
+		`{"name":""}`:                   false,
+		`{"name":"latte","size":"big"}`: false,
+		``:                              false,
+	} {
+		var v testBody
+		err := DecodeJson(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), &v)
+		if (err == nil) != ok {
+			t.Fatalf("decode %q: unexpected error: %v", body, err)
+		}
+	}
+}
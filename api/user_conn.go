@@ -2,10 +2,13 @@ package api
 
 import (
 	"context"
-	"log"
+	"sync"
+	"time"
 
 	"github.com/TheChosenGay/coffee/internal"
+	"github.com/TheChosenGay/coffee/internal/metrics"
 	"github.com/TheChosenGay/coffee/internal/ws"
+	"github.com/TheChosenGay/coffee/proto/chat_service"
 	"github.com/TheChosenGay/coffee/service/chat"
 	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/sirupsen/logrus"
@@ -45,10 +48,18 @@ func NewUserConnServer(opts WsServerOpts) *UserConnServer {
 }
 
 func (s *UserConnServer) Run() error {
-	log.Println("starting user conn server on port %s", s.opts.ListenAddr)
+	logger.Infof("starting user conn server on %s", s.opts.ListenAddr)
 	return s.transport.ListenAndServe()
 }
 
+// Ready is a health check of the listener of the server.
+func (s *UserConnServer) Ready(ctx context.Context) error {
+	if !s.transport.Listening() {
+		return errNotListening
+	}
+	return nil
+}
+
 func (s *UserConnServer) Close() error {
 	for _, user := range s.onlineUserSrv.GetOnlineUsers() {
 		user.Conn.Close()
@@ -56,10 +67,46 @@ func (s *UserConnServer) Close() error {
 	return nil
 }
 
+// Shutdown stops accepting new connections, tells every online user that the server
+// is going down and closes their connections.
+func (s *UserConnServer) Shutdown(ctx context.Context) error {
+	if err := s.transport.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	msg := &chat_service.ChatMessage{
+		MessageType: chat_service.MessageType_NOTIFY,
+		NotifyMessage: &chat_service.NotifyMessage{
+			NotifyType: chat_service.NotifyType_SHUTDOWN,
+		},
+	}
+	wg := sync.WaitGroup{}
+	for _, user := range s.onlineUserSrv.GetOnlineUsers() {
+		wg.Add(1)
+		go func(user *chat.OnlineUser) {
+			defer wg.Done()
+			if err := user.SendMsg(msg); err != nil {
+				logger.WithError(err).Warnf("failed to notify user %d of shutdown", user.UserId)
+			}
+		}(user)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return s.Close()
+}
+
 func (s *UserConnServer) onRecvConn(conn internal.Conn) {
 	if user, err := s.onlineUserSrv.GetOnlineUser(context.Background(), conn.UserId()); err != nil {
 		if user != nil && user.Conn.RemoteAddr() == conn.RemoteAddr() {
-			logrus.Warnf("user %d is already online, closing old connection", conn.UserId())
+			logger.Warnf("user %d is already online, closing old connection", conn.UserId())
 			conn.Close()
 			return
 		} else {
@@ -67,6 +114,7 @@ func (s *UserConnServer) onRecvConn(conn internal.Conn) {
 		}
 	}
 
+	metrics.WsConnections.WithLabelValues(metrics.ConnOpened).Inc()
 	user, err := s.userStore.GetUser(context.Background(), conn.UserId())
 	if err != nil || !user.IsValid() {
 		conn.Send([]byte("User not found"))
@@ -75,10 +123,11 @@ func (s *UserConnServer) onRecvConn(conn internal.Conn) {
 	}
 
 	onlineUser := chat.OnlineUser{
-		Conn:     conn,
-		UserId:   conn.UserId(),
-		UserName: user.Nickname,
-		ChatSrv:  s.chatService,
+		Conn:        conn,
+		UserId:      conn.UserId(),
+		UserName:    user.Nickname,
+		ChatSrv:     s.chatService,
+		ConnectedAt: time.Now(),
 	}
 	conn.OnRecvMsg(onlineUser.ReceiveMsg)
 	if err := s.onlineUserSrv.OnlineUser(context.Background(), &onlineUser); err != nil {
@@ -86,7 +135,7 @@ func (s *UserConnServer) onRecvConn(conn internal.Conn) {
 		conn.Close()
 		return
 	}
-	logrus.WithFields(logrus.Fields{
+	logger.WithFields(logrus.Fields{
 		"user_name": user.Nickname,
 		"user_id":   conn.UserId(),
 	}).Info("user connected")
@@ -96,11 +145,12 @@ func (s *UserConnServer) clearClosedConn(conn internal.Conn) {
 	userId := conn.UserId()
 	// must be closed.
 	defer conn.Close()
+	metrics.WsConnections.WithLabelValues(metrics.ConnClosed).Inc()
 
 	if err := s.onlineUserSrv.OfflineUser(context.Background(), userId); err != nil {
-		logrus.WithError(err).Error("offline user error")
+		logger.WithError(err).Error("offline user error")
 	}
-	logrus.WithFields(logrus.Fields{
+	logger.WithFields(logrus.Fields{
 		"user_id": userId,
 	}).Info("user disconnected")
 }
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Version of the json api, the routes of a version are served under /<version>/ and
+// keep their behavior as long as the version is served. Breaking changes go to a new version.
+type Version string
+
+const (
+	V1 Version = "v1"
+	// V2 encodes ids as strings, snowflake ids do not fit the numbers of javascript.
+	V2 Version = "v2"
+)
+
+// Versions are the served versions of the json api, from the oldest.
+var Versions = []Version{V1, V2}
+
+// Prefix returns the path prefix of the routes of v, e.g. /v1.
+func (v Version) Prefix() string {
+	return "/" + string(v)
+}
+
+// Deprecation of a route, announced by the Deprecation (RFC 9745), Sunset (RFC 8594)
+// and Link headers of its responses.
+type Deprecation struct {
+	// Since is when the route was deprecated.
+	Since time.Time
+	// Sunset is when the route will be removed, zero until it is planned.
+	Sunset time.Time
+	// Successor is the path of the route replacing the deprecated one, path parameters
+	// like {id} are filled from the request. Empty when there is none.
+	Successor string
+}
+
+// legacyDeprecation deprecates the unversioned routes, which are kept as aliases of v1
+// for the clients deployed before the api was versioned.
+var legacyDeprecation = Deprecation{
+	Since:     time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+	Successor: V1.Prefix(),
+}
+
+// announce sets the deprecation headers of the response to a request of route.
+func (d *Deprecation) announce(w http.ResponseWriter, r *http.Request, route string) {
+	metrics.DeprecatedRequests.WithLabelValues(route).Inc()
+	w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	if !d.Sunset.IsZero() {
+		w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		successor := pathParamRegexp.ReplaceAllStringFunc(d.Successor, func(param string) string {
+			return r.PathValue(pathParamRegexp.FindStringSubmatch(param)[1])
+		})
+		w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+	}
+}
+
+// legacyGatewayPrefix is the prefix of the gateway routes before they were versioned,
+// they are kept as additional bindings of the v1 routes in the protos.
+const legacyGatewayPrefix = "/api/"
+
+// announceLegacyGateway sets the deprecation headers for requests of unversioned gateway routes.
+func announceLegacyGateway(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.Path, legacyGatewayPrefix)
+	if !ok {
+		return
+	}
+	if segment, _, _ := strings.Cut(rest, "/"); isVersion(segment) {
+		return
+	}
+	d := legacyDeprecation
+	d.Successor = legacyGatewayPrefix + string(V1) + "/" + rest
+	d.announce(w, r, r.Method+" "+legacyGatewayPrefix)
+}
+
+// isVersion tells whether a path segment names a version, e.g. v1.
+func isVersion(segment string) bool {
+	number, ok := strings.CutPrefix(segment, "v")
+	_, err := strconv.Atoi(number)
+	return ok && err == nil
+}
+
+// RegisterLegacyService registers impl a second time under name, the name of the service
+// of desc before its proto package was versioned, so that deployed clients keep working.
+func RegisterLegacyService(server grpc.ServiceRegistrar, desc *grpc.ServiceDesc, name string, impl any) {
+	legacy := *desc
+	legacy.ServiceName = name
+	server.RegisterService(&legacy, impl)
+}
+
+// deprecationUnaryInterceptor tells clients calling a legacy service name that it is
+// deprecated. The generated handlers report the versioned method in info, so for a
+// legacy name it differs from the method called. Streams report the method called, the
+// versioned services have no streaming methods yet.
+func deprecationUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	announceLegacyMethod(ctx, info.FullMethod)
+	return handler(ctx, req)
+}
+
+func announceLegacyMethod(ctx context.Context, fullMethod string) {
+	called, ok := grpc.Method(ctx)
+	if !ok || called == fullMethod {
+		return
+	}
+	metrics.DeprecatedRequests.WithLabelValues(called).Inc()
+	grpc.SetHeader(ctx, metadata.Pairs(
+		"deprecation", "@"+strconv.FormatInt(legacyDeprecation.Since.Unix(), 10),
+		"link", "<"+fullMethod+`>; rel="successor-version"`,
+	))
+}
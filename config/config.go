@@ -0,0 +1,516 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/internal/tracing"
+	"github.com/TheChosenGay/coffee/service"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DriverMySql  = "mysql"
+	DriverSqlite = "sqlite"
+	// DriverMemory keeps the records in memory, they are lost on restart
+	DriverMemory = "memory"
+
+	IdGeneratorDatabase  = "database"
+	IdGeneratorSnowflake = "snowflake"
+
+	RateLimitBackendMemory = "memory"
+	RateLimitBackendRedis  = "redis"
+)
+
+// Config of the coffee server. Values are resolved in order: defaults, config file,
+// environment variables and finally command line flags.
+//
+// Every field can be set by an environment variable named after its yaml path,
+// e.g. database.mysql.password is read from COFFEE_DATABASE_MYSQL_PASSWORD, maps of
+// strings as name=value pairs, e.g. COFFEE_GRPC_AUTH_TOKENS=frontend=s3cret. Loading
+// fails on a variable of the other fields, e.g. rate_limit.routes.
+type Config struct {
+	Json      JsonConfig       `yaml:"json"`
+	Grpc      GrpcConfig       `yaml:"grpc"`
+	Ws        ServerConfig     `yaml:"ws"`
+	Chat      ChatConfig       `yaml:"chat"`
+	Admin     AdminConfig      `yaml:"admin"`
+	Database  DatabaseConfig   `yaml:"database"`
+	Redis     RedisConfig      `yaml:"redis"`
+	Id        IdConfig         `yaml:"id"`
+	Tracing   TracingConfig    `yaml:"tracing"`
+	Log       LogConfig        `yaml:"log"`
+	RateLimit RateLimitsConfig `yaml:"rate_limit"`
+
+	// ShutdownTimeout bounds how long in-flight requests are drained on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+type JsonConfig struct {
+	Addr string `yaml:"addr"`
+	// V1 serves the v1 routes and their unversioned aliases, they send ids as numbers
+	V1 bool `yaml:"v1"`
+}
+
+type GrpcConfig struct {
+	Addr      string          `yaml:"addr"`
+	TLS       TLSConfig       `yaml:"tls"`
+	Auth      AuthConfig      `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Keepalive KeepaliveConfig `yaml:"keepalive"`
+}
+
+// TLSConfig enables tls when cert_file is set, and mutual tls when client_ca_file is set too.
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type AuthConfig struct {
+	// Tokens maps client names to the bearer tokens they authenticate with,
+	// authentication is disabled when it is empty.
+	Tokens map[string]string `yaml:"tokens"`
+}
+
+type ChatConfig struct {
+	// ContactsOnly restricts the direct messages to the contacts of the sender, messages
+	// of blocked users are rejected either way
+	ContactsOnly bool `yaml:"contacts_only"`
+}
+
+// AdminConfig of the admin api of the operators, it is only served when there is a token.
+type AdminConfig struct {
+	// Addr should not be reachable from the public network
+	Addr string `yaml:"addr"`
+	// Auth maps operator names to their tokens, the names are recorded by the audit log
+	Auth AuthConfig `yaml:"auth"`
+}
+
+func (c AdminConfig) Enabled() bool {
+	return len(c.Auth.Tokens) > 0
+}
+
+type RateLimitConfig struct {
+	// Rate is the number of requests per second allowed per client (or user, room...),
+	// 0 disables the limit
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type RateLimitsConfig struct {
+	// Backend keeps the token buckets in "memory", per process, or in "redis", shared by
+	// all the nodes. The redis backend requires redis to be enabled.
+	Backend string `yaml:"backend"`
+	// IP limits the json requests per client ip
+	IP RateLimitConfig `yaml:"ip"`
+	// Routes limits single json routes per client ip, keyed by "METHOD pattern" without the
+	// version prefix, e.g. "POST /users", the limit holds for the route of every version
+	Routes map[string]RateLimitConfig `yaml:"routes"`
+	// ChatUser limits the chat messages per sender, ChatRoom the messages per room
+	ChatUser RateLimitConfig `yaml:"chat_user"`
+	ChatRoom RateLimitConfig `yaml:"chat_room"`
+}
+
+func (c RateLimitConfig) validate(path string) error {
+	if c.Rate < 0 {
+		return fmt.Errorf("%s.rate must not be negative", path)
+	}
+	if c.Rate > 0 && c.Burst <= 0 {
+		return fmt.Errorf("%s.burst must be positive", path)
+	}
+	return nil
+}
+
+type KeepaliveConfig struct {
+	// Time after which an idle connection is pinged, and Timeout to wait for the ack
+	Time    time.Duration `yaml:"time"`
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxConnectionIdle and MaxConnectionAge close connections gracefully, 0 means infinity
+	MaxConnectionIdle time.Duration `yaml:"max_connection_idle"`
+	MaxConnectionAge  time.Duration `yaml:"max_connection_age"`
+	// MinTime is the minimum interval clients may ping at, faster clients are disconnected
+	MinTime             time.Duration `yaml:"min_time"`
+	PermitWithoutStream bool          `yaml:"permit_without_stream"`
+}
+
+type DatabaseConfig struct {
+	// Driver is "mysql", "sqlite" or "memory" for tests and local development
+	Driver string       `yaml:"driver"`
+	MySql  MySqlConfig  `yaml:"mysql"`
+	Sqlite SqliteConfig `yaml:"sqlite"`
+	// AutoMigrate applies the pending migrations at startup, otherwise they are applied
+	// by `coffee migrate up` and the server refuses to start until they are
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type MySqlConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Protocol string `yaml:"protocol"`
+	Addr     string `yaml:"addr"`
+	DBName   string `yaml:"db_name"`
+}
+
+type SqliteConfig struct {
+	Path string `yaml:"path"`
+}
+
+type RedisConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+	// RoomTTL bounds how long a room stays cached
+	RoomTTL time.Duration `yaml:"room_ttl"`
+	// UserTTL bounds how long a user stays cached
+	UserTTL time.Duration `yaml:"user_ttl"`
+	// UserMissTTL bounds how long a missing user is cached as missing
+	UserMissTTL time.Duration `yaml:"user_miss_ttl"`
+}
+
+type IdConfig struct {
+	// Generator is either "database" (hi/lo blocks, a counter of the memory driver) or "snowflake"
+	Generator string `yaml:"generator"`
+	// NodeId must be unique per process when using snowflake ids
+	NodeId int64 `yaml:"node_id"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of none, stdout (local development) or otlp
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the host:port of the otlp grpc collector
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+	// Format is either json or text
+	Format string `yaml:"format"`
+	// Packages overrides the level by package name, e.g. {chat: debug}
+	Packages map[string]string `yaml:"packages"`
+}
+
+func Default() Config {
+	return Config{
+		Json: JsonConfig{Addr: ":8080", V1: true},
+		Grpc: GrpcConfig{
+			Addr: ":50051",
+			Keepalive: KeepaliveConfig{
+				Time:                time.Minute,
+				Timeout:             20 * time.Second,
+				MinTime:             10 * time.Second,
+				PermitWithoutStream: true,
+			},
+		},
+		Ws:    ServerConfig{Addr: ":8081"},
+		Admin: AdminConfig{Addr: "127.0.0.1:8090"},
+		Database: DatabaseConfig{
+			Driver: DriverMySql,
+			MySql: MySqlConfig{
+				Username: "root",
+				Protocol: "tcp",
+				Addr:     "127.0.0.1:3306",
+				DBName:   "coffee",
+			},
+			Sqlite: SqliteConfig{Path: "coffee.db"},
+		},
+		Redis: RedisConfig{Enabled: true, Addr: "127.0.0.1:6379", RoomTTL: 10 * time.Minute, UserTTL: 10 * time.Minute, UserMissTTL: 30 * time.Second},
+		RateLimit: RateLimitsConfig{
+			Backend: RateLimitBackendMemory,
+			IP:      RateLimitConfig{Rate: 20, Burst: 40},
+			Routes: map[string]RateLimitConfig{
+				// registrations are rare, a client registering users in a loop is abusing
+				"POST /users": {Rate: 0.1, Burst: 5},
+			},
+			ChatUser: RateLimitConfig{Rate: 5, Burst: 10},
+			ChatRoom: RateLimitConfig{Rate: 50, Burst: 100},
+		},
+		Id:  IdConfig{Generator: IdGeneratorDatabase},
+		Log: LogConfig{Level: "info", Format: logging.FormatJson},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			Endpoint:    "127.0.0.1:4317",
+			Insecure:    true,
+			SampleRatio: 1,
+		},
+
+		ShutdownTimeout: 15 * time.Second,
+	}
+}
+
+// Load resolves the configuration from the command line arguments (without the program name).
+func Load(args []string) (Config, error) {
+	cfg, _, err := LoadArgs(args)
+	return cfg, err
+}
+
+// LoadArgs resolves the configuration like Load and returns the arguments after the flags,
+// e.g. the arguments of a subcommand.
+func LoadArgs(args []string) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("coffee", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("COFFEE_CONFIG"), "path of the yaml config file")
+	jsonAddr := fs.String("json-addr", "", "listen address of the json server")
+	grpcAddr := fs.String("grpc-addr", "", "listen address of the grpc server")
+	wsAddr := fs.String("ws-addr", "", "listen address of the websocket server")
+	dbDriver := fs.String("db-driver", "", "database driver, mysql, sqlite or memory")
+	noRedis := fs.Bool("no-redis", false, "disable the redis user and room caches")
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return cfg, nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem(), "COFFEE"); err != nil {
+		return cfg, nil, err
+	}
+
+	// flags override everything, only when they are set explicitly
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "json-addr":
+			cfg.Json.Addr = *jsonAddr
+		case "grpc-addr":
+			cfg.Grpc.Addr = *grpcAddr
+		case "ws-addr":
+			cfg.Ws.Addr = *wsAddr
+		case "db-driver":
+			cfg.Database.Driver = *dbDriver
+		case "no-redis":
+			cfg.Redis.Enabled = !*noRedis
+		}
+	})
+
+	return cfg, fs.Args(), cfg.Validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	for name, addr := range map[string]string{"json": c.Json.Addr, "grpc": c.Grpc.Addr, "ws": c.Ws.Addr} {
+		if addr == "" {
+			errs = append(errs, fmt.Errorf("%s.addr is required", name))
+		}
+	}
+
+	if tls := c.Grpc.TLS; (tls.CertFile == "") != (tls.KeyFile == "") {
+		errs = append(errs, errors.New("grpc.tls requires both cert_file and key_file"))
+	} else if tls.ClientCAFile != "" && !tls.Enabled() {
+		errs = append(errs, errors.New("grpc.tls.client_ca_file requires cert_file and key_file"))
+	}
+	for name, token := range c.Grpc.Auth.Tokens {
+		if token == "" {
+			errs = append(errs, fmt.Errorf("grpc.auth.tokens.%s is empty", name))
+		}
+	}
+	errs = append(errs, c.Grpc.RateLimit.validate("grpc.rate_limit"))
+	if c.Admin.Enabled() && c.Admin.Addr == "" {
+		errs = append(errs, errors.New("admin.addr is required when admin.auth has tokens"))
+	}
+	for name, token := range c.Admin.Auth.Tokens {
+		if token == "" {
+			errs = append(errs, fmt.Errorf("admin.auth.tokens.%s is empty", name))
+		}
+	}
+	if c.Grpc.Keepalive.Time <= 0 || c.Grpc.Keepalive.Timeout <= 0 {
+		errs = append(errs, errors.New("grpc.keepalive.time and timeout must be positive"))
+	}
+
+	switch c.Database.Driver {
+	case DriverMySql:
+		if c.Database.MySql.Username == "" || c.Database.MySql.Addr == "" || c.Database.MySql.DBName == "" {
+			errs = append(errs, errors.New("database.mysql requires username, addr and db_name"))
+		}
+	case DriverSqlite:
+		if c.Database.Sqlite.Path == "" {
+			errs = append(errs, errors.New("database.sqlite.path is required"))
+		}
+	case DriverMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unknown database driver: %q", c.Database.Driver))
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+
+	if c.Redis.Enabled && c.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required when redis is enabled"))
+	}
+	if c.Redis.RoomTTL <= 0 {
+		errs = append(errs, errors.New("redis.room_ttl must be positive"))
+	}
+	if c.Redis.UserTTL <= 0 {
+		errs = append(errs, errors.New("redis.user_ttl must be positive"))
+	}
+	if c.Redis.UserMissTTL <= 0 {
+		errs = append(errs, errors.New("redis.user_miss_ttl must be positive"))
+	}
+
+	switch c.RateLimit.Backend {
+	case RateLimitBackendMemory:
+	case RateLimitBackendRedis:
+		if !c.Redis.Enabled {
+			errs = append(errs, errors.New("rate_limit.backend redis requires redis to be enabled"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown rate limit backend: %q", c.RateLimit.Backend))
+	}
+	errs = append(errs,
+		c.RateLimit.IP.validate("rate_limit.ip"),
+		c.RateLimit.ChatUser.validate("rate_limit.chat_user"),
+		c.RateLimit.ChatRoom.validate("rate_limit.chat_room"),
+	)
+	for route, limit := range c.RateLimit.Routes {
+		if method, pattern, ok := strings.Cut(route, " "); !ok || method == "" || !strings.HasPrefix(pattern, "/") {
+			errs = append(errs, fmt.Errorf("rate_limit.routes: %q is not a \"METHOD pattern\" route", route))
+		}
+		errs = append(errs, limit.validate("rate_limit.routes."+route))
+	}
+
+	switch c.Id.Generator {
+	case IdGeneratorDatabase:
+	case IdGeneratorSnowflake:
+		// javascript numbers are exact up to 2^53, snowflake ids are above it
+		if c.Json.V1 {
+			errs = append(errs, errors.New("id.generator snowflake requires json.v1 to be false, v1 sends ids as numbers"))
+		}
+		if c.Id.NodeId < 0 || c.Id.NodeId > service.MaxSnowflakeNodeId {
+			errs = append(errs, fmt.Errorf("id.node_id must be in [0, %d]", service.MaxSnowflakeNodeId))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown id generator: %q", c.Id.Generator))
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOtlp:
+		if c.Tracing.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint is required by the otlp exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown trace exporter: %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be in [0, 1]"))
+	}
+	return errors.Join(errs...)
+}
+
+// applyEnv overrides the fields of v by the environment variables named prefix_<YAML_NAME>.
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, key); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			field.SetBool(b)
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			field.SetFloat(f)
+		case reflect.Int, reflect.Int64:
+			if field.Type() == reflect.TypeOf(time.Duration(0)) {
+				d, err := time.ParseDuration(value)
+				if err != nil {
+					return fmt.Errorf("invalid %s: %w", key, err)
+				}
+				field.SetInt(int64(d))
+				continue
+			}
+			n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			field.SetInt(n)
+		case reflect.Map:
+			if field.Type() != reflect.TypeOf(map[string]string(nil)) {
+				return fmt.Errorf("%s cannot be set by the environment, set it in the config file", key)
+			}
+			m, err := parseEnvMap(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			field.Set(reflect.ValueOf(m))
+		default:
+			return fmt.Errorf("%s cannot be set by the environment, set it in the config file", key)
+		}
+	}
+	return nil
+}
+
+// parseEnvMap parses the map of an environment variable, written as name=value pairs
+// separated by commas, e.g. "alice=s3cret,bob=t0ken". The whole map of the config file
+// is replaced.
+func parseEnvMap(value string) (map[string]string, error) {
+	m := map[string]string{}
+	if strings.TrimSpace(value) == "" {
+		return m, nil
+	}
+	for pair := range strings.SplitSeq(value, ",") {
+		name, v, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q is not a name=value pair", pair)
+		}
+		if _, ok := m[name]; ok {
+			return nil, fmt.Errorf("duplicate name %q", name)
+		}
+		m[name] = strings.TrimSpace(v)
+	}
+	return m, nil
+}
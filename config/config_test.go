@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "json:\n  addr: \":9000\"\ngrpc:\n  addr: \":9001\"\ndatabase:\n  driver: sqlite\n  sqlite:\n    path: test.db\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("COFFEE_GRPC_ADDR", ":9101")
+	t.Setenv("COFFEE_REDIS_ENABLED", "false")
+
+	cfg, err := Load([]string{"-config", path, "-ws-addr", ":9202"})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Json.Addr != ":9000" {
+		t.Fatalf("json addr from file not applied: %s", cfg.Json.Addr)
+	}
+	if cfg.Grpc.Addr != ":9101" {
+		t.Fatalf("grpc addr from env not applied: %s", cfg.Grpc.Addr)
+	}
+	if cfg.Ws.Addr != ":9202" {
+		t.Fatalf("ws addr from flag not applied: %s", cfg.Ws.Addr)
+	}
+	if cfg.Database.Driver != DriverSqlite || cfg.Redis.Enabled {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Driver = "postgres"
+	cfg.Id.Generator = IdGeneratorSnowflake
+	cfg.Id.NodeId = 4096
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("invalid config passed validation")
+	}
+
+	cfg = Default()
+	cfg.Grpc.TLS.CertFile = "server.pem"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("tls without key passed validation")
+	}
+	cfg = Default()
+	cfg.Grpc.RateLimit.Rate = 10
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("rate limit without burst passed validation")
+	}
+	cfg = Default()
+	cfg.Redis.Enabled = false
+	cfg.RateLimit.Backend = RateLimitBackendRedis
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("redis rate limit without redis passed validation")
+	}
+	cfg = Default()
+	cfg.RateLimit.Routes = map[string]RateLimitConfig{"/users": {Rate: 1, Burst: 1}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("rate limit of a route without method passed validation")
+	}
+	cfg = Default()
+	cfg.Admin.Auth.Tokens = map[string]string{"alice": ""}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("empty admin token passed validation")
+	}
+	cfg = Default()
+	cfg.Id.Generator = IdGeneratorSnowflake
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("snowflake ids with api v1 passed validation")
+	}
+	cfg.Json.V1 = false
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("snowflake ids without api v1 failed validation: %v", err)
+	}
+}
+
+func TestLoadEnvMaps(t *testing.T) {
+	t.Setenv("COFFEE_GRPC_AUTH_TOKENS", "frontend=s3cret, batch=a=b")
+	t.Setenv("COFFEE_LOG_PACKAGES", "chat=debug")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(cfg.Grpc.Auth.Tokens) != 2 || cfg.Grpc.Auth.Tokens["frontend"] != "s3cret" || cfg.Grpc.Auth.Tokens["batch"] != "a=b" {
+		t.Fatalf("grpc tokens from env not applied: %v", cfg.Grpc.Auth.Tokens)
+	}
+	if len(cfg.Log.Packages) != 1 || cfg.Log.Packages["chat"] != "debug" {
+		t.Fatalf("log packages from env not applied: %v", cfg.Log.Packages)
+	}
+
+	t.Setenv("COFFEE_ADMIN_AUTH_TOKENS", "alice")
+	if _, err := Load(nil); err == nil {
+		t.Fatalf("token without name=value passed")
+	}
+}
+
+func TestLoadEnvUnsupportedField(t *testing.T) {
+	t.Setenv("COFFEE_RATE_LIMIT_ROUTES", "POST /users=1")
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "COFFEE_RATE_LIMIT_ROUTES") {
+		t.Fatalf("rate limit routes from env did not fail: %v", err)
+	}
+}
@@ -1,4 +1,4 @@
-const BASE_URL = 'http://localhost:8080';
+const BASE_URL = 'http://localhost:8080/v1';
 
 export interface Room {
   room_id: number;
@@ -11,7 +11,9 @@ export interface CreateRoomResponse {
 }
 
 export interface ErrorResponse {
-  error: string;
+  code: string;
+  message: string;
+  request_id?: string;
 }
 
 export interface User {
@@ -43,9 +45,16 @@ export interface QuitRoomResponse {
   message: string;
 }
 
+function jsonBody(body: unknown): RequestInit {
+  return {
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body),
+  };
+}
+
 export class RoomAPI {
   async listRooms(): Promise<Room[]> {
-    const res = await fetch(`${BASE_URL}/room/list`);
+    const res = await fetch(`${BASE_URL}/rooms`);
     if (!res.ok) {
       throw new Error(`HTTP error! status: ${res.status}`);
     }
@@ -53,10 +62,10 @@ export class RoomAPI {
   }
 
   async createRoom(maxUnitSize: number): Promise<CreateRoomResponse> {
-    const url = `${BASE_URL}/room/create?max_unit_size=${maxUnitSize}`;
+    const url = `${BASE_URL}/rooms`;
     console.log('Sending request to:', url);
     
-    const res = await fetch(url);
+    const res = await fetch(url, { method: 'POST', ...jsonBody({ max_unit_size: maxUnitSize }) });
     console.log('Response status:', res.status);
     
     const data = await res.json();
@@ -64,46 +73,46 @@ export class RoomAPI {
     
     if (!res.ok) {
       const error = data as ErrorResponse;
-      throw new Error(error.error || 'Failed to create room');
+      throw new Error(error.message || 'Failed to create room');
     }
     
     return data as CreateRoomResponse;
   }
 
   async deleteRoom(roomId: number): Promise<void> {
-    const res = await fetch(`${BASE_URL}/room/delete?room_id=${roomId}`);
+    const res = await fetch(`${BASE_URL}/rooms/${roomId}`, { method: 'DELETE' });
     if (!res.ok) {
       const data = await res.json();
-      throw new Error((data as ErrorResponse).error || 'Failed to delete room');
+      throw new Error((data as ErrorResponse).message || 'Failed to delete room');
     }
   }
 
   async joinRoom(roomId: number, userId: number): Promise<JoinRoomResponse> {
-    const res = await fetch(`${BASE_URL}/room/join?room_id=${roomId}&user_id=${userId}`);
+    const res = await fetch(`${BASE_URL}/rooms/${roomId}/units`, { method: 'POST', ...jsonBody({ user_id: userId }) });
     const data = await res.json();
     if (!res.ok) {
       const error = data as ErrorResponse;
-      throw new Error(error.error || 'Failed to join room');
+      throw new Error(error.message || 'Failed to join room');
     }
     return data as JoinRoomResponse;
   }
 
   async quitRoom(roomId: number, userId: number): Promise<QuitRoomResponse> {
-    const res = await fetch(`${BASE_URL}/room/quit?room_id=${roomId}&user_id=${userId}`);
+    const res = await fetch(`${BASE_URL}/rooms/${roomId}/units/${userId}`, { method: 'DELETE' });
     const data = await res.json();
     if (!res.ok) {
       const error = data as ErrorResponse;
-      throw new Error(error.error || 'Failed to quit room');
+      throw new Error(error.message || 'Failed to quit room');
     }
     return data as QuitRoomResponse;
   }
 
   async getRoomUnits(roomId: number): Promise<RoomUnit[]> {
-    const res = await fetch(`${BASE_URL}/room/get_units?room_id=${roomId}`);
+    const res = await fetch(`${BASE_URL}/rooms/${roomId}/units`);
     const data = await res.json();
     if (!res.ok) {
       const error = data as ErrorResponse;
-      throw new Error(error.error || 'Failed to get room units');
+      throw new Error(error.message || 'Failed to get room units');
     }
     const response = data as GetRoomUnitsResponse;
     return response.units || [];
@@ -112,22 +121,22 @@ export class RoomAPI {
 
 export class UserAPI {
   async registerUser(nickname: string, sex: number): Promise<RegisterUserResponse> {
-    const url = `${BASE_URL}/user/register?nickname=${encodeURIComponent(nickname)}&sex=${sex}`;
-    console.log('Registering user:', url);
+    const url = `${BASE_URL}/users`;
+    console.log('Registering user:', nickname);
     
-    const res = await fetch(url);
+    const res = await fetch(url, { method: 'POST', ...jsonBody({ nickname, sex }) });
     const data = await res.json();
     
     if (!res.ok) {
       const error = data as ErrorResponse;
-      throw new Error(error.error || 'Failed to register user');
+      throw new Error(error.message || 'Failed to register user');
     }
     
     return data as RegisterUserResponse;
   }
 
   async listUsers(): Promise<User[]> {
-    const res = await fetch(`${BASE_URL}/user/list`);
+    const res = await fetch(`${BASE_URL}/users`);
     if (!res.ok) {
       throw new Error(`HTTP error! status: ${res.status}`);
     }
@@ -135,18 +144,18 @@ export class UserAPI {
   }
 
   async deleteUser(userId: number): Promise<void> {
-    const res = await fetch(`${BASE_URL}/user/delete?user_id=${userId}`);
+    const res = await fetch(`${BASE_URL}/users/${userId}`, { method: 'DELETE' });
     if (!res.ok) {
       const data = await res.json();
-      throw new Error((data as ErrorResponse).error || 'Failed to delete user');
+      throw new Error((data as ErrorResponse).message || 'Failed to delete user');
     }
   }
 
   async getUserById(userId: number): Promise<User> {
-    const res = await fetch(`${BASE_URL}/user/get?id=${userId}`);
+    const res = await fetch(`${BASE_URL}/users/${userId}`);
     if (!res.ok) {
       const data = await res.json();
-      throw new Error((data as ErrorResponse).error || 'Failed to get user');
+      throw new Error((data as ErrorResponse).message || 'Failed to get user');
     }
     return res.json();
   }
@@ -11,25 +11,38 @@ message Content {
 enum MessageType {
   NORMAL = 0;
   NOTIFY = 1;
+  ERROR = 2;
 }
 
 enum NotifyType {
   QUIT = 0;
   JOIN = 1;
+  SHUTDOWN = 2;
+  KICKED = 3;
+  ANNOUNCEMENT = 4;
+  CONTACT_REQUEST = 5;
+  CONTACT_ACCEPTED = 6;
 }
 
 message NotifyMessage {
   NotifyType notify_type = 1;
-  int32 operator_id = 2;
+  int64 operator_id = 2;
+}
+
+message ErrorMessage {
+  string code = 1;
+  string message = 2;
+  int64 retry_after_ms = 3;
 }
 
 message ChatMessage {
-  int32 sender_id = 1;
-  int32 target_id = 2;
+  int64 sender_id = 1;
+  int64 target_id = 2;
   bool is_user = 3;
   repeated Content contents = 4;
   MessageType message_type = 5;
   NotifyMessage notify_message = 6;
+  ErrorMessage error_message = 7;
 }
 `;
 
@@ -52,11 +65,17 @@ export interface ChatMessageData {
   target_id: number;
   is_user: boolean;
   contents: Array<{ content: string[] }>;
-  message_type?: number; // MessageType: 0 = NORMAL, 1 = NOTIFY
+  message_type?: number; // MessageType: 0 = NORMAL, 1 = NOTIFY, 2 = ERROR
   notify_message?: {
-    notify_type?: number; // NotifyType: 0 = QUIT, 1 = JOIN
+    notify_type?: number; // NotifyType: 0 = QUIT, 1 = JOIN, 2 = SHUTDOWN, 3 = KICKED, 4 = ANNOUNCEMENT, 5 = CONTACT_REQUEST, 6 = CONTACT_ACCEPTED
     operator_id?: number;
   };
+  // why a message sent by this client was not delivered, only set when message_type is ERROR
+  error_message?: {
+    code: string;
+    message: string;
+    retry_after_ms?: number;
+  };
 }
 
 export class ChatClient {
@@ -326,6 +345,16 @@ export class ChatClient {
         console.log('✅ 解析 notify_message:', notifyMessage);
       }
       
+      let errorMessage: ChatMessageData['error_message'] = undefined;
+      const em = message.errorMessage || message.error_message;
+      if (em) {
+        errorMessage = {
+          code: em.code || '',
+          message: em.message || '',
+          retry_after_ms: Number(em.retryAfterMs ?? em.retry_after_ms ?? 0)
+        };
+      }
+
       const data: ChatMessageData = {
         sender_id: senderId,
         target_id: targetId,
@@ -334,7 +363,8 @@ export class ChatClient {
           content: c.content || []
         })),
         message_type: messageType,
-        notify_message: notifyMessage
+        notify_message: notifyMessage,
+        error_message: errorMessage
       };
       
       console.log('✅ Protobuf 消息解析成功');
@@ -1072,6 +1072,15 @@ function createConnectionCard(userId?: number): ConnectionInfo {
     console.log(`   消息总数: ${messageCount}`);
     console.log('');
     
+    // 消息未送达（例如发送过快被限流）
+    if (data.message_type === 2 && data.error_message) { // ERROR
+      const { code, message, retry_after_ms } = data.error_message;
+      console.warn(`⚠️ 消息发送失败: ${code} ${message}`);
+      const retry = retry_after_ms ? `，请 ${Math.ceil(retry_after_ms / 1000)} 秒后重试` : '';
+      alert(`消息发送失败: ${message}${retry}`);
+      return;
+    }
+
     const targetIdNum = Number(data.target_id) || 0;
     const currentUserIdNum = Number(currentUserId) || 0;
     
@@ -1135,6 +1144,23 @@ function createConnectionCard(userId?: number): ConnectionInfo {
             // 只更新消息显示，不重新渲染整个页面
             updateRoomMessagesDisplay(roomId);
             
+            return;
+          } else if (notifyMsg.notify_type === 4) { // ANNOUNCEMENT
+            const text = contents.flatMap((content) => content.content || []).join('\n');
+            console.log(`📢 收到系统公告: 房间 #${roomId}`, text);
+            
+            if (!roomMessages.has(roomId)) {
+              roomMessages.set(roomId, []);
+            }
+            roomMessages.get(roomId)!.push({
+              userId: 0,
+              nickname: `系统公告`,
+              message: text,
+              time: new Date()
+            });
+            
+            updateRoomMessagesDisplay(roomId);
+            
             return;
           }
         }
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatusOk is the status of a passing check.
+const StatusOk = "ok"
+
+// ErrShuttingDown is reported once the process started shutting down.
+var ErrShuttingDown = errors.New("shutting down")
+
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Report is the result of running all checks, Checks maps the name of every check to
+// StatusOk or the error it failed with.
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Checker tells whether the process is ready to serve, that is all the dependencies and
+// listeners it checks are up and it is not shutting down.
+type Checker struct {
+	timeout time.Duration
+
+	mx     sync.RWMutex
+	checks []check
+
+	shuttingDown atomic.Bool
+}
+
+// NewChecker returns a checker whose checks fail when they take longer than timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check, it is run by every call of Check.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Shutdown marks the process not ready, so load balancers stop sending it new requests
+// while the servers drain. It is meant to be the first stop hook.
+func (c *Checker) Shutdown(ctx context.Context) error {
+	c.shuttingDown.Store(true)
+	return nil
+}
+
+// Check runs the checks concurrently and reports their results.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mx.RLock()
+	checks := c.checks
+	c.mx.RUnlock()
+
+	report := Report{Ready: true, Checks: make(map[string]string, len(checks)+1)}
+	if c.shuttingDown.Load() {
+		report.Ready = false
+		report.Checks["lifecycle"] = ErrShuttingDown.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = run(ctx, check.fn)
+		}()
+	}
+	wg.Wait()
+
+	for i, check := range checks {
+		if errs[i] != nil {
+			report.Ready = false
+			report.Checks[check.name] = errs[i].Error()
+			continue
+		}
+		report.Checks[check.name] = StatusOk
+	}
+	return report
+}
+
+// run returns the error of fn, or the error of ctx when fn does not return in time.
+func run(ctx context.Context, fn CheckFunc) error {
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
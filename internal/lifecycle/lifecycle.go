@@ -0,0 +1,106 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logging.Package("lifecycle")
+
+type StopFunc func(ctx context.Context) error
+
+type hook struct {
+	name string
+	stop StopFunc
+}
+
+// Manager runs the servers of the process and stops everything in reverse order of
+// registration when the process receives SIGINT/SIGTERM or one of the servers fails.
+type Manager struct {
+	timeout time.Duration
+
+	mx    sync.Mutex
+	hooks []hook
+
+	errCh chan error
+}
+
+func NewManager(timeout time.Duration) *Manager {
+	return &Manager{
+		timeout: timeout,
+		errCh:   make(chan error, 1),
+	}
+}
+
+// OnStop registers a stop hook. Hooks registered later are stopped first,
+// so servers should be registered after the stores they depend on.
+func (m *Manager) OnStop(name string, stop StopFunc) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Go runs a blocking server. If it returns an error the whole process is shut down.
+func (m *Manager) Go(name string, run func() error) {
+	go func() {
+		if err := run(); err != nil {
+			select {
+			case m.errCh <- fmt.Errorf("%s: %w", name, err):
+			default:
+			}
+		}
+	}()
+}
+
+// Wait blocks until a termination signal is received or a server fails, then runs
+// all stop hooks within the shutdown timeout.
+func (m *Manager) Wait() error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	var runErr error
+	select {
+	case sig := <-sigCh:
+		logger.Infof("received signal %s, shutting down", sig)
+	case runErr = <-m.errCh:
+		logger.WithError(runErr).Error("server failed, shutting down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	return errors.Join(runErr, m.Stop(ctx))
+}
+
+// Stop runs the stop hooks in reverse order of registration.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mx.Lock()
+	hooks := m.hooks
+	m.hooks = nil
+	m.mx.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		start := time.Now()
+		if err := h.stop(ctx); err != nil {
+			logger.WithError(err).Errorf("failed to stop %s", h.name)
+			errs = append(errs, fmt.Errorf("stop %s: %w", h.name, err))
+			continue
+		}
+		logger.WithFields(logrus.Fields{
+			"name":       h.name,
+			"elapsed_ms": time.Since(start).Milliseconds(),
+		}).Info("stopped")
+	}
+	return errors.Join(errs...)
+}
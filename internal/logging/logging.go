@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatJson = "json"
+	FormatText = "text"
+
+	// RequestIdHeader is accepted from clients and echoed in every response.
+	RequestIdHeader = "X-Request-ID"
+)
+
+// RequestId correlates the logs, traces and responses of one request.
+type RequestId string
+
+type requestIdKey struct{}
+
+func NewRequestId() RequestId {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return RequestId(hex.EncodeToString(b))
+}
+
+// ValidRequestId reports whether an id received from a client can be trusted to be logged.
+func ValidRequestId(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func WithRequestId(ctx context.Context, id RequestId) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestIdFrom returns the request id of ctx, or an empty id when ctx is not part of a request.
+func RequestIdFrom(ctx context.Context) RequestId {
+	id, _ := ctx.Value(requestIdKey{}).(RequestId)
+	return id
+}
+
+type Opts struct {
+	Level  string
+	Format string
+	// Packages overrides the level of the loggers created by Package, by package name.
+	Packages map[string]string
+}
+
+// Logger is the logger of one package, its level can be configured on its own.
+type Logger struct {
+	*logrus.Logger
+	pkg string
+}
+
+var (
+	mx       sync.Mutex
+	opts     = Opts{Level: "info", Format: FormatText}
+	packages = map[string]*Logger{}
+)
+
+// Package returns the logger of pkg, loggers are usually kept in a package level variable:
+//
+//	var log = logging.Package("chat")
+func Package(pkg string) *Logger {
+	mx.Lock()
+	defer mx.Unlock()
+	if l, ok := packages[pkg]; ok {
+		return l
+	}
+	l := &Logger{Logger: logrus.New(), pkg: pkg}
+	l.AddHook(pkgHook(pkg))
+	if err := configure(l, opts); err != nil {
+		panic(err)
+	}
+	packages[pkg] = l
+	return l
+}
+
+// Setup applies opts to the standard logrus logger and to every package logger.
+func Setup(o Opts) error {
+	mx.Lock()
+	defer mx.Unlock()
+	if err := configure(&Logger{Logger: logrus.StandardLogger()}, o); err != nil {
+		return err
+	}
+	for _, l := range packages {
+		if err := configure(l, o); err != nil {
+			return err
+		}
+	}
+	opts = o
+	return nil
+}
+
+func configure(l *Logger, o Opts) error {
+	levelName := o.Level
+	if pkgLevel, ok := o.Packages[l.pkg]; ok && l.pkg != "" {
+		levelName = pkgLevel
+	}
+	level, err := logrus.ParseLevel(levelName)
+	if err != nil {
+		return fmt.Errorf("invalid log level of %q: %w", l.pkg, err)
+	}
+	l.SetLevel(level)
+	l.SetOutput(os.Stderr)
+	switch o.Format {
+	case FormatJson:
+		l.SetFormatter(&logrus.JSONFormatter{})
+	case FormatText, "":
+		l.SetFormatter(&logrus.TextFormatter{})
+	default:
+		return fmt.Errorf("unknown log format: %q", o.Format)
+	}
+	return nil
+}
+
+// Ctx returns an entry carrying the request and trace ids of ctx.
+func (l *Logger) Ctx(ctx context.Context) *logrus.Entry {
+	entry := l.WithContext(ctx)
+	if id := RequestIdFrom(ctx); id != "" {
+		entry = entry.WithField("request_id", string(id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		entry = entry.WithField("trace_id", sc.TraceID().String())
+	}
+	return entry
+}
+
+// pkgHook adds the package name to every entry of a package logger.
+type pkgHook string
+
+func (h pkgHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h pkgHook) Fire(entry *logrus.Entry) error {
+	entry.Data["pkg"] = string(h)
+	return nil
+}
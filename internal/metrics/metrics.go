@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "coffee"
+
+// Registry holds every metric of the process, it is exposed by Handler.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// MARK: json over http
+
+var (
+	HttpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of json requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HttpDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of json requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// DeprecatedRequests tells which clients still need to move before a route is removed.
+	DeprecatedRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "deprecated_requests_total",
+		Help:      "Number of requests of deprecated json routes by route.",
+	}, []string{"route"})
+)
+
+// MARK: grpc
+
+var (
+	GrpcRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "Number of grpc calls by full method name and status code.",
+	}, []string{"method", "code"})
+
+	GrpcDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of grpc calls by full method name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+// MARK: chat
+
+var (
+	OnlineUsers = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "chat",
+		Name:      "online_users",
+		Help:      "Number of users connected to the chat server.",
+	})
+
+	OnlineRooms = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "chat",
+		Name:      "online_rooms",
+		Help:      "Number of rooms loaded in memory.",
+	})
+
+	// ChatMessages counts delivered, dropped, rate limited and rejected messages, use rate() for messages per second.
+	ChatMessages = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "chat",
+		Name:      "messages_total",
+		Help:      "Number of chat messages by result (sent, dropped, limited or rejected).",
+	}, []string{"result"})
+
+	WsConnections = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "connections_total",
+		Help:      "Number of websocket connections by event (opened or closed).",
+	}, []string{"event"})
+)
+
+const (
+	MessageSent    = "sent"
+	MessageDropped = "dropped"
+	MessageLimited = "limited"
+	// MessageRejected are direct messages the receiver does not accept from the sender
+	MessageRejected = "rejected"
+
+	ConnOpened = "opened"
+	ConnClosed = "closed"
+)
+
+// MARK: stores
+
+var CacheRequests = factory.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "cache",
+	Name:      "requests_total",
+	Help:      "Number of cache lookups by store, operation and result (hit or miss).",
+}, []string{"store", "op", "result"})
+
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
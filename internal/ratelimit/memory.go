@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// buckets idle for longer are forgotten
+const idleBucketTimeout = 10 * time.Minute
+
+// MemoryLimiter keeps the buckets in the process, every node of a cluster limits on its own.
+type MemoryLimiter struct {
+	limit Limit
+
+	mx        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+func NewMemoryLimiter(limit Limit) *MemoryLimiter {
+	return &MemoryLimiter{limit: limit, buckets: make(map[string]*memoryBucket), lastSweep: time.Now()}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string) error {
+	now := time.Now()
+
+	// the bucket is taken from and released to under the lock, so that a release cannot
+	// race another one over the burst
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if now.Sub(l.lastSweep) > idleBucketTimeout {
+		for k, bucket := range l.buckets {
+			if now.Sub(bucket.lastSeen) > idleBucketTimeout {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{Limiter: rate.NewLimiter(rate.Limit(l.limit.Rate), l.limit.Burst)}
+		l.buckets[key] = bucket
+	}
+	bucket.lastSeen = now
+
+	reservation := bucket.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// the token is not taken, only its wait is reported
+		reservation.CancelAt(now)
+		return &ExceededError{Key: key, RetryAfter: delay}
+	}
+	return nil
+}
+
+func (l *MemoryLimiter) Release(ctx context.Context, key string) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	// a reservation of -1 tokens puts one back, unless the bucket refilled meanwhile
+	if bucket.TokensAt(now) <= float64(l.limit.Burst-1) {
+		bucket.ReserveN(now, -1)
+	}
+}
+
+type memoryBackend struct{}
+
+// NewMemoryBackend returns a backend of MemoryLimiters.
+func NewMemoryBackend() Backend {
+	return memoryBackend{}
+}
+
+func (memoryBackend) New(name string, limit Limit) Limiter {
+	return NewMemoryLimiter(limit)
+}
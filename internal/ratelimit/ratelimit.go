@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+)
+
+var logger = logging.Package("ratelimit")
+
+// Limit of a token bucket, Rate tokens are added per second up to Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled tells whether the limit restricts anything, a zero rate means no limit.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// Limiter keeps a token bucket per key, e.g. per ip or per user.
+type Limiter interface {
+	// Allow takes a token from the bucket of key, it returns an *ExceededError when the
+	// bucket is empty.
+	Allow(ctx context.Context, key string) error
+	// Release gives back a token taken by Allow, e.g. when the request is rejected by
+	// another limit after all. The bucket does not grow over its burst.
+	Release(ctx context.Context, key string)
+}
+
+// ExceededError is returned when a bucket is empty, RetryAfter is the time until
+// the next token is added.
+type ExceededError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("rate limit of %s exceeded, retry after %v", e.Key, e.RetryAfter)
+}
+
+// Backend creates the limiters of the process, Name separates the buckets of limiters
+// sharing a backend, e.g. "ip" or "chat_room".
+type Backend interface {
+	New(name string, limit Limit) Limiter
+}
+
+// NewLimiter returns a limiter of backend, or nil when the limit is disabled.
+func NewLimiter(backend Backend, name string, limit Limit) Limiter {
+	if !limit.Enabled() {
+		return nil
+	}
+	return backend.New(name, limit)
+}
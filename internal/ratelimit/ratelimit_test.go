@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+)
+
+func testLimiter(t *testing.T, limiter Limiter) {
+	ctx := context.Background()
+	for i := range 3 {
+		err := limiter.Allow(ctx, "10.0.0.1")
+		if i < 2 && err != nil {
+			t.Fatalf("call %d rejected: %v", i, err)
+		}
+		var exceeded *ExceededError
+		if i == 2 && (!errors.As(err, &exceeded) || exceeded.RetryAfter <= 0) {
+			t.Fatalf("expected the burst to be exhausted, got %v", err)
+		}
+	}
+	if err := limiter.Allow(ctx, "10.0.0.2"); err != nil {
+		t.Fatalf("another key is limited: %v", err)
+	}
+
+	// a released token can be taken again, but no more than the burst
+	limiter.Release(ctx, "10.0.0.1")
+	if err := limiter.Allow(ctx, "10.0.0.1"); err != nil {
+		t.Fatalf("released token not taken: %v", err)
+	}
+	for range 3 {
+		limiter.Release(ctx, "10.0.0.2")
+	}
+	for i := range 3 {
+		if err := limiter.Allow(ctx, "10.0.0.2"); i == 2 && err == nil {
+			t.Fatalf("bucket grew over its burst")
+		}
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	testLimiter(t, NewMemoryLimiter(Limit{Rate: 1, Burst: 2}))
+}
+
+func TestMemoryLimiterConcurrentRelease(t *testing.T) {
+	limiter := NewMemoryLimiter(Limit{Rate: 0.001, Burst: 2})
+	ctx := context.Background()
+	if err := limiter.Allow(ctx, "10.0.0.1"); err != nil {
+		t.Fatalf("call rejected: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for range 100 {
+		wg.Go(func() {
+			<-start
+			limiter.Release(ctx, "10.0.0.1")
+		})
+	}
+	close(start)
+	wg.Wait()
+	if tokens := limiter.buckets["10.0.0.1"].TokensAt(time.Now()); tokens > 2 {
+		t.Fatalf("bucket grew to %v tokens, over its burst", tokens)
+	}
+	for i := range 3 {
+		if err := limiter.Allow(ctx, "10.0.0.1"); i == 2 && err == nil {
+			t.Fatalf("bucket grew over its burst")
+		}
+	}
+}
+
+func TestRedisLimiter(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	testLimiter(t, NewRedisLimiter(client, "ip", Limit{Rate: 1, Burst: 2}))
+
+	// redis being down does not block requests
+	server.Close()
+	if err := NewRedisLimiter(client, "ip", Limit{Rate: 1, Burst: 2}).Allow(context.Background(), "10.0.0.1"); err != nil {
+		t.Fatalf("expected to fail open, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+const RedisKeyPrefix = "ratelimit:"
+
+// tokenBucketScript refills the bucket KEYS[1] by the time elapsed since its last use
+// and takes a token. ARGV are the rate per second and the burst. It returns 1 when the
+// token is taken and otherwise 0 with the microseconds until the next token.
+// The time of redis is used, so that the clocks of the nodes do not matter.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+-- a bucket is full again after burst / rate seconds, it can be forgotten by then
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`)
+
+// releaseScript puts a token back to the bucket KEYS[1] up to the burst ARGV[1]. A
+// forgotten bucket is full already.
+var releaseScript = redis.NewScript(`
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+if tokens then
+	redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(tonumber(ARGV[1]), tokens + 1)))
+end
+return 1
+`)
+
+// RedisLimiter keeps the buckets in redis, so the limits hold across all nodes.
+// When redis fails requests are allowed, an outage of redis does not take the api down.
+type RedisLimiter struct {
+	client redis.Scripter
+	prefix string
+	limit  Limit
+}
+
+func NewRedisLimiter(client redis.Scripter, name string, limit Limit) *RedisLimiter {
+	return &RedisLimiter{client: client, prefix: RedisKeyPrefix + name + ":", limit: limit}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string) error {
+	result, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key}, l.limit.Rate, l.limit.Burst).Int64Slice()
+	if err != nil {
+		logger.Ctx(ctx).WithError(err).Warnf("failed to check the rate limit of %s, allowed", key)
+		return nil
+	}
+	if result[0] == 1 {
+		return nil
+	}
+	return &ExceededError{Key: key, RetryAfter: time.Duration(result[1]) * time.Microsecond}
+}
+
+func (l *RedisLimiter) Release(ctx context.Context, key string) {
+	if err := releaseScript.Run(ctx, l.client, []string{l.prefix + key}, l.limit.Burst).Err(); err != nil {
+		logger.Ctx(ctx).WithError(err).Warnf("failed to release a token of %s", key)
+	}
+}
+
+type redisBackend struct {
+	client redis.Scripter
+}
+
+// NewRedisBackend returns a backend of RedisLimiters sharing client.
+func NewRedisBackend(client redis.Scripter) Backend {
+	return redisBackend{client: client}
+}
+
+func (b redisBackend) New(name string, limit Limit) Limiter {
+	return NewRedisLimiter(b.client, name, limit)
+}
@@ -1,9 +1,15 @@
 package internal
 
+import "context"
+
 type HandleConnFunc func(conn Conn)
 
 type Transport interface {
 	ListenAndServe() error
+	// Listening tells whether the transport accepts connections.
+	Listening() bool
+	// Shutdown stops accepting new connections, established connections are kept.
+	Shutdown(ctx context.Context) error
 	OnRecvConn(handler HandleConnFunc)
 	OnCloseConn(handler HandleConnFunc)
 }
@@ -18,5 +24,5 @@ type Conn interface {
 
 	RemoteAddr() string
 	// the user id of the connection
-	UserId() int
+	UserId() int64
 }
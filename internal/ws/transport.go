@@ -1,22 +1,30 @@
 package ws
 
 import (
+	"context"
+	"errors"
+	"net"
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/TheChosenGay/coffee/internal"
+	"github.com/TheChosenGay/coffee/internal/logging"
 	"github.com/TheChosenGay/coffee/types"
-	"github.com/sirupsen/logrus"
 	"golang.org/x/net/websocket"
 )
 
+var logger = logging.Package("ws")
+
 type WsTransportOpts struct {
 	ListenAddr string
 }
 
 type WsTransport struct {
-	opts WsTransportOpts
+	opts       WsTransportOpts
+	httpServer *http.Server
+	listening  atomic.Bool
 
 	mx       sync.Mutex
 	connPool sync.Pool
@@ -28,16 +36,37 @@ type WsTransport struct {
 }
 
 func NewWsTransport(opts WsTransportOpts) *WsTransport {
-	return &WsTransport{
+	t := &WsTransport{
 		opts:     opts,
 		connPool: sync.Pool{New: func() any { return &WsConn{} }},
 		CloseCh:  make(chan internal.Conn),
 	}
+	mux := http.NewServeMux()
+	mux.Handle("/ws", websocket.Handler(t.handleWs))
+	t.httpServer = &http.Server{Addr: opts.ListenAddr, Handler: mux}
+	return t
 }
 
 func (t *WsTransport) ListenAndServe() error {
-	http.Handle("/ws", websocket.Handler(t.handleWs))
-	return http.ListenAndServe(t.opts.ListenAddr, nil)
+	lis, err := net.Listen("tcp", t.opts.ListenAddr)
+	if err != nil {
+		return err
+	}
+	t.listening.Store(true)
+	defer t.listening.Store(false)
+	if err := t.httpServer.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (t *WsTransport) Listening() bool {
+	return t.listening.Load()
+}
+
+func (t *WsTransport) Shutdown(ctx context.Context) error {
+	// websocket connections are hijacked, so they are not waited by Shutdown.
+	return t.httpServer.Shutdown(ctx)
 }
 
 func (t *WsTransport) handleWs(ws *websocket.Conn) {
@@ -59,12 +88,10 @@ func (t *WsTransport) handleWs(ws *websocket.Conn) {
 	}
 	conn.userId = userId
 	conn.closeCh = make(chan struct{})
+	conn.closeOnce = sync.Once{}
 	t.onConnHandler(conn)
-	select {
-	case <-conn.closeCh:
-		t.onCloseConnHandler(conn)
-		return
-	}
+	<-conn.closeCh
+	t.onCloseConnHandler(conn)
 }
 
 func (t *WsTransport) Close(conn internal.Conn) error {
@@ -80,9 +107,9 @@ func (t *WsTransport) OnCloseConn(handler internal.HandleConnFunc) {
 	t.onCloseConnHandler = handler
 }
 
-func (t *WsTransport) getUserId(ws *websocket.Conn) (int, error) {
+func (t *WsTransport) getUserId(ws *websocket.Conn) (int64, error) {
 	userIdStr := ws.Request().URL.Query().Get("user_id")
-	userId, err := strconv.Atoi(userIdStr)
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
 	if err != nil {
 		return types.InvalidUserId, err
 	}
@@ -90,9 +117,10 @@ func (t *WsTransport) getUserId(ws *websocket.Conn) (int, error) {
 }
 
 type WsConn struct {
-	conn    *websocket.Conn
-	userId  int
-	closeCh chan struct{}
+	conn      *websocket.Conn
+	userId    int64
+	closeCh   chan struct{}
+	closeOnce sync.Once
 }
 
 func (c *WsConn) Send(msg []byte) error {
@@ -119,21 +147,26 @@ func (c *WsConn) OnRecvMsg(handler internal.HandleMessageFunc) {
 				return
 			}
 			if err := handler(msg[:n]); err != nil {
-				logrus.WithError(err).Error("failed to send message")
+				logger.WithError(err).Error("failed to send message")
 			}
 		}
 	}()
 }
 
+// Close is safe to be called more than once, only the first call closes the connection.
 func (c *WsConn) Close() error {
-	c.closeCh <- struct{}{}
-	return c.conn.Close()
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+		err = c.conn.Close()
+	})
+	return err
 }
 
 func (c *WsConn) RemoteAddr() string {
 	return c.conn.RemoteAddr().String()
 }
 
-func (c *WsConn) UserId() int {
+func (c *WsConn) UserId() int64 {
 	return c.userId
 }
@@ -1,85 +1,338 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 	"reflect"
+	"time"
 
 	"github.com/TheChosenGay/coffee/api"
 	"github.com/TheChosenGay/coffee/api/grpc_handler"
 	"github.com/TheChosenGay/coffee/api/json_handler"
+	"github.com/TheChosenGay/coffee/config"
+	"github.com/TheChosenGay/coffee/internal/health"
+	"github.com/TheChosenGay/coffee/internal/lifecycle"
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"github.com/TheChosenGay/coffee/internal/tracing"
 	"github.com/TheChosenGay/coffee/service"
 	"github.com/TheChosenGay/coffee/service/chat"
 	"github.com/TheChosenGay/coffee/service/manage"
 	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/TheChosenGay/coffee/service/store/cache_store"
 	"github.com/TheChosenGay/coffee/service/store/gorm_store"
+	"github.com/TheChosenGay/coffee/service/store/memory_store"
 	"github.com/TheChosenGay/coffee/service/store/redis_store"
+	"google.golang.org/grpc/keepalive"
+	"gorm.io/gorm"
 )
 
+var logger = logging.Package("main")
+
+// healthCheckTimeout bounds the checks of a readiness probe.
+const healthCheckTimeout = 2 * time.Second
+
 func main() {
-	cs := service.NewCoffeeService()
-	db := gorm_store.NewMySqlDatabase(gorm_store.MySqlDatabaseOpts{
-		Username: "root",
-		Password: "123456",
-		Protocol: "tcp",
-		Addr:     "127.0.0.1:3306",
-		DBName:   "coffee",
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	if err := logging.Setup(logging.Opts{Level: cfg.Log.Level, Format: cfg.Log.Format, Packages: cfg.Log.Packages}); err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	lm := lifecycle.NewManager(cfg.ShutdownTimeout)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Opts{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: "coffee",
+		SampleRatio: cfg.Tracing.SampleRatio,
 	})
-	redisStore := redis_store.NewRedisUserStore(redis_store.RedisStoreOpts{Addr: "127.0.0.1:6379", Password: "", DB: 0})
-	userStore := gorm_store.NewGormUserStore(db)
-	roomStore := gorm_store.NewGormRoomStore(db)
-	cachedUserStore := cache_store.NewCacheUserStore(redisStore, userStore)
+	if err != nil {
+		logger.Fatalf("failed to setup tracing: %v", err)
+	}
+	// registered first, so it is stopped last and flushes the spans of the shutdown too.
+	lm.OnStop("tracing", shutdownTracing)
+
+	// readiness of the process, reported by /readyz and the grpc health service
+	checker := health.NewChecker(healthCheckTimeout)
+
+	cs := service.NewCoffeeService(memory_store.NewMemoryCoffeeStore(service.MockCoffees...))
+	stores := openStores(cfg, lm, checker)
+	userStore, roomStore := stores.users, stores.rooms
+	auditor := service.NewAuditor(stores.audit)
+
+	redisOpts := redis_store.RedisStoreOpts{Addr: cfg.Redis.Addr, Password: cfg.Redis.Password, DB: cfg.Redis.DB}
+	var cachedUserStore store.UserStore = userStore
+	var cachedRoomStore store.RoomStore = roomStore
+	units := stores.units
+	if cfg.Redis.Enabled {
+		redisRoomStore := redis_store.NewRedisRoomStore(redisOpts, cfg.Redis.RoomTTL)
+		lm.OnStop("redis rooms", func(ctx context.Context) error { return redisRoomStore.Close() })
+		cacheRoomStore := cache_store.NewCacheRoomStore(redisRoomStore, roomStore)
+		cachedRoomStore = cacheRoomStore
+
+		redisStore := redis_store.NewRedisUserStore(redisOpts, cfg.Redis.UserTTL, cfg.Redis.UserMissTTL)
+		lm.OnStop("redis", func(ctx context.Context) error { return redisStore.Close() })
+		checker.Add("redis", redisStore.Ping)
+		cacheUserStore := cache_store.NewCacheUserStore(redisStore, userStore)
+		lm.OnStop("user cache", cacheUserStore.Flush)
+		cachedUserStore = cacheUserStore
+		units = cache_store.NewCacheUnitOfWork(units, redisStore, redisRoomStore)
+	}
+	limiters := newRateLimitBackend(cfg.RateLimit, redisOpts, lm)
+
 	onlineUserService := chat.NewDefaultOnlineUserService(cachedUserStore)
-	onlineRoomService := chat.NewDefaultOnlineRoomService(roomStore)
+	onlineRoomService := chat.NewDefaultOnlineRoomService(cachedRoomStore)
+
+	userService := service.NewUserService(units, cachedUserStore, stores.avatars, stores.userIds, auditor)
 
-	userIdService := service.NewUserIdService()
-	userService := service.NewUserService(cachedUserStore, userIdService)
 	// use one coffee servive for both json and grpc
-	go runJsonServer(cs, roomStore, userStore, userService, onlineRoomService, onlineUserService)
-	go runGrpcServer(cs)
-	go runUserConnServer(cachedUserStore, onlineUserService, onlineRoomService)
-	select {}
+	grpcServer := newGrpcServer(cfg.Grpc, cs, userService, checker, limiters)
+	lm.Go("grpc server", grpcServer.Run)
+	lm.OnStop("grpc server", grpcServer.Shutdown)
+	checker.Add("grpc server", grpcServer.Ready)
+
+	// the annotated grpc services are served as json too, the gateway is stopped
+	// after the json server and before the grpc server it calls.
+	gateway, err := grpcServer.NewGateway(context.Background())
+	if err != nil {
+		logger.Fatalf("failed to create grpc gateway: %v", err)
+	}
+	lm.OnStop("grpc gateway", func(ctx context.Context) error { return gateway.Close() })
+
+	roomService := manage.NewRoomService(units, cachedRoomStore, userStore, stores.roomIds, onlineRoomService, onlineUserService, auditor)
+	contactService := manage.NewContactService(units, stores.contacts, cachedUserStore, onlineUserService, manage.ContactServiceOpts{
+		ContactsOnly: cfg.Chat.ContactsOnly,
+	})
+	jsonServer := newJsonServer(cfg.Json, cs, roomService, userService, contactService)
+	jsonServer.Mount("/api/", gateway)
+	jsonServer.ServeHealth(checker)
+	jsonServer.RateLimit(newJsonRateLimit(cfg.RateLimit, limiters))
+	lm.Go("json server", jsonServer.Run)
+	lm.OnStop("json server", jsonServer.Shutdown)
+	checker.Add("json server", jsonServer.Ready)
+
+	// messages over the limits are rejected before their receivers are looked up
+	chatService := chat.NewDefaultChatService(onlineUserService, onlineRoomService)
+	chatService = chat.NewContactChatService(chatService, contactService)
+	chatService = chat.NewRateLimitedChatService(chatService, chat.ChatRateLimitOpts{
+		User: ratelimit.NewLimiter(limiters, "chat_user", limitOf(cfg.RateLimit.ChatUser)),
+		Room: ratelimit.NewLimiter(limiters, "chat_room", limitOf(cfg.RateLimit.ChatRoom)),
+	})
+	userConnServer := newUserConnServer(cfg.Ws.Addr, cachedUserStore, onlineUserService, chatService)
+	lm.Go("ws server", userConnServer.Run)
+	lm.OnStop("ws server", userConnServer.Shutdown)
+	checker.Add("ws server", userConnServer.Ready)
+
+	if cfg.Admin.Enabled() {
+		adminServer := newAdminServer(cfg.Admin, roomService, onlineUserService, onlineRoomService, auditor)
+		lm.Go("admin server", adminServer.Run)
+		lm.OnStop("admin server", adminServer.Shutdown)
+		checker.Add("admin server", adminServer.Ready)
+	}
+
+	// registered last, so the process reports not ready before the servers start draining.
+	lm.OnStop("health", checker.Shutdown)
+
+	if err := lm.Wait(); err != nil {
+		logger.Fatalf("shutdown with error: %v", err)
+	}
 }
 
-// start json over http server
-func runJsonServer(cs service.CoffeeService, roomStore store.RoomStore, userStore store.UserStore, userService service.UserService, onlineRoomService chat.OnlineRoomService, onlineUserService chat.OnlineUserService) {
-	csvc := json_handler.NewJsonCoffeeServiceHandler(cs)
-	roomIdService := service.NewRoomIdService()
+// stores of the records of the server and the generators of their ids.
+type stores struct {
+	users    store.UserStore
+	rooms    store.RoomStore
+	audit    store.AuditStore
+	avatars  store.AvatarStore
+	contacts store.ContactStore
+	units    store.UnitOfWork
+	userIds  service.IdService
+	roomIds  service.IdService
+}
+
+// openStores opens the database of the configured driver, the memory driver has none.
+func openStores(cfg config.Config, lm *lifecycle.Manager, checker *health.Checker) stores {
+	if cfg.Database.Driver == config.DriverMemory {
+		logger.Warn("records are kept in memory, they are lost when the server stops")
+		userIds, roomIds := newIdServices(cfg.Id, nil)
+		memory := stores{
+			users:    memory_store.NewMemoryUserStore(),
+			rooms:    memory_store.NewMemoryRoomStore(),
+			audit:    memory_store.NewMemoryAuditStore(),
+			avatars:  memory_store.NewMemoryAvatarStore(),
+			contacts: memory_store.NewMemoryContactStore(),
+			userIds:  userIds,
+			roomIds:  roomIds,
+		}
+		memory.units = memory_store.NewMemoryUnitOfWork(store.Stores{
+			Users:    memory.users,
+			Rooms:    memory.rooms,
+			Audit:    memory.audit,
+			Avatars:  memory.avatars,
+			Contacts: memory.contacts,
+		})
+		return memory
+	}
 
-	rs := manage.NewRoomService(roomStore, userStore, roomIdService, onlineRoomService, onlineUserService)
-	rsvc := json_handler.NewJsonRoomServiceHandler(rs)
+	db := openDatabase(cfg.Database)
+	lm.OnStop("database", func(ctx context.Context) error { return gorm_store.CloseDatabase(db) })
+	checker.Add("database", func(ctx context.Context) error { return gorm_store.PingDatabase(ctx, db) })
+	if err := prepareSchema(context.Background(), cfg.Database, db); err != nil {
+		logger.Fatalf("unexpected database schema: %v", err)
+	}
+	userIds, roomIds := newIdServices(cfg.Id, db)
+	return stores{
+		users:    gorm_store.NewGormUserStore(db),
+		rooms:    gorm_store.NewGormRoomStore(db),
+		audit:    gorm_store.NewGormAuditStore(db),
+		avatars:  gorm_store.NewGormAvatarStore(db),
+		contacts: gorm_store.NewGormContactStore(db),
+		units:    gorm_store.NewGormUnitOfWork(db),
+		userIds:  userIds,
+		roomIds:  roomIds,
+	}
+}
+
+func openDatabase(cfg config.DatabaseConfig) *gorm.DB {
+	if cfg.Driver == config.DriverSqlite {
+		return gorm_store.NewSqliteDatabase(gorm_store.SqliteDatabaseOpts{Path: cfg.Sqlite.Path})
+	}
+	return gorm_store.NewMySqlDatabase(gorm_store.MySqlDatabaseOpts{
+		Username: cfg.MySql.Username,
+		Password: cfg.MySql.Password,
+		Protocol: cfg.MySql.Protocol,
+		Addr:     cfg.MySql.Addr,
+		DBName:   cfg.MySql.DBName,
+	})
+}
+
+// newIdServices returns the id generators of users and rooms, db is nil for the memory driver.
+func newIdServices(cfg config.IdConfig, db *gorm.DB) (service.IdService, service.IdService) {
+	if cfg.Generator == config.IdGeneratorSnowflake {
+		// snowflake ids are unique across users and rooms, one generator is enough.
+		idService, err := service.NewSnowflakeIdService(cfg.NodeId)
+		if err != nil {
+			logger.Fatalf("failed to create id service: %v", err)
+		}
+		return idService, idService
+	}
+	if db == nil {
+		return memory_store.NewMemoryIdService(), memory_store.NewMemoryIdService()
+	}
+	// ids are allocated from the database, so they keep growing across restarts.
+	userIdService := gorm_store.NewGormIdService(db, gorm_store.GormIdServiceOpts{Name: "user", SeedTable: "user_models", SeedColumn: "user_id"})
+	roomIdService := gorm_store.NewGormIdService(db, gorm_store.GormIdServiceOpts{Name: "room", SeedTable: "room_models", SeedColumn: "room_id"})
+	return userIdService, roomIdService
+}
+
+// json over http server
+func newJsonServer(cfg config.JsonConfig, cs service.CoffeeService, roomService service.RoomService, userService service.UserService, contactService service.ContactService) *api.JsonServer {
+	csvc := json_handler.NewJsonCoffeeServiceHandler(cs)
+	rsvc := json_handler.NewJsonRoomServiceHandler(roomService)
 	usvc := json_handler.NewJsonUserServiceHandler(userService)
-	jsonServer := api.NewJsonServer(":8080")
+	jsonServer := api.NewJsonServer(cfg.Addr)
 
-	jsonServer.RegisterHandler(reflect.TypeOf(csvc).Elem().Name(), csvc)
-	jsonServer.RegisterHandler(reflect.TypeOf(rsvc).Elem().Name(), rsvc)
-	jsonServer.RegisterHandler(reflect.TypeOf(usvc).Elem().Name(), usvc)
-	if err := jsonServer.Run(); err != nil {
-		log.Fatalf("failed to run json server: %v", err)
+	if cfg.V1 {
+		jsonServer.RegisterHandlers(api.V1, []api.JsonServerHandler{csvc, rsvc, usvc})
 	}
+	jsonServer.RegisterHandlers(api.V2, []api.JsonServerHandler{
+		json_handler.NewJsonUserServiceHandlerV2(userService),
+		json_handler.NewJsonContactServiceHandler(contactService),
+	})
+	return jsonServer
 }
 
-// start grpc server
-func runGrpcServer(cs service.CoffeeService) {
+// grpc server
+func newGrpcServer(cfg config.GrpcConfig, cs service.CoffeeService, userService service.UserService, checker *health.Checker, limiters ratelimit.Backend) *api.GrpcServer {
+	opts := api.GrpcServerOpts{
+		ListenAddr: cfg.Addr,
+		Health:     checker,
+		Tokens:     make(map[string]string, len(cfg.Auth.Tokens)),
+		RateLimit:  ratelimit.NewLimiter(limiters, "grpc", limitOf(cfg.RateLimit)),
+		Keepalive: keepalive.ServerParameters{
+			Time:              cfg.Keepalive.Time,
+			Timeout:           cfg.Keepalive.Timeout,
+			MaxConnectionIdle: cfg.Keepalive.MaxConnectionIdle,
+			MaxConnectionAge:  cfg.Keepalive.MaxConnectionAge,
+		},
+		KeepalivePolicy: keepalive.EnforcementPolicy{
+			MinTime:             cfg.Keepalive.MinTime,
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+		},
+	}
+	for name, token := range cfg.Auth.Tokens {
+		opts.Tokens[token] = name
+	}
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := api.LoadServerTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ClientCAFile)
+		if err != nil {
+			logger.Fatalf("failed to load grpc tls: %v", err)
+		}
+		opts.TLS = tlsConfig
+	}
+
 	csvc := grpc_handler.NewGrpcCoffeeServiceHandler(cs)
-	grpcServer := api.NewGrpcServer(":50051")
+	grpcServer := api.NewGrpcServer(opts)
 	grpcServer.RegisterHandler(reflect.TypeOf(csvc).Elem().Name(), csvc)
-	if err := grpcServer.Run(); err != nil {
-		log.Fatalf("failed to run grpc server: %v", err)
-	}
+	usvc := grpc_handler.NewGrpcUserServiceHandler(userService)
+	grpcServer.RegisterHandler(reflect.TypeOf(usvc).Elem().Name(), usvc)
+	return grpcServer
 }
 
-// start websocket server
-func runUserConnServer(userStore store.UserStore, onlineUserService chat.OnlineUserService, onlineRoomService chat.OnlineRoomService) {
-	userConnServer := api.NewUserConnServer(api.WsServerOpts{
-		ListenAddr:    ":8081",
+// websocket server
+func newUserConnServer(listenAddr string, userStore store.UserStore, onlineUserService chat.OnlineUserService, chatService chat.ChatService) *api.UserConnServer {
+	return api.NewUserConnServer(api.WsServerOpts{
+		ListenAddr:    listenAddr,
 		UserStore:     userStore,
 		OnlineUserSrv: onlineUserService,
-		ChatService:   chat.NewDefaultChatService(onlineUserService, onlineRoomService),
+		ChatService:   chatService,
 	})
-	defer userConnServer.Close()
+}
+
+// admin api of the operators
+func newAdminServer(cfg config.AdminConfig, roomService service.RoomService, onlineUserService chat.OnlineUserService, onlineRoomService chat.OnlineRoomService, auditor *service.Auditor) *api.AdminServer {
+	opts := api.AdminServerOpts{ListenAddr: cfg.Addr, Tokens: make(map[string]string, len(cfg.Auth.Tokens))}
+	for name, token := range cfg.Auth.Tokens {
+		opts.Tokens[token] = name
+	}
+	adminService := manage.NewAuditedAdminService(manage.NewAdminService(roomService, onlineUserService, onlineRoomService, auditor), auditor)
+	adminServer := api.NewAdminServer(opts)
+	adminServer.RegisterHandler(json_handler.NewJsonAdminServiceHandler(adminService))
+	return adminServer
+}
+
+// newRateLimitBackend returns where the token buckets of the rate limits are kept.
+func newRateLimitBackend(cfg config.RateLimitsConfig, redisOpts redis_store.RedisStoreOpts, lm *lifecycle.Manager) ratelimit.Backend {
+	if cfg.Backend != config.RateLimitBackendRedis {
+		return ratelimit.NewMemoryBackend()
+	}
+	client := redis_store.NewRedisClient(redisOpts)
+	lm.OnStop("rate limit redis", func(ctx context.Context) error { return client.Close() })
+	return ratelimit.NewRedisBackend(client)
+}
 
-	if err := userConnServer.Run(); err != nil {
-		log.Fatalf("failed to run ws server: %v", err)
+func newJsonRateLimit(cfg config.RateLimitsConfig, limiters ratelimit.Backend) api.RateLimitOpts {
+	opts := api.RateLimitOpts{
+		IP:     ratelimit.NewLimiter(limiters, "ip", limitOf(cfg.IP)),
+		Routes: make(map[string]ratelimit.Limiter),
 	}
+	for route, limit := range cfg.Routes {
+		if limiter := ratelimit.NewLimiter(limiters, "route:"+route, limitOf(limit)); limiter != nil {
+			opts.Routes[route] = limiter
+		}
+	}
+	return opts
+}
+
+func limitOf(cfg config.RateLimitConfig) ratelimit.Limit {
+	return ratelimit.Limit{Rate: cfg.Rate, Burst: cfg.Burst}
 }
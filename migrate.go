@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/TheChosenGay/coffee/config"
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/service/store/gorm_store"
+	"gorm.io/gorm"
+)
+
+const migrateUsage = `usage: coffee migrate [flags] <command> [version]
+
+commands:
+  up [version]    apply the migrations up to version, the latest by default
+  down [version]  revert the migrations down to version, the previous one by default
+  status          list the migrations and when they were applied`
+
+// noVersion is the target of a migrate command given no version.
+const noVersion = -1
+
+// migrate runs the migrate subcommand with the arguments after "migrate", the flags
+// select the database like they do for the server.
+func migrate(args []string) {
+	cfg, args, err := config.LoadArgs(args)
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	if err := logging.Setup(logging.Opts{Level: cfg.Log.Level, Format: cfg.Log.Format, Packages: cfg.Log.Packages}); err != nil {
+		log.Fatalf("invalid log config: %v", err)
+	}
+	if len(args) == 0 || len(args) > 2 {
+		log.Fatal(migrateUsage)
+	}
+	target := noVersion
+	if len(args) == 2 {
+		if target, err = strconv.Atoi(args[1]); err != nil || target < 0 {
+			log.Fatalf("invalid version %q\n%s", args[1], migrateUsage)
+		}
+	}
+
+	if cfg.Database.Driver == config.DriverMemory {
+		log.Fatal("the memory database has no schema to migrate")
+	}
+	db := openDatabase(cfg.Database)
+	defer gorm_store.CloseDatabase(db)
+	migrator, err := gorm_store.NewSchemaMigrator(db)
+	if err != nil {
+		logger.Fatalf("failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up", "down":
+		if target, err = migrateTarget(args[0], target, schemaVersion(ctx, migrator)); err != nil {
+			log.Fatal(err)
+		}
+		if args[0] == "up" {
+			err = migrator.Up(ctx, target)
+		} else {
+			err = migrator.Down(ctx, target)
+		}
+	case "status":
+		err = printMigrationStatus(ctx, migrator)
+	default:
+		log.Fatal(migrateUsage)
+	}
+	if err != nil {
+		logger.Fatalf("failed to migrate: %v", err)
+	}
+}
+
+func schemaVersion(ctx context.Context, migrator *gorm_store.SchemaMigrator) int {
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		logger.Fatalf("failed to read the schema version: %v", err)
+	}
+	return version
+}
+
+// migrateTarget returns the version the command migrates a schema at version to, given
+// the target of its arguments. It fails when the command would apply or revert nothing.
+func migrateTarget(command string, target, version int) (int, error) {
+	switch command {
+	case "up":
+		if target == noVersion {
+			// the latest for Up, a schema of a newer release fails in Up
+			return 0, nil
+		}
+		if target == 0 {
+			return 0, fmt.Errorf("version 0 is the empty schema: migrate down to 0 to revert every migration")
+		}
+		if target <= version {
+			return 0, fmt.Errorf("the schema is at version %d, not below %d: there is no migration to apply", version, target)
+		}
+	case "down":
+		if target == noVersion {
+			target = max(version-1, 0)
+		}
+		if target >= version {
+			return 0, fmt.Errorf("the schema is at version %d, not above %d: there is no migration to revert", version, target)
+		}
+	}
+	return target, nil
+}
+
+func printMigrationStatus(ctx context.Context, migrator *gorm_store.SchemaMigrator) error {
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range status {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Local().Format(time.DateTime)
+		}
+		if s.Version > migrator.Latest() {
+			applied += " (newer release)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
+
+// prepareSchema applies the pending migrations when configured to, then refuses to run
+// against a schema other than the one of this release.
+func prepareSchema(ctx context.Context, cfg config.DatabaseConfig, db *gorm.DB) error {
+	migrator, err := gorm_store.NewSchemaMigrator(db)
+	if err != nil {
+		return err
+	}
+	if cfg.AutoMigrate {
+		if err := migrator.Up(ctx, 0); err != nil {
+			return err
+		}
+	}
+	return migrator.Check(ctx)
+}
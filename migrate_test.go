@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestMigrateTarget(t *testing.T) {
+	for _, c := range []struct {
+		command         string
+		target, version int
+		want            int
+		fails           bool
+	}{
+		{command: "up", target: noVersion, version: 2, want: 0},
+		{command: "up", target: 3, version: 2, want: 3},
+		{command: "up", target: 0, version: 2, fails: true},
+		{command: "up", target: 0, version: 0, fails: true},
+		{command: "up", target: 2, version: 2, fails: true},
+		{command: "up", target: 1, version: 2, fails: true},
+		{command: "down", target: noVersion, version: 2, want: 1},
+		{command: "down", target: 0, version: 2, want: 0},
+		{command: "down", target: noVersion, version: 0, fails: true},
+		{command: "down", target: 2, version: 2, fails: true},
+		{command: "down", target: 3, version: 2, fails: true},
+	} {
+		target, err := migrateTarget(c.command, c.target, c.version)
+		if c.fails != (err != nil) || !c.fails && target != c.want {
+			t.Errorf("%s %d at version %d: got %d %v", c.command, c.target, c.version, target, err)
+		}
+	}
+}
@@ -26,6 +26,7 @@ type MessageType int32
 const (
 	MessageType_NORMAL MessageType = 0
 	MessageType_NOTIFY MessageType = 1
+	MessageType_ERROR  MessageType = 2
 )
 
 // Enum value maps for MessageType.
@@ -33,10 +34,12 @@ var (
 	MessageType_name = map[int32]string{
 		0: "NORMAL",
 		1: "NOTIFY",
+		2: "ERROR",
 	}
 	MessageType_value = map[string]int32{
 		"NORMAL": 0,
 		"NOTIFY": 1,
+		"ERROR":  2,
 	}
 )
 
@@ -70,8 +73,13 @@ func (MessageType) EnumDescriptor() ([]byte, []int) {
 type NotifyType int32
 
 const (
-	NotifyType_QUIT NotifyType = 0
-	NotifyType_JOIN NotifyType = 1
+	NotifyType_QUIT             NotifyType = 0
+	NotifyType_JOIN             NotifyType = 1
+	NotifyType_SHUTDOWN         NotifyType = 2 // the server is going down, the client should reconnect later
+	NotifyType_KICKED           NotifyType = 3 // an operator disconnected the user
+	NotifyType_ANNOUNCEMENT     NotifyType = 4 // a system announcement of the operators, the text is in contents
+	NotifyType_CONTACT_REQUEST  NotifyType = 5 // the user operator_id asked to be a contact of the target
+	NotifyType_CONTACT_ACCEPTED NotifyType = 6 // the user operator_id accepted the contact request of the target
 )
 
 // Enum value maps for NotifyType.
@@ -79,10 +87,20 @@ var (
 	NotifyType_name = map[int32]string{
 		0: "QUIT",
 		1: "JOIN",
+		2: "SHUTDOWN",
+		3: "KICKED",
+		4: "ANNOUNCEMENT",
+		5: "CONTACT_REQUEST",
+		6: "CONTACT_ACCEPTED",
 	}
 	NotifyType_value = map[string]int32{
-		"QUIT": 0,
-		"JOIN": 1,
+		"QUIT":             0,
+		"JOIN":             1,
+		"SHUTDOWN":         2,
+		"KICKED":           3,
+		"ANNOUNCEMENT":     4,
+		"CONTACT_REQUEST":  5,
+		"CONTACT_ACCEPTED": 6,
 	}
 )
 
@@ -159,8 +177,8 @@ func (x *Content) GetContent() []string {
 
 type NotifyMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	NotifyType    NotifyType             `protobuf:"varint,1,opt,name=notify_type,json=notifyType,proto3,enum=NotifyType" json:"notify_type,omitempty"`
-	OperatorId    int32                  `protobuf:"varint,2,opt,name=operator_id,json=operatorId,proto3" json:"operator_id,omitempty"`
+	NotifyType    NotifyType             `protobuf:"varint,1,opt,name=notify_type,json=notifyType,proto3,enum=chat.v1.NotifyType" json:"notify_type,omitempty"`
+	OperatorId    int64                  `protobuf:"varint,2,opt,name=operator_id,json=operatorId,proto3" json:"operator_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -202,28 +220,90 @@ func (x *NotifyMessage) GetNotifyType() NotifyType {
 	return NotifyType_QUIT
 }
 
-func (x *NotifyMessage) GetOperatorId() int32 {
+func (x *NotifyMessage) GetOperatorId() int64 {
 	if x != nil {
 		return x.OperatorId
 	}
 	return 0
 }
 
+// ErrorMessage tells the sender why its message was not delivered.
+type ErrorMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"` // e.g. resource_exhausted, like the code of the json api errors
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	RetryAfterMs  int64                  `protobuf:"varint,3,opt,name=retry_after_ms,json=retryAfterMs,proto3" json:"retry_after_ms,omitempty"` // set when the message was rate limited
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorMessage) Reset() {
+	*x = ErrorMessage{}
+	mi := &file_chat_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorMessage) ProtoMessage() {}
+
+func (x *ErrorMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorMessage.ProtoReflect.Descriptor instead.
+func (*ErrorMessage) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ErrorMessage) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ErrorMessage) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ErrorMessage) GetRetryAfterMs() int64 {
+	if x != nil {
+		return x.RetryAfterMs
+	}
+	return 0
+}
+
 type ChatMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SenderId      int32                  `protobuf:"varint,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
-	TargetId      int32                  `protobuf:"varint,2,opt,name=target_id,json=targetId,proto3" json:"target_id,omitempty"`
+	SenderId      int64                  `protobuf:"varint,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	TargetId      int64                  `protobuf:"varint,2,opt,name=target_id,json=targetId,proto3" json:"target_id,omitempty"`
 	IsUser        bool                   `protobuf:"varint,3,opt,name=is_user,json=isUser,proto3" json:"is_user,omitempty"`
 	Contents      []*Content             `protobuf:"bytes,4,rep,name=contents,proto3" json:"contents,omitempty"`
-	MessageType   MessageType            `protobuf:"varint,5,opt,name=message_type,json=messageType,proto3,enum=MessageType" json:"message_type,omitempty"`
+	MessageType   MessageType            `protobuf:"varint,5,opt,name=message_type,json=messageType,proto3,enum=chat.v1.MessageType" json:"message_type,omitempty"`
 	NotifyMessage *NotifyMessage         `protobuf:"bytes,6,opt,name=notify_message,json=notifyMessage,proto3" json:"notify_message,omitempty"` // only used when message_type is NOTIFY
+	ErrorMessage  *ErrorMessage          `protobuf:"bytes,7,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`    // only used when message_type is ERROR
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatMessage) Reset() {
 	*x = ChatMessage{}
-	mi := &file_chat_proto_msgTypes[2]
+	mi := &file_chat_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -235,7 +315,7 @@ func (x *ChatMessage) String() string {
 func (*ChatMessage) ProtoMessage() {}
 
 func (x *ChatMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[2]
+	mi := &file_chat_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -248,17 +328,17 @@ func (x *ChatMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatMessage.ProtoReflect.Descriptor instead.
 func (*ChatMessage) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{2}
+	return file_chat_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *ChatMessage) GetSenderId() int32 {
+func (x *ChatMessage) GetSenderId() int64 {
 	if x != nil {
 		return x.SenderId
 	}
 	return 0
 }
 
-func (x *ChatMessage) GetTargetId() int32 {
+func (x *ChatMessage) GetTargetId() int64 {
 	if x != nil {
 		return x.TargetId
 	}
@@ -293,35 +373,54 @@ func (x *ChatMessage) GetNotifyMessage() *NotifyMessage {
 	return nil
 }
 
+func (x *ChatMessage) GetErrorMessage() *ErrorMessage {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return nil
+}
+
 var File_chat_proto protoreflect.FileDescriptor
 
 const file_chat_proto_rawDesc = "" +
 	"\n" +
 	"\n" +
-	"chat.proto\"#\n" +
+	"chat.proto\x12\achat.v1\"#\n" +
 	"\aContent\x12\x18\n" +
-	"\acontent\x18\x01 \x03(\tR\acontent\"^\n" +
-	"\rNotifyMessage\x12,\n" +
-	"\vnotify_type\x18\x01 \x01(\x0e2\v.NotifyTypeR\n" +
+	"\acontent\x18\x01 \x03(\tR\acontent\"f\n" +
+	"\rNotifyMessage\x124\n" +
+	"\vnotify_type\x18\x01 \x01(\x0e2\x13.chat.v1.NotifyTypeR\n" +
 	"notifyType\x12\x1f\n" +
-	"\voperator_id\x18\x02 \x01(\x05R\n" +
-	"operatorId\"\xee\x01\n" +
+	"\voperator_id\x18\x02 \x01(\x03R\n" +
+	"operatorId\"b\n" +
+	"\fErrorMessage\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12$\n" +
+	"\x0eretry_after_ms\x18\x03 \x01(\x03R\fretryAfterMs\"\xc2\x02\n" +
 	"\vChatMessage\x12\x1b\n" +
-	"\tsender_id\x18\x01 \x01(\x05R\bsenderId\x12\x1b\n" +
-	"\ttarget_id\x18\x02 \x01(\x05R\btargetId\x12\x17\n" +
-	"\ais_user\x18\x03 \x01(\bR\x06isUser\x12$\n" +
-	"\bcontents\x18\x04 \x03(\v2\b.ContentR\bcontents\x12/\n" +
-	"\fmessage_type\x18\x05 \x01(\x0e2\f.MessageTypeR\vmessageType\x125\n" +
-	"\x0enotify_message\x18\x06 \x01(\v2\x0e.NotifyMessageR\rnotifyMessage*%\n" +
+	"\tsender_id\x18\x01 \x01(\x03R\bsenderId\x12\x1b\n" +
+	"\ttarget_id\x18\x02 \x01(\x03R\btargetId\x12\x17\n" +
+	"\ais_user\x18\x03 \x01(\bR\x06isUser\x12,\n" +
+	"\bcontents\x18\x04 \x03(\v2\x10.chat.v1.ContentR\bcontents\x127\n" +
+	"\fmessage_type\x18\x05 \x01(\x0e2\x14.chat.v1.MessageTypeR\vmessageType\x12=\n" +
+	"\x0enotify_message\x18\x06 \x01(\v2\x16.chat.v1.NotifyMessageR\rnotifyMessage\x12:\n" +
+	"\rerror_message\x18\a \x01(\v2\x15.chat.v1.ErrorMessageR\ferrorMessage*0\n" +
 	"\vMessageType\x12\n" +
 	"\n" +
 	"\x06NORMAL\x10\x00\x12\n" +
 	"\n" +
-	"\x06NOTIFY\x10\x01* \n" +
+	"\x06NOTIFY\x10\x01\x12\t\n" +
+	"\x05ERROR\x10\x02*w\n" +
 	"\n" +
 	"NotifyType\x12\b\n" +
 	"\x04QUIT\x10\x00\x12\b\n" +
-	"\x04JOIN\x10\x01B\x10Z\x0e./chat_serviceb\x06proto3"
+	"\x04JOIN\x10\x01\x12\f\n" +
+	"\bSHUTDOWN\x10\x02\x12\n" +
+	"\n" +
+	"\x06KICKED\x10\x03\x12\x10\n" +
+	"\fANNOUNCEMENT\x10\x04\x12\x13\n" +
+	"\x0fCONTACT_REQUEST\x10\x05\x12\x14\n" +
+	"\x10CONTACT_ACCEPTED\x10\x06B\x10Z\x0e./chat_serviceb\x06proto3"
 
 var (
 	file_chat_proto_rawDescOnce sync.Once
@@ -336,24 +435,26 @@ func file_chat_proto_rawDescGZIP() []byte {
 }
 
 var file_chat_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_chat_proto_goTypes = []any{
-	(MessageType)(0),      // 0: MessageType
-	(NotifyType)(0),       // 1: NotifyType
-	(*Content)(nil),       // 2: Content
-	(*NotifyMessage)(nil), // 3: NotifyMessage
-	(*ChatMessage)(nil),   // 4: ChatMessage
+	(MessageType)(0),      // 0: chat.v1.MessageType
+	(NotifyType)(0),       // 1: chat.v1.NotifyType
+	(*Content)(nil),       // 2: chat.v1.Content
+	(*NotifyMessage)(nil), // 3: chat.v1.NotifyMessage
+	(*ErrorMessage)(nil),  // 4: chat.v1.ErrorMessage
+	(*ChatMessage)(nil),   // 5: chat.v1.ChatMessage
 }
 var file_chat_proto_depIdxs = []int32{
-	1, // 0: NotifyMessage.notify_type:type_name -> NotifyType
-	2, // 1: ChatMessage.contents:type_name -> Content
-	0, // 2: ChatMessage.message_type:type_name -> MessageType
-	3, // 3: ChatMessage.notify_message:type_name -> NotifyMessage
-	4, // [4:4] is the sub-list for method output_type
-	4, // [4:4] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	1, // 0: chat.v1.NotifyMessage.notify_type:type_name -> chat.v1.NotifyType
+	2, // 1: chat.v1.ChatMessage.contents:type_name -> chat.v1.Content
+	0, // 2: chat.v1.ChatMessage.message_type:type_name -> chat.v1.MessageType
+	3, // 3: chat.v1.ChatMessage.notify_message:type_name -> chat.v1.NotifyMessage
+	4, // 4: chat.v1.ChatMessage.error_message:type_name -> chat.v1.ErrorMessage
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_chat_proto_init() }
@@ -367,7 +468,7 @@ func file_chat_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chat_proto_rawDesc), len(file_chat_proto_rawDesc)),
 			NumEnums:      2,
-			NumMessages:   3,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
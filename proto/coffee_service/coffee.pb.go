@@ -7,6 +7,7 @@
 package coffee_service
 
 import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -23,7 +24,7 @@ const (
 
 type Coffee struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
 	CoverUrl      string                 `protobuf:"bytes,3,opt,name=cover_url,json=coverUrl,proto3" json:"cover_url,omitempty"`
 	Category      string                 `protobuf:"bytes,4,opt,name=category,proto3" json:"category,omitempty"`
@@ -62,7 +63,7 @@ func (*Coffee) Descriptor() ([]byte, []int) {
 	return file_coffee_proto_rawDescGZIP(), []int{0}
 }
 
-func (x *Coffee) GetId() int32 {
+func (x *Coffee) GetId() int64 {
 	if x != nil {
 		return x.Id
 	}
@@ -181,7 +182,7 @@ func (x *CoffeesResponse) GetCoffee() []*Coffee {
 // get coffee by id
 type CoffeeByIdRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -216,7 +217,7 @@ func (*CoffeeByIdRequest) Descriptor() ([]byte, []int) {
 	return file_coffee_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *CoffeeByIdRequest) GetId() int32 {
+func (x *CoffeeByIdRequest) GetId() int64 {
 	if x != nil {
 		return x.Id
 	}
@@ -316,26 +317,26 @@ var File_coffee_proto protoreflect.FileDescriptor
 
 const file_coffee_proto_rawDesc = "" +
 	"\n" +
-	"\fcoffee.proto\"\x8a\x01\n" +
+	"\fcoffee.proto\x12\tcoffee.v1\x1a\x1cgoogle/api/annotations.proto\"\x8a\x01\n" +
 	"\x06Coffee\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x12\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1b\n" +
 	"\tcover_url\x18\x03 \x01(\tR\bcoverUrl\x12\x1a\n" +
 	"\bcategory\x18\x04 \x01(\tR\bcategory\x12#\n" +
 	"\rprod_location\x18\x05 \x01(\tR\fprodLocation\"\x14\n" +
-	"\x12ListCoffeesRequest\"2\n" +
-	"\x0fCoffeesResponse\x12\x1f\n" +
-	"\x06coffee\x18\x01 \x03(\v2\a.CoffeeR\x06coffee\"#\n" +
+	"\x12ListCoffeesRequest\"<\n" +
+	"\x0fCoffeesResponse\x12)\n" +
+	"\x06coffee\x18\x01 \x03(\v2\x11.coffee.v1.CoffeeR\x06coffee\"#\n" +
 	"\x11CoffeeByIdRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\x05R\x02id\")\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\")\n" +
 	"\x13CoffeeByNameRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\"1\n" +
-	"\x0eCoffeeResponse\x12\x1f\n" +
-	"\x06coffee\x18\x01 \x01(\v2\a.CoffeeR\x06coffee2\xb5\x01\n" +
-	"\rCoffeeService\x124\n" +
-	"\vListCoffees\x12\x13.ListCoffeesRequest\x1a\x10.CoffeesResponse\x124\n" +
-	"\rGetCoffeeById\x12\x12.CoffeeByIdRequest\x1a\x0f.CoffeeResponse\x128\n" +
-	"\x0fGetCoffeeByName\x12\x14.CoffeeByNameRequest\x1a\x0f.CoffeeResponseB\x12Z\x10./coffee_serviceb\x06proto3"
+	"\x04name\x18\x01 \x01(\tR\x04name\";\n" +
+	"\x0eCoffeeResponse\x12)\n" +
+	"\x06coffee\x18\x01 \x01(\v2\x11.coffee.v1.CoffeeR\x06coffee2\x8f\x03\n" +
+	"\rCoffeeService\x12q\n" +
+	"\vListCoffees\x12\x1d.coffee.v1.ListCoffeesRequest\x1a\x1a.coffee.v1.CoffeesResponse\"'\x82\xd3\xe4\x93\x02!Z\x0e\x12\f/api/coffees\x12\x0f/api/v1/coffees\x12{\n" +
+	"\rGetCoffeeById\x12\x1c.coffee.v1.CoffeeByIdRequest\x1a\x19.coffee.v1.CoffeeResponse\"1\x82\xd3\xe4\x93\x02+Z\x13\x12\x11/api/coffees/{id}\x12\x14/api/v1/coffees/{id}\x12\x8d\x01\n" +
+	"\x0fGetCoffeeByName\x12\x1e.coffee.v1.CoffeeByNameRequest\x1a\x19.coffee.v1.CoffeeResponse\"?\x82\xd3\xe4\x93\x029Z\x1a\x12\x18/api/coffees/name/{name}\x12\x1b/api/v1/coffees/name/{name}B\x12Z\x10./coffee_serviceb\x06proto3"
 
 var (
 	file_coffee_proto_rawDescOnce sync.Once
@@ -351,22 +352,22 @@ func file_coffee_proto_rawDescGZIP() []byte {
 
 var file_coffee_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_coffee_proto_goTypes = []any{
-	(*Coffee)(nil),              // 0: Coffee
-	(*ListCoffeesRequest)(nil),  // 1: ListCoffeesRequest
-	(*CoffeesResponse)(nil),     // 2: CoffeesResponse
-	(*CoffeeByIdRequest)(nil),   // 3: CoffeeByIdRequest
-	(*CoffeeByNameRequest)(nil), // 4: CoffeeByNameRequest
-	(*CoffeeResponse)(nil),      // 5: CoffeeResponse
+	(*Coffee)(nil),              // 0: coffee.v1.Coffee
+	(*ListCoffeesRequest)(nil),  // 1: coffee.v1.ListCoffeesRequest
+	(*CoffeesResponse)(nil),     // 2: coffee.v1.CoffeesResponse
+	(*CoffeeByIdRequest)(nil),   // 3: coffee.v1.CoffeeByIdRequest
+	(*CoffeeByNameRequest)(nil), // 4: coffee.v1.CoffeeByNameRequest
+	(*CoffeeResponse)(nil),      // 5: coffee.v1.CoffeeResponse
 }
 var file_coffee_proto_depIdxs = []int32{
-	0, // 0: CoffeesResponse.coffee:type_name -> Coffee
-	0, // 1: CoffeeResponse.coffee:type_name -> Coffee
-	1, // 2: CoffeeService.ListCoffees:input_type -> ListCoffeesRequest
-	3, // 3: CoffeeService.GetCoffeeById:input_type -> CoffeeByIdRequest
-	4, // 4: CoffeeService.GetCoffeeByName:input_type -> CoffeeByNameRequest
-	2, // 5: CoffeeService.ListCoffees:output_type -> CoffeesResponse
-	5, // 6: CoffeeService.GetCoffeeById:output_type -> CoffeeResponse
-	5, // 7: CoffeeService.GetCoffeeByName:output_type -> CoffeeResponse
+	0, // 0: coffee.v1.CoffeesResponse.coffee:type_name -> coffee.v1.Coffee
+	0, // 1: coffee.v1.CoffeeResponse.coffee:type_name -> coffee.v1.Coffee
+	1, // 2: coffee.v1.CoffeeService.ListCoffees:input_type -> coffee.v1.ListCoffeesRequest
+	3, // 3: coffee.v1.CoffeeService.GetCoffeeById:input_type -> coffee.v1.CoffeeByIdRequest
+	4, // 4: coffee.v1.CoffeeService.GetCoffeeByName:input_type -> coffee.v1.CoffeeByNameRequest
+	2, // 5: coffee.v1.CoffeeService.ListCoffees:output_type -> coffee.v1.CoffeesResponse
+	5, // 6: coffee.v1.CoffeeService.GetCoffeeById:output_type -> coffee.v1.CoffeeResponse
+	5, // 7: coffee.v1.CoffeeService.GetCoffeeByName:output_type -> coffee.v1.CoffeeResponse
 	5, // [5:8] is the sub-list for method output_type
 	2, // [2:5] is the sub-list for method input_type
 	2, // [2:2] is the sub-list for extension type_name
@@ -19,9 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	CoffeeService_ListCoffees_FullMethodName     = "/CoffeeService/ListCoffees"
-	CoffeeService_GetCoffeeById_FullMethodName   = "/CoffeeService/GetCoffeeById"
-	CoffeeService_GetCoffeeByName_FullMethodName = "/CoffeeService/GetCoffeeByName"
+	CoffeeService_ListCoffees_FullMethodName     = "/coffee.v1.CoffeeService/ListCoffees"
+	CoffeeService_GetCoffeeById_FullMethodName   = "/coffee.v1.CoffeeService/GetCoffeeById"
+	CoffeeService_GetCoffeeByName_FullMethodName = "/coffee.v1.CoffeeService/GetCoffeeByName"
 )
 
 // CoffeeServiceClient is the client API for CoffeeService service.
@@ -176,7 +176,7 @@ func _CoffeeService_GetCoffeeByName_Handler(srv interface{}, ctx context.Context
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var CoffeeService_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "CoffeeService",
+	ServiceName: "coffee.v1.CoffeeService",
 	HandlerType: (*CoffeeServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
@@ -0,0 +1,19 @@
+package coffee_service
+
+import "errors"
+
+// Validate methods are called by the grpc server before the requests reach the handlers.
+
+func (r *CoffeeByIdRequest) Validate() error {
+	if r.GetId() <= 0 {
+		return errors.New("id must be positive")
+	}
+	return nil
+}
+
+func (r *CoffeeByNameRequest) Validate() error {
+	if r.GetName() == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package user_service
+
+import "errors"
+
+// Validate methods are called by the grpc server before the requests reach the handlers.
+
+func (r *GetUserRequest) Validate() error {
+	if r.GetUserId() <= 0 {
+		return errors.New("user_id must be positive")
+	}
+	return nil
+}
+
+func (r *UpdateProfileRequest) Validate() error {
+	if r.GetUserId() <= 0 {
+		return errors.New("user_id must be positive")
+	}
+	return nil
+}
+
+func (r *UploadAvatarRequest) Validate() error {
+	if r.GetUserId() <= 0 {
+		return errors.New("user_id must be positive")
+	}
+	if len(r.GetData()) == 0 {
+		return errors.New("data is required")
+	}
+	return nil
+}
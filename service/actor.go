@@ -0,0 +1,23 @@
+package service
+
+import "context"
+
+// Actor is who performs an operation, e.g. "admin:alice" for an operator of the admin api.
+type Actor string
+
+// ActorSystem performs the operations started by the server itself.
+const ActorSystem Actor = "system"
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of ctx, or ActorSystem when no actor is set.
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return ActorSystem
+}
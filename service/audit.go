@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultAuditLimit and MaxAuditLimit bound the entries of a page of the audit trail
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+)
+
+// auditLogger mirrors the audit trail in the logs, keep its level at info or below.
+var auditLogger = logging.Package("audit")
+
+// Auditor records the administrative and moderation actions in the audit trail.
+// A nil Auditor records nothing.
+type Auditor struct {
+	store store.AuditStore
+}
+
+func NewAuditor(store store.AuditStore) *Auditor {
+	return &Auditor{store: store}
+}
+
+// Record appends an action of the actor of ctx on target, before and after are the
+// target around the action, nil when it did not exist. Failed actions are recorded
+// with their error. The trail is written even when ctx is canceled, failing to write
+// it does not fail the action, it is logged.
+func (a *Auditor) Record(ctx context.Context, action, target string, before, after any, err error) {
+	if a == nil {
+		return
+	}
+	entry := types.AuditEntry{
+		Actor:     string(ActorFrom(ctx)),
+		Action:    action,
+		Target:    target,
+		RequestId: string(logging.RequestIdFrom(ctx)),
+		Before:    auditJson(before),
+		After:     auditJson(after),
+		CreatedAt: time.Now().UTC(),
+	}
+	log := auditLogger.Ctx(ctx).WithFields(logrus.Fields{
+		"actor":  entry.Actor,
+		"action": action,
+		"target": target,
+	})
+	if err != nil {
+		entry.Error = err.Error()
+		log = log.WithError(err)
+	}
+	if err := a.store.AppendAudit(context.WithoutCancel(ctx), entry); err != nil {
+		log.WithField("audit_error", err.Error()).Error("failed to append audit entry")
+		return
+	}
+	log.Info("audit")
+}
+
+// List returns the entries of the audit trail matching filter, newest first.
+func (a *Auditor) List(ctx context.Context, filter store.AuditFilter) ([]types.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditLimit
+	}
+	if filter.Limit > MaxAuditLimit {
+		return nil, InvalidArgument("limit must be at most %d", MaxAuditLimit)
+	}
+	return a.store.ListAudit(ctx, filter)
+}
+
+func auditJson(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,36 @@
+package chat
+
+import (
+	"context"
+
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
+)
+
+// DirectMessageChecker tells whether a user may send direct messages to another, it is
+// implemented by service.ContactService.
+type DirectMessageChecker interface {
+	CheckDirectMessage(ctx context.Context, senderId int64, targetId int64) error
+}
+
+// contactChatService rejects the direct messages the checker does not allow, e.g. to a
+// user who blocked the sender. Messages to rooms are not checked.
+type contactChatService struct {
+	ChatService
+	checker DirectMessageChecker
+}
+
+func NewContactChatService(chatService ChatService, checker DirectMessageChecker) ChatService {
+	return &contactChatService{ChatService: chatService, checker: checker}
+}
+
+func (s *contactChatService) SendMsgToUser(ctx context.Context, userId int64, msg *chat_service.ChatMessage) error {
+	if err := s.checker.CheckDirectMessage(ctx, msg.SenderId, userId); err != nil {
+		if service.KindOf(err) == service.KindForbidden {
+			metrics.ChatMessages.WithLabelValues(metrics.MessageRejected).Inc()
+		}
+		return err
+	}
+	return s.ChatService.SendMsgToUser(ctx, userId, msg)
+}
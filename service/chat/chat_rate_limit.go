@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
+)
+
+type ChatRateLimitOpts struct {
+	// User limits the messages per sender, nil disables the limit.
+	User ratelimit.Limiter
+	// Room limits the messages per room of all its members together, nil disables the limit.
+	Room ratelimit.Limiter
+}
+
+// rateLimitedChatService rejects messages over the limits of their sender or room
+// with errors of kind service.KindResourceExhausted.
+type rateLimitedChatService struct {
+	ChatService
+	opts ChatRateLimitOpts
+}
+
+func NewRateLimitedChatService(chatService ChatService, opts ChatRateLimitOpts) ChatService {
+	return &rateLimitedChatService{ChatService: chatService, opts: opts}
+}
+
+func (s *rateLimitedChatService) SendMsgToUser(ctx context.Context, userId int64, msg *chat_service.ChatMessage) error {
+	if err := s.allow(ctx, s.opts.User, msg.SenderId, "too many messages"); err != nil {
+		return err
+	}
+	return s.ChatService.SendMsgToUser(ctx, userId, msg)
+}
+
+func (s *rateLimitedChatService) SendMsgToRoom(ctx context.Context, roomId int64, msg *chat_service.ChatMessage) error {
+	if err := s.allow(ctx, s.opts.User, msg.SenderId, "too many messages"); err != nil {
+		return err
+	}
+	if err := s.allow(ctx, s.opts.Room, roomId, "too many messages in room %d", roomId); err != nil {
+		// the room is checked last, so that senders over their limit take none of the
+		// tokens of its members, and the message is not counted against its sender
+		if s.opts.User != nil {
+			s.opts.User.Release(ctx, strconv.FormatInt(msg.SenderId, 10))
+		}
+		return err
+	}
+	return s.ChatService.SendMsgToRoom(ctx, roomId, msg)
+}
+
+func (s *rateLimitedChatService) allow(ctx context.Context, limiter ratelimit.Limiter, id int64, format string, args ...any) error {
+	if limiter == nil {
+		return nil
+	}
+	if err := limiter.Allow(ctx, strconv.FormatInt(id, 10)); err != nil {
+		metrics.ChatMessages.WithLabelValues(metrics.MessageLimited).Inc()
+		return service.Wrap(service.KindResourceExhausted, err, format, args...)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package chat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
+)
+
+// nopChatService delivers every message nowhere.
+type nopChatService struct{}
+
+func (nopChatService) SendMsgToUser(ctx context.Context, userId int64, msg *chat_service.ChatMessage) error {
+	return nil
+}
+
+func (nopChatService) SendMsgToRoom(ctx context.Context, roomId int64, msg *chat_service.ChatMessage) error {
+	return nil
+}
+
+func TestRateLimitedRoomKeepsSenderTokens(t *testing.T) {
+	chat := NewRateLimitedChatService(nopChatService{}, ChatRateLimitOpts{
+		User: ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 0.001, Burst: 2}),
+		Room: ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 0.001, Burst: 1}),
+	})
+	ctx := context.Background()
+	msg := &chat_service.ChatMessage{SenderId: 1}
+
+	if err := chat.SendMsgToRoom(ctx, 10, msg); err != nil {
+		t.Fatalf("first message rejected: %v", err)
+	}
+	for range 3 {
+		if err := chat.SendMsgToRoom(ctx, 10, msg); service.KindOf(err) != service.KindResourceExhausted {
+			t.Fatalf("expected the room limit to be exceeded, got %v", err)
+		}
+	}
+	// the messages rejected by the room took none of the tokens of the sender
+	if err := chat.SendMsgToRoom(ctx, 11, msg); err != nil {
+		t.Fatalf("message to another room rejected: %v", err)
+	}
+	if err := chat.SendMsgToUser(ctx, 2, msg); service.KindOf(err) != service.KindResourceExhausted {
+		t.Fatalf("expected the limit of the sender to be exceeded, got %v", err)
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"github.com/TheChosenGay/coffee/internal/tracing"
 	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ChatService interface {
-	SendMsgToUser(ctx context.Context, userId int, msg *chat_service.ChatMessage) error
-	SendMsgToRoom(ctx context.Context, roomId int, msg *chat_service.ChatMessage) error
+	SendMsgToUser(ctx context.Context, userId int64, msg *chat_service.ChatMessage) error
+	SendMsgToRoom(ctx context.Context, roomId int64, msg *chat_service.ChatMessage) error
 }
 
 type defaultChatService struct {
@@ -21,17 +25,25 @@ func NewDefaultChatService(onlineUserService OnlineUserService, onlineRoomServic
 	return &defaultChatService{onlineUserService: onlineUserService, onlineRoomService: onlineRoomService}
 }
 
-func (s *defaultChatService) SendMsgToUser(ctx context.Context, userId int, msg *chat_service.ChatMessage) error {
+func (s *defaultChatService) SendMsgToUser(ctx context.Context, userId int64, msg *chat_service.ChatMessage) (err error) {
+	ctx, span := tracing.Start(ctx, "ChatService.SendMsgToUser", trace.WithAttributes(attribute.Int64("chat.user_id", userId)))
+	defer func() { tracing.End(span, err) }()
+
 	onlineUser, err := s.onlineUserService.GetOnlineUser(ctx, userId)
 	if err != nil {
+		metrics.ChatMessages.WithLabelValues(metrics.MessageDropped).Inc()
 		return fmt.Errorf("failed to get online user:%d, error: %w", userId, err)
 	}
 	return onlineUser.SendMsg(msg)
 }
 
-func (s *defaultChatService) SendMsgToRoom(ctx context.Context, roomId int, msg *chat_service.ChatMessage) error {
+func (s *defaultChatService) SendMsgToRoom(ctx context.Context, roomId int64, msg *chat_service.ChatMessage) (err error) {
+	ctx, span := tracing.Start(ctx, "ChatService.SendMsgToRoom", trace.WithAttributes(attribute.Int64("chat.room_id", roomId)))
+	defer func() { tracing.End(span, err) }()
+
 	onlineRoom, err := s.onlineRoomService.GetOnlineRoom(ctx, roomId)
 	if err != nil {
+		metrics.ChatMessages.WithLabelValues(metrics.MessageDropped).Inc()
 		return err
 	}
 	onlineRoom.BroadcastMsg(msg)
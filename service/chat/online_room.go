@@ -2,18 +2,21 @@ package chat
 
 import (
 	"context"
-	"errors"
 	"sync"
 	"time"
 
+	"github.com/TheChosenGay/coffee/internal/logging"
 	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
 	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/TheChosenGay/coffee/types"
 	"github.com/sirupsen/logrus"
 )
 
+var logger = logging.Package("chat")
+
 type OnlineRoom struct {
-	RoomId      int
+	RoomId      int64
 	RoomName    string
 	broadcastCh chan *chat_service.ChatMessage
 
@@ -22,13 +25,13 @@ type OnlineRoom struct {
 	onlineUserService OnlineUserService
 
 	mx          sync.Mutex
-	onlineUnits map[int]types.Unit
+	onlineUnits map[int64]types.Unit
 }
 
-func NewOnlineRoom(roomId int, roomStore store.RoomStore, userStore store.UserStore, onlineUserService OnlineUserService) (*OnlineRoom, error) {
+func NewOnlineRoom(roomId int64, roomStore store.RoomStore, userStore store.UserStore, onlineUserService OnlineUserService) (*OnlineRoom, error) {
 	r := &OnlineRoom{
 		RoomId:            roomId,
-		onlineUnits:       make(map[int]types.Unit),
+		onlineUnits:       make(map[int64]types.Unit),
 		broadcastCh:       make(chan *chat_service.ChatMessage),
 		roomStore:         roomStore,
 		userStore:         userStore,
@@ -40,7 +43,7 @@ func NewOnlineRoom(roomId int, roomStore store.RoomStore, userStore store.UserSt
 		close(r.broadcastCh)
 		return nil, err
 	}
-	logrus.WithFields(logrus.Fields{
+	logger.WithFields(logrus.Fields{
 		"room_id": r.RoomId,
 		"time":    time.Since(start).Milliseconds(),
 	}).Info("fetching units")
@@ -51,39 +54,39 @@ func (r *OnlineRoom) AddUnit(ctx context.Context, unit types.Unit) error {
 	r.mx.Lock()
 	defer r.mx.Unlock()
 	if _, ok := r.onlineUnits[unit.Id()]; ok {
-		return errors.New("unit already in the room")
+		return service.Conflict("unit %d already in the room", unit.Id())
 	}
 	r.onlineUnits[unit.Id()] = unit
 
 	msg := chat_service.ChatMessage{
-		TargetId:    int32(r.RoomId),
+		TargetId:    r.RoomId,
 		IsUser:      false,
 		MessageType: chat_service.MessageType_NOTIFY,
 		NotifyMessage: &chat_service.NotifyMessage{
 			NotifyType: chat_service.NotifyType_JOIN,
-			OperatorId: int32(unit.Id()),
+			OperatorId: unit.Id(),
 		},
 	}
 	go r.BroadcastMsg(&msg)
 	return nil
 }
 
-func (r *OnlineRoom) RemoveUnit(ctx context.Context, unitId int) error {
+func (r *OnlineRoom) RemoveUnit(ctx context.Context, unitId int64) error {
 	r.mx.Lock()
 	defer r.mx.Unlock()
 	if _, ok := r.onlineUnits[unitId]; !ok {
-		return errors.New("unit not in the room")
+		return service.NotFound("unit %d not in the room", unitId)
 	}
 	delete(r.onlineUnits, unitId)
 
 	msg := chat_service.ChatMessage{
-		SenderId:    int32(unitId),
-		TargetId:    int32(r.RoomId),
+		SenderId:    unitId,
+		TargetId:    r.RoomId,
 		IsUser:      false,
 		MessageType: chat_service.MessageType_NOTIFY,
 		NotifyMessage: &chat_service.NotifyMessage{
 			NotifyType: chat_service.NotifyType_QUIT,
-			OperatorId: int32(unitId),
+			OperatorId: unitId,
 		},
 	}
 	go r.BroadcastMsg(&msg)
@@ -109,14 +112,14 @@ func (r *OnlineRoom) fetchUnits() error {
 	wg := sync.WaitGroup{}
 	for _, unitId := range room.Units {
 		wg.Add(1)
-		go func(unitId int) {
+		go func(unitId int64) {
 			defer wg.Done()
 			user, err := r.userStore.GetUser(context.Background(), unitId)
 			if err != nil {
 				return
 			}
 			if !user.IsValid() {
-				logrus.Errorf("user %d is not valid", unitId)
+				logger.Errorf("user %d is not valid", unitId)
 				return
 			}
 			onlineUser, err := r.onlineUserService.GetOnlineUser(context.Background(), unitId)
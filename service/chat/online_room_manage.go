@@ -2,39 +2,41 @@ package chat
 
 import (
 	"context"
-	"errors"
 	"sync"
 
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"github.com/TheChosenGay/coffee/service"
 	"github.com/TheChosenGay/coffee/service/store"
 )
 
 type OnlineRoomService interface {
-	GetOnlineRoom(ctx context.Context, roomId int) (*OnlineRoom, error)
+	GetOnlineRoom(ctx context.Context, roomId int64) (*OnlineRoom, error)
 	OnlineRoom(ctx context.Context, room *OnlineRoom) error
-	OfflineRoom(ctx context.Context, roomId int) error
+	OfflineRoom(ctx context.Context, roomId int64) error
+	GetOnlineRooms() []*OnlineRoom
 }
 
 type defaultOnlineRoomService struct {
 	roomStore store.RoomStore
 
 	mx          sync.Mutex
-	onlineRooms map[int]*OnlineRoom
+	onlineRooms map[int64]*OnlineRoom
 }
 
 func NewDefaultOnlineRoomService(roomStore store.RoomStore) OnlineRoomService {
 	return &defaultOnlineRoomService{
-		onlineRooms: make(map[int]*OnlineRoom),
+		onlineRooms: make(map[int64]*OnlineRoom),
 		mx:          sync.Mutex{},
 		roomStore:   roomStore,
 	}
 }
 
-func (s *defaultOnlineRoomService) GetOnlineRoom(ctx context.Context, roomId int) (*OnlineRoom, error) {
+func (s *defaultOnlineRoomService) GetOnlineRoom(ctx context.Context, roomId int64) (*OnlineRoom, error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 	room, ok := s.onlineRooms[roomId]
 	if !ok {
-		return nil, errors.New("room not found")
+		return nil, service.NotFound("room %d is not online", roomId)
 	}
 	return room, nil
 }
@@ -43,12 +45,24 @@ func (s *defaultOnlineRoomService) OnlineRoom(ctx context.Context, room *OnlineR
 	s.mx.Lock()
 	defer s.mx.Unlock()
 	s.onlineRooms[room.RoomId] = room
+	metrics.OnlineRooms.Set(float64(len(s.onlineRooms)))
 	return nil
 }
 
-func (s *defaultOnlineRoomService) OfflineRoom(ctx context.Context, roomId int) error {
+func (s *defaultOnlineRoomService) OfflineRoom(ctx context.Context, roomId int64) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 	delete(s.onlineRooms, roomId)
+	metrics.OnlineRooms.Set(float64(len(s.onlineRooms)))
 	return nil
 }
+
+func (s *defaultOnlineRoomService) GetOnlineRooms() []*OnlineRoom {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	rooms := []*OnlineRoom{}
+	for _, room := range s.onlineRooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
@@ -2,31 +2,49 @@ package chat
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/TheChosenGay/coffee/internal"
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"github.com/TheChosenGay/coffee/internal/ratelimit"
+	"github.com/TheChosenGay/coffee/internal/tracing"
 	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
 	"github.com/TheChosenGay/coffee/types"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 )
 
 type OnlineUser struct {
-	UserId   int
-	UserName string
-	Conn     internal.Conn
-	ChatSrv  ChatService
+	UserId      int64
+	UserName    string
+	Conn        internal.Conn
+	ChatSrv     ChatService
+	ConnectedAt time.Time
 }
 
-func (u *OnlineUser) ReceiveMsg(msg []byte) error {
+func (u *OnlineUser) ReceiveMsg(msg []byte) (err error) {
 	chatMsg, err := u.UnmarshalMsg(msg)
 	if err != nil {
+		u.sendError(&chat_service.ChatMessage{}, service.InvalidArgument("invalid message: %v", err))
 		return err
 	}
 
-	chatMsg.SenderId = int32(u.UserId)
+	chatMsg.SenderId = u.UserId
 	chatMsg.MessageType = chat_service.MessageType_NORMAL
 
-	logrus.WithFields(logrus.Fields{
+	// every message received from the connection starts a new trace
+	ctx, span := tracing.Start(context.Background(), "chat.ReceiveMsg", trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		attribute.Int64("chat.sender_id", u.UserId),
+		attribute.Int64("chat.target_id", chatMsg.TargetId),
+		attribute.Bool("chat.is_user", chatMsg.IsUser),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	logger.Ctx(ctx).WithFields(logrus.Fields{
 		"user_id":   u.UserId,
 		"user_name": u.UserName,
 		"target_id": chatMsg.TargetId,
@@ -35,27 +53,60 @@ func (u *OnlineUser) ReceiveMsg(msg []byte) error {
 	}).Info("received message")
 
 	if chatMsg.IsUser {
-		err = u.ChatSrv.SendMsgToUser(context.Background(), int(chatMsg.TargetId), chatMsg)
+		err = u.ChatSrv.SendMsgToUser(ctx, chatMsg.TargetId, chatMsg)
 	} else {
-		err = u.ChatSrv.SendMsgToRoom(context.Background(), int(chatMsg.TargetId), chatMsg)
+		err = u.ChatSrv.SendMsgToRoom(ctx, chatMsg.TargetId, chatMsg)
 	}
 	if err != nil {
-		logrus.Errorf("failed to send message to user %d, error: %v", u.UserId, err)
+		entry := logger.Ctx(ctx).WithError(err)
+		if service.KindOf(err) == service.KindInternal {
+			entry.Errorf("failed to send message of user %d", u.UserId)
+		} else {
+			entry.Warnf("rejected message of user %d", u.UserId)
+		}
+		u.sendError(chatMsg, err)
 		return err
 	}
 
 	return nil
 }
 
+// sendError tells the user why msg was not delivered by an ERROR frame, the frame
+// has the target of msg so that clients can show it in the right conversation.
+func (u *OnlineUser) sendError(msg *chat_service.ChatMessage, err error) {
+	errMsg := &chat_service.ErrorMessage{
+		Code:    service.KindOf(err).String(),
+		Message: service.MessageOf(err),
+	}
+	var exceeded *ratelimit.ExceededError
+	if errors.As(err, &exceeded) {
+		errMsg.RetryAfterMs = exceeded.RetryAfter.Milliseconds()
+	}
+	frame, err := proto.Marshal(&chat_service.ChatMessage{
+		TargetId:     msg.TargetId,
+		IsUser:       msg.IsUser,
+		MessageType:  chat_service.MessageType_ERROR,
+		ErrorMessage: errMsg,
+	})
+	if err != nil {
+		return
+	}
+	if err := u.Conn.Send(frame); err != nil {
+		logger.WithError(err).Warnf("failed to send error to user %d", u.UserId)
+	}
+}
+
 func (u *OnlineUser) SendMsg(msg *chat_service.ChatMessage) error {
 	marshaledMsg, err := proto.Marshal(msg)
 	if err != nil {
 		return err
 	}
 	if err := u.Conn.Send(marshaledMsg); err != nil {
+		metrics.ChatMessages.WithLabelValues(metrics.MessageDropped).Inc()
 		return err
 	}
-	logrus.WithFields(logrus.Fields{
+	metrics.ChatMessages.WithLabelValues(metrics.MessageSent).Inc()
+	logger.WithFields(logrus.Fields{
 		"user_id":   u.UserId,
 		"user_name": u.UserName,
 		"target_id": msg.TargetId,
@@ -65,6 +116,22 @@ func (u *OnlineUser) SendMsg(msg *chat_service.ChatMessage) error {
 	return nil
 }
 
+// Kick tells the user that an operator disconnected it and closes its connection.
+func (u *OnlineUser) Kick() error {
+	err := u.SendMsg(&chat_service.ChatMessage{
+		TargetId:    u.UserId,
+		IsUser:      true,
+		MessageType: chat_service.MessageType_NOTIFY,
+		NotifyMessage: &chat_service.NotifyMessage{
+			NotifyType: chat_service.NotifyType_KICKED,
+		},
+	})
+	if err != nil {
+		logger.WithError(err).Warnf("failed to notify user %d of the kick", u.UserId)
+	}
+	return u.Conn.Close()
+}
+
 func (u *OnlineUser) PushMsg(msg *chat_service.ChatMessage) error {
 	marshaledMsg, err := proto.Marshal(msg)
 	if err != nil {
@@ -84,7 +151,7 @@ func (u *OnlineUser) UnmarshalMsg(msg []byte) (*chat_service.ChatMessage, error)
 
 // MARK: - types.Unit interface
 
-func (u *OnlineUser) Id() int {
+func (u *OnlineUser) Id() int64 {
 	return u.UserId
 }
 
@@ -92,11 +159,11 @@ func (u *OnlineUser) NickName() string {
 	return u.UserName
 }
 
-func (u *OnlineUser) Role(roomId int) (types.RoleType, error) {
+func (u *OnlineUser) Role(roomId int64) (types.RoleType, error) {
 	return types.Member, nil
 }
 
-func (u *OnlineUser) SetRole(roomId int, role types.RoleType) error {
+func (u *OnlineUser) SetRole(roomId int64, role types.RoleType) error {
 
 	return nil
 }
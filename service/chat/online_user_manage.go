@@ -2,38 +2,38 @@ package chat
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"sync"
 
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"github.com/TheChosenGay/coffee/service"
 	"github.com/TheChosenGay/coffee/service/store"
 )
 
 type OnlineUserService interface {
-	GetOnlineUser(ctx context.Context, userId int) (*OnlineUser, error)
-	OfflineUser(ctx context.Context, userId int) error
+	GetOnlineUser(ctx context.Context, userId int64) (*OnlineUser, error)
+	OfflineUser(ctx context.Context, userId int64) error
 	OnlineUser(ctx context.Context, user *OnlineUser) error
 	GetOnlineUsers() []*OnlineUser
 }
 
 type defaultOnlineUserService struct {
 	mx          sync.Mutex
-	onlineUsers map[int]*OnlineUser
+	onlineUsers map[int64]*OnlineUser
 }
 
 func NewDefaultOnlineUserService(userStore store.UserStore) OnlineUserService {
 	return &defaultOnlineUserService{
-		onlineUsers: make(map[int]*OnlineUser),
+		onlineUsers: make(map[int64]*OnlineUser),
 		mx:          sync.Mutex{},
 	}
 }
 
-func (s *defaultOnlineUserService) GetOnlineUser(ctx context.Context, userId int) (*OnlineUser, error) {
+func (s *defaultOnlineUserService) GetOnlineUser(ctx context.Context, userId int64) (*OnlineUser, error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 	user, ok := s.onlineUsers[userId]
 	if !ok {
-		return nil, errors.New("user not found")
+		return nil, service.NotFound("user %d is not online", userId)
 	}
 	return user, nil
 }
@@ -48,10 +48,11 @@ func (s *defaultOnlineUserService) GetOnlineUsers() []*OnlineUser {
 	return users
 }
 
-func (s *defaultOnlineUserService) OfflineUser(ctx context.Context, userId int) error {
+func (s *defaultOnlineUserService) OfflineUser(ctx context.Context, userId int64) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 	delete(s.onlineUsers, userId)
+	metrics.OnlineUsers.Set(float64(len(s.onlineUsers)))
 	return nil
 }
 
@@ -61,9 +62,10 @@ func (s *defaultOnlineUserService) OnlineUser(ctx context.Context, user *OnlineU
 
 	if _, ok := s.onlineUsers[user.UserId]; ok {
 		s.onlineUsers[user.UserId].Conn.Close()
-		return fmt.Errorf("user %d is already online", user.UserId)
+		return service.Conflict("user %d is already online", user.UserId)
 	}
 
 	s.onlineUsers[user.UserId] = user
+	metrics.OnlineUsers.Set(float64(len(s.onlineUsers)))
 	return nil
 }
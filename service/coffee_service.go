@@ -4,57 +4,53 @@ import (
 	"context"
 	"errors"
 
+	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/TheChosenGay/coffee/types"
 )
 
 type CoffeeService interface {
 	ListCoffees(ctx context.Context) ([]types.Coffee, error)
-	GetCoffeeById(ctx context.Context, id int) (types.Coffee, error)
+	GetCoffeeById(ctx context.Context, id int64) (types.Coffee, error)
 	GetCoffeeByName(ctx context.Context, name string) (types.Coffee, error)
 }
 
 // MOCK: Coffee
-var coffees = []types.Coffee{
+// MockCoffees is the catalog served until coffees are kept in the database.
+var MockCoffees = []types.Coffee{
 	{Id: 1, Name: "Coffee 1", CoverUrl: "https://example.com/coffee1.jpg", Category: "Coffee"},
 	{Id: 2, Name: "Coffee 2", CoverUrl: "https://example.com/coffee2.jpg", Category: "Coffee"},
 	{Id: 3, Name: "Coffee 3", CoverUrl: "https://example.com/coffee3.jpg", Category: "Coffee"},
 }
 
 type coffeeService struct {
+	coffeeStore store.CoffeeStore
 }
 
-func NewCoffeeService() CoffeeService {
-	return &coffeeService{}
+func NewCoffeeService(coffeeStore store.CoffeeStore) CoffeeService {
+	return &coffeeService{coffeeStore: coffeeStore}
 }
 
 func (s *coffeeService) ListCoffees(ctx context.Context) ([]types.Coffee, error) {
-	return coffees, nil
+	return s.coffeeStore.ListCoffees(ctx)
 }
 
-func (s *coffeeService) GetCoffeeById(ctx context.Context, id int) (types.Coffee, error) {
-	var retCoffee *types.Coffee = nil
-	for _, coffee := range coffees {
-		if coffee.Id == id {
-			retCoffee = &coffee
-			break
-		}
-	}
-	if retCoffee == nil {
-		return types.Coffee{}, errors.New("coffee not found")
+func (s *coffeeService) GetCoffeeById(ctx context.Context, id int64) (types.Coffee, error) {
+	coffee, err := s.coffeeStore.GetCoffeeById(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return types.Coffee{}, NotFound("coffee %d not found", id)
 	}
-	return *retCoffee, nil
+	return coffee, err
 }
 
 func (s *coffeeService) GetCoffeeByName(ctx context.Context, name string) (types.Coffee, error) {
-	var retCoffee *types.Coffee = nil
+	coffees, err := s.coffeeStore.ListCoffees(ctx)
+	if err != nil {
+		return types.Coffee{}, err
+	}
 	for _, coffee := range coffees {
 		if coffee.Name == name {
-			retCoffee = &coffee
-			break
+			return coffee, nil
 		}
 	}
-	if retCoffee == nil {
-		return types.Coffee{}, errors.New("coffee not found")
-	}
-	return *retCoffee, nil
+	return types.Coffee{}, NotFound("coffee %q not found", name)
 }
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// ContactService manages the contacts of the users: a user requests another to be its
+// contact, which accepts or declines, and either may remove the other afterwards. A
+// blocked user can neither request nor message the user blocking it.
+type ContactService interface {
+	// SendContactRequest asks contactId to be a contact of userId, the request is
+	// accepted right away when contactId asked userId already.
+	SendContactRequest(ctx context.Context, userId int64, contactId int64) (types.Contact, error)
+	AcceptContactRequest(ctx context.Context, userId int64, requesterId int64) (types.Contact, error)
+	DeclineContactRequest(ctx context.Context, userId int64, requesterId int64) error
+	// RemoveContact removes a contact of both users, or cancels a pending request of userId.
+	RemoveContact(ctx context.Context, userId int64, contactId int64) error
+	// BlockUser ends any relation of the users and rejects the requests and direct
+	// messages of contactId until it is unblocked.
+	BlockUser(ctx context.Context, userId int64, contactId int64) (types.Contact, error)
+	UnblockUser(ctx context.Context, userId int64, contactId int64) error
+
+	// ListContacts lists the relations of userId in state, ordered by contact id.
+	ListContacts(ctx context.Context, userId int64, state types.ContactState) ([]types.Contact, error)
+	// ListContactRequests lists the pending requests to userId.
+	ListContactRequests(ctx context.Context, userId int64) ([]types.Contact, error)
+
+	// CheckDirectMessage fails with an error of kind KindForbidden when senderId may not
+	// send direct messages to targetId.
+	CheckDirectMessage(ctx context.Context, senderId int64, targetId int64) error
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TheChosenGay/coffee/service/store"
+)
+
+// ErrorKind classifies the errors returned by services, the api maps each kind to
+// a http status and a grpc code.
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindNotFound
+	KindConflict
+	KindForbidden
+	KindInvalidArgument
+	KindResourceExhausted
+	KindUnauthenticated
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindForbidden:
+		return "forbidden"
+	case KindInvalidArgument:
+		return "invalid_argument"
+	case KindResourceExhausted:
+		return "resource_exhausted"
+	case KindUnauthenticated:
+		return "unauthenticated"
+	default:
+		return "internal"
+	}
+}
+
+// Error is a domain error whose message can be shown to clients.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	// Err is the cause, it is only logged.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFound(format string, args ...any) error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(format string, args ...any) error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func Forbidden(format string, args ...any) error {
+	return &Error{Kind: KindForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+func InvalidArgument(format string, args ...any) error {
+	return &Error{Kind: KindInvalidArgument, Message: fmt.Sprintf(format, args...)}
+}
+
+func ResourceExhausted(format string, args ...any) error {
+	return &Error{Kind: KindResourceExhausted, Message: fmt.Sprintf(format, args...)}
+}
+
+func Unauthenticated(format string, args ...any) error {
+	return &Error{Kind: KindUnauthenticated, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns err as a domain error of kind, keeping err as the cause.
+func Wrap(kind ErrorKind, err error, format string, args ...any) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// KindOf returns the kind of err, errors of stores which are not wrapped by a service
+// are classified as well so that a missing record is never reported as internal.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return KindNotFound
+	}
+	return KindInternal
+}
+
+// MessageOf returns the client facing message of err, internal errors are not described.
+func MessageOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return store.ErrNotFound.Error()
+	}
+	return "internal error"
+}
@@ -1,35 +1,89 @@
 package service
 
-import "sync/atomic"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
 
 type IdService interface {
-	GenerateId() int
+	GenerateId(ctx context.Context) (int64, error)
 }
 
-type roomIdService struct {
-	id atomic.Int32
-}
+// snowflake layout: 1 unused sign bit | 41 bits timestamp (ms since epoch) | 10 bits node id | 12 bits sequence
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+
+	MaxSnowflakeNodeId   = 1<<snowflakeNodeBits - 1
+	maxSnowflakeSequence = 1<<snowflakeSequenceBits - 1
+)
+
+// SnowflakeEpoch is the custom epoch of snowflake ids, 2025-01-01 00:00:00 UTC.
+var SnowflakeEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type snowflakeIdService struct {
+	nodeId int64
+	// clock is time.Now, replaced by tests
+	clock func() time.Time
 
-func NewRoomIdService() IdService {
-	s := &roomIdService{id: atomic.Int32{}}
-	s.id.Store(0)
-	return s
+	mx       sync.Mutex
+	lastTime int64
+	sequence int64
 }
 
-func (s *roomIdService) GenerateId() int {
-	return int(s.id.Add(1))
+// NewSnowflakeIdService returns an id generator which is collision-free across nodes
+// as long as every node is started with a different node id.
+func NewSnowflakeIdService(nodeId int64) (IdService, error) {
+	if nodeId < 0 || nodeId > MaxSnowflakeNodeId {
+		return nil, fmt.Errorf("snowflake node id must be in [0, %d], got %d", MaxSnowflakeNodeId, nodeId)
+	}
+	return &snowflakeIdService{nodeId: nodeId, clock: time.Now}, nil
 }
 
-type userIdService struct {
-	id atomic.Int32
+func (s *snowflakeIdService) GenerateId(ctx context.Context) (int64, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := s.now()
+	if now < s.lastTime {
+		// clock moved backwards, wait until it catches up instead of reusing ids.
+		if err := s.waitUntil(ctx, s.lastTime); err != nil {
+			return 0, err
+		}
+		now = s.now()
+	}
+
+	if now == s.lastTime {
+		s.sequence = (s.sequence + 1) & maxSnowflakeSequence
+		if s.sequence == 0 {
+			// sequence exhausted in this millisecond
+			if err := s.waitUntil(ctx, s.lastTime+1); err != nil {
+				return 0, err
+			}
+			now = s.now()
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastTime = now
+
+	return now<<(snowflakeNodeBits+snowflakeSequenceBits) | s.nodeId<<snowflakeSequenceBits | s.sequence, nil
 }
 
-func NewUserIdService() IdService {
-	s := &userIdService{id: atomic.Int32{}}
-	s.id.Store(0)
-	return s
+func (s *snowflakeIdService) now() int64 {
+	return s.clock().Sub(SnowflakeEpoch).Milliseconds()
 }
 
-func (s *userIdService) GenerateId() int {
-	return int(s.id.Add(1))
+func (s *snowflakeIdService) waitUntil(ctx context.Context, ms int64) error {
+	for s.now() < ms {
+		select {
+		case <-ctx.Done():
+			return errors.Join(errors.New("snowflake clock is behind"), ctx.Err())
+		case <-time.After(time.Duration(ms-s.now()) * time.Millisecond):
+		}
+	}
+	return nil
 }
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock starts at SnowflakeEpoch plus a second and moves forward by step at every
+// reading.
+type fakeClock struct {
+	mx   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: SnowflakeEpoch.Add(time.Second)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+func (c *fakeClock) set(now time.Time, step time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now, c.step = now, step
+}
+
+func newTestSnowflake(t *testing.T, nodeId int64, clock func() time.Time) *snowflakeIdService {
+	t.Helper()
+	idService, err := NewSnowflakeIdService(nodeId)
+	if err != nil {
+		t.Fatalf("failed to create snowflake: %v", err)
+	}
+	s := idService.(*snowflakeIdService)
+	s.clock = clock
+	return s
+}
+
+func snowflakeTime(id int64) int64 {
+	return id >> (snowflakeNodeBits + snowflakeSequenceBits)
+}
+
+func snowflakeSequence(id int64) int64 {
+	return id & maxSnowflakeSequence
+}
+
+func TestSnowflakeIdsAreOrderedAndUnique(t *testing.T) {
+	s := newTestSnowflake(t, 7, time.Now)
+	seen := map[int64]bool{}
+	var last int64
+	for range 10000 {
+		id, err := s.GenerateId(context.Background())
+		if err != nil {
+			t.Fatalf("failed to generate id: %v", err)
+		}
+		if id <= last || seen[id] {
+			t.Fatalf("id %d after %d is not increasing", id, last)
+		}
+		if node := id >> snowflakeSequenceBits & MaxSnowflakeNodeId; node != 7 {
+			t.Fatalf("id %d has node %d, want 7", id, node)
+		}
+		seen[id] = true
+		last = id
+	}
+}
+
+func TestSnowflakeSequenceRollover(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSnowflake(t, 1, clock.Now)
+
+	var last int64
+	for i := range maxSnowflakeSequence + 1 {
+		id, err := s.GenerateId(context.Background())
+		if err != nil {
+			t.Fatalf("failed to generate id: %v", err)
+		}
+		if snowflakeSequence(id) != int64(i) {
+			t.Fatalf("id %d has sequence %d, want %d", i, snowflakeSequence(id), i)
+		}
+		last = id
+	}
+
+	// the sequence of the millisecond is exhausted, the next id waits for the next one
+	clock.set(clock.Now(), time.Millisecond)
+	id, err := s.GenerateId(context.Background())
+	if err != nil {
+		t.Fatalf("failed to generate id: %v", err)
+	}
+	if snowflakeTime(id) <= snowflakeTime(last) || snowflakeSequence(id) != 0 {
+		t.Fatalf("id %d after rollover of %d: time %d, sequence %d", id, last, snowflakeTime(id), snowflakeSequence(id))
+	}
+}
+
+func TestSnowflakeClockMovesBackwards(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestSnowflake(t, 1, clock.Now)
+	last, err := s.GenerateId(context.Background())
+	if err != nil {
+		t.Fatalf("failed to generate id: %v", err)
+	}
+	start := clock.Now()
+
+	// an id is not generated until the clock catches up
+	clock.set(start.Add(-5*time.Millisecond), 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if id, err := s.GenerateId(ctx); err == nil {
+		t.Fatalf("generated id %d while the clock is behind", id)
+	}
+
+	clock.set(start.Add(-5*time.Millisecond), time.Millisecond)
+	id, err := s.GenerateId(context.Background())
+	if err != nil {
+		t.Fatalf("failed to generate id: %v", err)
+	}
+	if id <= last || snowflakeTime(id) < snowflakeTime(last) {
+		t.Fatalf("id %d after the clock moved backwards is not after %d", id, last)
+	}
+}
@@ -2,15 +2,14 @@ package service
 
 import (
 	"context"
-	"errors"
-	"log"
 
 	"github.com/TheChosenGay/coffee/types"
+	"github.com/sirupsen/logrus"
 )
 
 type LogginService interface {
-	Login(ctx context.Context, userId int) (types.User, error)
-	Logout(ctx context.Context, userId int) error
+	Login(ctx context.Context, userId int64) (types.User, error)
+	Logout(ctx context.Context, userId int64) error
 }
 
 type loggingService struct {
@@ -21,29 +20,33 @@ func NewLoggingService(userService UserService) LogginService {
 	return &loggingService{userService: userService}
 }
 
-func (s *loggingService) Login(ctx context.Context, userId int) (types.User, error) {
+func (s *loggingService) Login(ctx context.Context, userId int64) (types.User, error) {
 	if userId == types.InvalidUserId {
-		return types.User{}, errors.New("invalid user id")
+		return types.User{}, InvalidArgument("invalid user id")
 	}
 	user, err := s.userService.GetUser(ctx, userId)
 	if err != nil {
 		return types.User{UserId: types.InvalidUserId}, err
 	}
-	log.Printf("user(id:%d, nickname:%s) logged in: \n", userId, user.Nickname)
+	logger.Ctx(ctx).WithFields(logrus.Fields{
+		"user_id":   userId,
+		"user_name": user.Nickname,
+	}).Info("user logged in")
 	return user, nil
 }
 
-func (s *loggingService) Logout(ctx context.Context, userId int) error {
+func (s *loggingService) Logout(ctx context.Context, userId int64) error {
 	if userId == types.InvalidUserId {
-		return errors.New("invalid user id")
+		return InvalidArgument("invalid user id")
 	}
 
 	user, err := s.userService.GetUser(ctx, userId)
 	if err != nil {
 		return err
 	}
-	reqId := ctx.Value("requestId")
-
-	log.Printf("requestId: %d, user(id:%d, nickname:%s) logged out: \n", reqId, userId, user.Nickname)
+	logger.Ctx(ctx).WithFields(logrus.Fields{
+		"user_id":   userId,
+		"user_name": user.Nickname,
+	}).Info("user logged out")
 	return nil
 }
@@ -0,0 +1,45 @@
+package manage
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/TheChosenGay/coffee/service"
+)
+
+// auditedAdminService records the actions of the operators on the chat in the audit
+// trail. Bans are recorded by the room service, reads are not audited.
+type auditedAdminService struct {
+	AdminService
+	auditor *service.Auditor
+}
+
+func NewAuditedAdminService(svc AdminService, auditor *service.Auditor) AdminService {
+	return &auditedAdminService{AdminService: svc, auditor: auditor}
+}
+
+func (s *auditedAdminService) DisconnectUser(ctx context.Context, userId int64) error {
+	var before *OnlineUserInfo
+	if users, err := s.AdminService.ListOnlineUsers(ctx); err == nil {
+		for _, user := range users {
+			if user.UserId == userId {
+				before = &user
+			}
+		}
+	}
+	err := s.AdminService.DisconnectUser(ctx, userId)
+	s.auditor.Record(ctx, "disconnect_user", "user:"+strconv.FormatInt(userId, 10), before, nil, err)
+	return err
+}
+
+// announcementAudit is an announcement as recorded by the audit trail.
+type announcementAudit struct {
+	Text  string `json:"text"`
+	Rooms int    `json:"rooms"`
+}
+
+func (s *auditedAdminService) Announce(ctx context.Context, text string) (int, error) {
+	rooms, err := s.AdminService.Announce(ctx, text)
+	s.auditor.Record(ctx, "announce", "rooms", nil, announcementAudit{Text: text, Rooms: rooms}, err)
+	return rooms, err
+}
@@ -0,0 +1,125 @@
+package manage
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/tracing"
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/service/chat"
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// AdminService is the privileged view of the operators on the chat.
+type AdminService interface {
+	ListOnlineUsers(ctx context.Context) ([]OnlineUserInfo, error)
+	ListOnlineRooms(ctx context.Context) ([]OnlineRoomInfo, error)
+	// DisconnectUser closes the connection of an online user, the user may connect again.
+	DisconnectUser(ctx context.Context, userId int64) error
+	// Announce broadcasts text to every online room, it returns the number of rooms reached.
+	Announce(ctx context.Context, text string) (int, error)
+	SetRoomBanned(ctx context.Context, roomId int64, banned bool) error
+	// ListAudit returns the audit trail of the administrative and moderation actions.
+	ListAudit(ctx context.Context, filter store.AuditFilter) ([]types.AuditEntry, error)
+}
+
+type OnlineUserInfo struct {
+	UserId      int64     `json:"user_id"`
+	Nickname    string    `json:"nickname"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+type OnlineRoomInfo struct {
+	RoomId  int64
+	Members []int64
+}
+
+type adminService struct {
+	roomService       service.RoomService
+	onlineUserService chat.OnlineUserService
+	onlineRoomService chat.OnlineRoomService
+	auditor           *service.Auditor
+}
+
+func NewAdminService(roomService service.RoomService, onlineUserService chat.OnlineUserService, onlineRoomService chat.OnlineRoomService, auditor *service.Auditor) AdminService {
+	return &adminService{roomService: roomService, onlineUserService: onlineUserService, onlineRoomService: onlineRoomService, auditor: auditor}
+}
+
+func (s *adminService) ListOnlineUsers(ctx context.Context) ([]OnlineUserInfo, error) {
+	users := []OnlineUserInfo{}
+	for _, user := range s.onlineUserService.GetOnlineUsers() {
+		users = append(users, OnlineUserInfo{
+			UserId:      user.UserId,
+			Nickname:    user.UserName,
+			RemoteAddr:  user.Conn.RemoteAddr(),
+			ConnectedAt: user.ConnectedAt,
+		})
+	}
+	slices.SortFunc(users, func(a, b OnlineUserInfo) int { return a.ConnectedAt.Compare(b.ConnectedAt) })
+	return users, nil
+}
+
+func (s *adminService) ListOnlineRooms(ctx context.Context) ([]OnlineRoomInfo, error) {
+	rooms := []OnlineRoomInfo{}
+	for _, room := range s.onlineRoomService.GetOnlineRooms() {
+		units, err := room.GetUnits(ctx)
+		if err != nil {
+			return nil, err
+		}
+		members := make([]int64, 0, len(units))
+		for _, unit := range units {
+			members = append(members, unit.Id())
+		}
+		slices.Sort(members)
+		rooms = append(rooms, OnlineRoomInfo{RoomId: room.RoomId, Members: members})
+	}
+	slices.SortFunc(rooms, func(a, b OnlineRoomInfo) int { return cmp.Compare(a.RoomId, b.RoomId) })
+	return rooms, nil
+}
+
+func (s *adminService) DisconnectUser(ctx context.Context, userId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.DisconnectUser")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.onlineUserService.GetOnlineUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	// the user goes offline when the ws server sees the connection closed
+	return user.Kick()
+}
+
+func (s *adminService) Announce(ctx context.Context, text string) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.Announce")
+	defer func() { tracing.End(span, err) }()
+
+	rooms := s.onlineRoomService.GetOnlineRooms()
+	for _, room := range rooms {
+		room.BroadcastMsg(&chat_service.ChatMessage{
+			TargetId:    room.RoomId,
+			IsUser:      false,
+			Contents:    []*chat_service.Content{{Content: []string{text}}},
+			MessageType: chat_service.MessageType_NOTIFY,
+			NotifyMessage: &chat_service.NotifyMessage{
+				NotifyType: chat_service.NotifyType_ANNOUNCEMENT,
+			},
+		})
+	}
+	return len(rooms), nil
+}
+
+func (s *adminService) SetRoomBanned(ctx context.Context, roomId int64, banned bool) error {
+	if banned {
+		return s.roomService.BanRoom(ctx, roomId)
+	}
+	return s.roomService.UnBanRoom(ctx, roomId)
+}
+
+func (s *adminService) ListAudit(ctx context.Context, filter store.AuditFilter) ([]types.AuditEntry, error) {
+	return s.auditor.List(ctx, filter)
+}
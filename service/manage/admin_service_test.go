@@ -0,0 +1,134 @@
+package manage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/service/chat"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+type adminFixture struct {
+	admin AdminService
+	audit *fakeAuditStore
+	conns map[int64]*testConn
+}
+
+// newAdminFixture connects the users 1 to 3, 3 first, and makes the rooms 20 of users
+// 2 and 1 and 10 of user 3 online.
+func newAdminFixture(t *testing.T) *adminFixture {
+	t.Helper()
+	ctx := context.Background()
+	users := newFakeUserStore(t, 3, 1, 2)
+	rooms := newFakeRoomStore()
+	onlineUsers := newFakeOnlineUserService()
+	onlineRooms := newFakeOnlineRoomService()
+
+	now := time.Now()
+	conns := map[int64]*testConn{}
+	for _, userId := range []int64{3, 1, 2} {
+		conns[userId] = onlineUsers.connect(userId, now.Add(time.Duration(len(conns))*time.Second))
+	}
+	for roomId, units := range map[int64][]int64{20: {2, 1}, 10: {3}} {
+		if err := rooms.CreateRoom(ctx, types.Room{RoomId: roomId, MaxUnitSize: 10, Units: units}); err != nil {
+			t.Fatalf("failed to create room: %v", err)
+		}
+		room, err := chat.NewOnlineRoom(roomId, rooms, users, onlineUsers)
+		if err != nil {
+			t.Fatalf("failed to make room online: %v", err)
+		}
+		onlineRooms.OnlineRoom(ctx, room)
+	}
+
+	audit := &fakeAuditStore{}
+	auditor := service.NewAuditor(audit)
+	admin := NewAuditedAdminService(NewAdminService(nil, onlineUsers, onlineRooms, auditor), auditor)
+	return &adminFixture{admin: admin, audit: audit, conns: conns}
+}
+
+func TestAdminListOnline(t *testing.T) {
+	f := newAdminFixture(t)
+	ctx := context.Background()
+
+	users, err := f.admin.ListOnlineUsers(ctx)
+	if err != nil {
+		t.Fatalf("failed to list online users: %v", err)
+	}
+	var ids []int64
+	for _, user := range users {
+		ids = append(ids, user.UserId)
+	}
+	// ordered by the time of connection
+	if len(ids) != 3 || ids[0] != 3 || ids[1] != 1 || ids[2] != 2 {
+		t.Fatalf("unexpected online users: %v", ids)
+	}
+	if users[0].RemoteAddr != "10.0.0.1:4000" {
+		t.Fatalf("unexpected remote address: %s", users[0].RemoteAddr)
+	}
+
+	rooms, err := f.admin.ListOnlineRooms(ctx)
+	if err != nil {
+		t.Fatalf("failed to list online rooms: %v", err)
+	}
+	if len(rooms) != 2 || rooms[0].RoomId != 10 || rooms[1].RoomId != 20 {
+		t.Fatalf("unexpected online rooms: %v", rooms)
+	}
+	if members := rooms[1].Members; len(members) != 2 || members[0] != 1 || members[1] != 2 {
+		t.Fatalf("unexpected members of room 20: %v", members)
+	}
+}
+
+func TestAdminDisconnectUser(t *testing.T) {
+	f := newAdminFixture(t)
+	ctx := service.WithActor(context.Background(), "admin:alice")
+
+	if err := f.admin.DisconnectUser(ctx, 1); err != nil {
+		t.Fatalf("failed to disconnect user: %v", err)
+	}
+	f.conns[1].nextFrame(t, isNotify(chat_service.NotifyType_KICKED))
+	if !f.conns[1].isClosed() || f.conns[2].isClosed() {
+		t.Fatalf("expected only the connection of user 1 to be closed")
+	}
+	entry := f.audit.last(t)
+	var before OnlineUserInfo
+	if entry.Action != "disconnect_user" || entry.Target != "user:1" || entry.Actor != "admin:alice" || entry.Error != "" {
+		t.Fatalf("unexpected audit entry: %+v", entry)
+	}
+	if err := json.Unmarshal(entry.Before, &before); err != nil || before.UserId != 1 {
+		t.Fatalf("unexpected user before the disconnection: %s", entry.Before)
+	}
+
+	err := f.admin.DisconnectUser(ctx, 4)
+	if service.KindOf(err) != service.KindNotFound {
+		t.Fatalf("expected offline user not to be found, got %v", err)
+	}
+	if entry := f.audit.last(t); entry.Target != "user:4" || entry.Error == "" || entry.Before != nil {
+		t.Fatalf("failed disconnection not audited: %+v", entry)
+	}
+}
+
+func TestAdminAnnounce(t *testing.T) {
+	f := newAdminFixture(t)
+	ctx := service.WithActor(context.Background(), "admin:alice")
+
+	rooms, err := f.admin.Announce(ctx, "maintenance at noon")
+	if err != nil || rooms != 2 {
+		t.Fatalf("expected 2 rooms announced to, got %d %v", rooms, err)
+	}
+	for userId, roomId := range map[int64]int64{1: 20, 2: 20, 3: 10} {
+		frame := f.conns[userId].nextFrame(t, isNotify(chat_service.NotifyType_ANNOUNCEMENT))
+		if frame.TargetId != roomId || frame.Contents[0].Content[0] != "maintenance at noon" {
+			t.Fatalf("unexpected announcement to user %d: %v", userId, frame)
+		}
+	}
+
+	entry := f.audit.last(t)
+	var after announcementAudit
+	if entry.Action != "announce" || json.Unmarshal(entry.After, &after) != nil || after.Rooms != 2 || after.Text != "maintenance at noon" {
+		t.Fatalf("unexpected audit entry: %+v", entry)
+	}
+}
@@ -0,0 +1,328 @@
+package manage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal/tracing"
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/service/chat"
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	"github.com/sirupsen/logrus"
+)
+
+type ContactServiceOpts struct {
+	// ContactsOnly restricts the direct messages to the contacts of the sender
+	ContactsOnly bool
+}
+
+type contactService struct {
+	// units run the writes of the relations of both users of a pair
+	units             store.UnitOfWork
+	contactStore      store.ContactStore
+	userStore         store.UserStore
+	onlineUserService chat.OnlineUserService
+	opts              ContactServiceOpts
+}
+
+func NewContactService(units store.UnitOfWork, contactStore store.ContactStore, userStore store.UserStore, onlineUserService chat.OnlineUserService, opts ContactServiceOpts) service.ContactService {
+	return &contactService{units: units, contactStore: contactStore, userStore: userStore, onlineUserService: onlineUserService, opts: opts}
+}
+
+func (s *contactService) SendContactRequest(ctx context.Context, userId int64, contactId int64) (_ types.Contact, err error) {
+	ctx, span := tracing.Start(ctx, "ContactService.SendContactRequest")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.checkPair(ctx, userId, contactId); err != nil {
+		return types.Contact{}, err
+	}
+	var contact types.Contact
+	err = s.units.Do(ctx, func(stores store.Stores) error {
+		mine, theirs, err := getPair(ctx, stores.Contacts, userId, contactId)
+		if err != nil {
+			return err
+		}
+		switch {
+		case mine.State == types.ContactBlocked:
+			return service.Forbidden("user %d is blocked, unblock it first", contactId)
+		case theirs.State == types.ContactBlocked:
+			return service.Forbidden("user %d does not accept contact requests from you", contactId)
+		case mine.State == types.ContactFriend:
+			return service.Conflict("user %d is a contact already", contactId)
+		case mine.State == types.ContactRequested:
+			return service.Conflict("a contact request to user %d is pending already", contactId)
+		case theirs.State == types.ContactRequested:
+			contact, err = befriend(ctx, stores.Contacts, userId, contactId)
+			return err
+		}
+		contact = types.Contact{UserId: userId, ContactId: contactId, State: types.ContactRequested, UpdatedAt: time.Now()}
+		return stores.Contacts.PutContact(ctx, contact)
+	})
+	if err != nil {
+		return types.Contact{}, err
+	}
+	if contact.State == types.ContactRequested {
+		if contact, err = s.matchRequests(ctx, contact); err != nil {
+			return types.Contact{}, err
+		}
+	}
+	if contact.State == types.ContactFriend {
+		s.notify(ctx, contactId, userId, chat_service.NotifyType_CONTACT_ACCEPTED)
+	} else {
+		s.notify(ctx, contactId, userId, chat_service.NotifyType_CONTACT_REQUEST)
+	}
+	return contact, nil
+}
+
+// matchRequests makes the users of request contacts when the other one requested too.
+// Users requesting each other at the same time do not see the request of the other in
+// their units, so it is read again once the request is committed: of two crossing
+// requests, at least the one committed last sees the other.
+func (s *contactService) matchRequests(ctx context.Context, request types.Contact) (types.Contact, error) {
+	theirs, err := getContact(ctx, s.contactStore, request.ContactId, request.UserId)
+	if err != nil || theirs.State != types.ContactRequested {
+		return request, err
+	}
+	contact := request
+	err = s.units.Do(ctx, func(stores store.Stores) error {
+		mine, theirs, err := getPair(ctx, stores.Contacts, request.UserId, request.ContactId)
+		if err != nil {
+			return err
+		}
+		// either request may be gone meanwhile, e.g. declined
+		if mine.State != types.ContactRequested || theirs.State != types.ContactRequested {
+			return nil
+		}
+		contact, err = befriend(ctx, stores.Contacts, request.UserId, request.ContactId)
+		return err
+	})
+	return contact, err
+}
+
+func (s *contactService) AcceptContactRequest(ctx context.Context, userId int64, requesterId int64) (_ types.Contact, err error) {
+	ctx, span := tracing.Start(ctx, "ContactService.AcceptContactRequest")
+	defer func() { tracing.End(span, err) }()
+
+	var contact types.Contact
+	err = s.units.Do(ctx, func(stores store.Stores) error {
+		if err := checkRequest(ctx, stores.Contacts, requesterId, userId); err != nil {
+			return err
+		}
+		contact, err = befriend(ctx, stores.Contacts, userId, requesterId)
+		return err
+	})
+	if err != nil {
+		return types.Contact{}, err
+	}
+	s.notify(ctx, requesterId, userId, chat_service.NotifyType_CONTACT_ACCEPTED)
+	return contact, nil
+}
+
+func (s *contactService) DeclineContactRequest(ctx context.Context, userId int64, requesterId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "ContactService.DeclineContactRequest")
+	defer func() { tracing.End(span, err) }()
+
+	// the requester is not told, it sees its request pending until it cancels it
+	return s.units.Do(ctx, func(stores store.Stores) error {
+		if err := checkRequest(ctx, stores.Contacts, requesterId, userId); err != nil {
+			return err
+		}
+		return stores.Contacts.DeleteContact(ctx, requesterId, userId)
+	})
+}
+
+func (s *contactService) RemoveContact(ctx context.Context, userId int64, contactId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "ContactService.RemoveContact")
+	defer func() { tracing.End(span, err) }()
+
+	return s.units.Do(ctx, func(stores store.Stores) error {
+		mine, theirs, err := getPair(ctx, stores.Contacts, userId, contactId)
+		if err != nil {
+			return err
+		}
+		if mine.State != types.ContactFriend && mine.State != types.ContactRequested {
+			return service.NotFound("user %d is not a contact", contactId)
+		}
+		if err := stores.Contacts.DeleteContact(ctx, userId, contactId); err != nil {
+			return err
+		}
+		if theirs.State == types.ContactFriend {
+			return stores.Contacts.DeleteContact(ctx, contactId, userId)
+		}
+		return nil
+	})
+}
+
+func (s *contactService) BlockUser(ctx context.Context, userId int64, contactId int64) (_ types.Contact, err error) {
+	ctx, span := tracing.Start(ctx, "ContactService.BlockUser")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.checkPair(ctx, userId, contactId); err != nil {
+		return types.Contact{}, err
+	}
+	contact := types.Contact{UserId: userId, ContactId: contactId, State: types.ContactBlocked, UpdatedAt: time.Now()}
+	err = s.units.Do(ctx, func(stores store.Stores) error {
+		_, theirs, err := getPair(ctx, stores.Contacts, userId, contactId)
+		if err != nil {
+			return err
+		}
+		if err := stores.Contacts.PutContact(ctx, contact); err != nil {
+			return err
+		}
+		// the blocked user loses the contact and its request, its own block is kept
+		if theirs.State == types.ContactFriend || theirs.State == types.ContactRequested {
+			return stores.Contacts.DeleteContact(ctx, contactId, userId)
+		}
+		return nil
+	})
+	if err != nil {
+		return types.Contact{}, err
+	}
+	return contact, nil
+}
+
+func (s *contactService) UnblockUser(ctx context.Context, userId int64, contactId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "ContactService.UnblockUser")
+	defer func() { tracing.End(span, err) }()
+
+	return s.units.Do(ctx, func(stores store.Stores) error {
+		mine, err := getContact(ctx, stores.Contacts, userId, contactId)
+		if err != nil {
+			return err
+		}
+		if mine.State != types.ContactBlocked {
+			return service.NotFound("user %d is not blocked", contactId)
+		}
+		return stores.Contacts.DeleteContact(ctx, userId, contactId)
+	})
+}
+
+func (s *contactService) ListContacts(ctx context.Context, userId int64, state types.ContactState) (_ []types.Contact, err error) {
+	ctx, span := tracing.Start(ctx, "ContactService.ListContacts")
+	defer func() { tracing.End(span, err) }()
+
+	if err := checkUser(ctx, s.userStore, userId); err != nil {
+		return nil, err
+	}
+	return s.contactStore.ListContacts(ctx, userId, state)
+}
+
+func (s *contactService) ListContactRequests(ctx context.Context, userId int64) (_ []types.Contact, err error) {
+	ctx, span := tracing.Start(ctx, "ContactService.ListContactRequests")
+	defer func() { tracing.End(span, err) }()
+
+	if err := checkUser(ctx, s.userStore, userId); err != nil {
+		return nil, err
+	}
+	return s.contactStore.ListContactRequests(ctx, userId)
+}
+
+func (s *contactService) CheckDirectMessage(ctx context.Context, senderId int64, targetId int64) error {
+	mine, theirs, err := getPair(ctx, s.contactStore, senderId, targetId)
+	if err != nil {
+		return err
+	}
+	switch {
+	case theirs.State == types.ContactBlocked:
+		return service.Forbidden("user %d does not accept messages from you", targetId)
+	case mine.State == types.ContactBlocked:
+		return service.Forbidden("user %d is blocked, unblock it first", targetId)
+	case s.opts.ContactsOnly && mine.State != types.ContactFriend:
+		return service.Forbidden("direct messages are restricted to contacts, user %d is not one", targetId)
+	}
+	return nil
+}
+
+// checkPair checks that the users of a new relation exist and differ.
+func (s *contactService) checkPair(ctx context.Context, userId int64, contactId int64) error {
+	if userId == contactId {
+		return service.InvalidArgument("a user cannot be its own contact")
+	}
+	if err := checkUser(ctx, s.userStore, userId); err != nil {
+		return err
+	}
+	return checkUser(ctx, s.userStore, contactId)
+}
+
+// notify tells the user userId, when it is online, that operatorId acted on its contacts.
+func (s *contactService) notify(ctx context.Context, userId int64, operatorId int64, notifyType chat_service.NotifyType) {
+	user, err := s.onlineUserService.GetOnlineUser(ctx, userId)
+	if err != nil {
+		// offline users list their requests when they come back
+		return
+	}
+	err = user.SendMsg(&chat_service.ChatMessage{
+		SenderId:    operatorId,
+		TargetId:    userId,
+		IsUser:      true,
+		MessageType: chat_service.MessageType_NOTIFY,
+		NotifyMessage: &chat_service.NotifyMessage{
+			NotifyType: notifyType,
+			OperatorId: operatorId,
+		},
+	})
+	if err != nil {
+		logger.Ctx(ctx).WithError(err).WithFields(logrus.Fields{
+			"user_id":     userId,
+			"operator_id": operatorId,
+			"notify_type": notifyType.String(),
+		}).Warn("failed to notify user of its contacts")
+	}
+}
+
+// befriend makes the users contacts of each other and returns the relation of userId.
+// The relations are written in the order of the user ids, so that units befriending
+// the same users wait for each other instead of deadlocking.
+func befriend(ctx context.Context, contacts store.ContactStore, userId int64, contactId int64) (types.Contact, error) {
+	now := time.Now()
+	contact := types.Contact{UserId: userId, ContactId: contactId, State: types.ContactFriend, UpdatedAt: now}
+	reverse := types.Contact{UserId: contactId, ContactId: userId, State: types.ContactFriend, UpdatedAt: now}
+	pair := []types.Contact{contact, reverse}
+	if contactId < userId {
+		pair = []types.Contact{reverse, contact}
+	}
+	for _, relation := range pair {
+		if err := contacts.PutContact(ctx, relation); err != nil {
+			return types.Contact{}, err
+		}
+	}
+	return contact, nil
+}
+
+// checkRequest checks that requesterId has a pending request to userId.
+func checkRequest(ctx context.Context, contacts store.ContactStore, requesterId int64, userId int64) error {
+	request, err := getContact(ctx, contacts, requesterId, userId)
+	if err != nil {
+		return err
+	}
+	if request.State != types.ContactRequested {
+		return service.NotFound("no contact request from user %d", requesterId)
+	}
+	return nil
+}
+
+// getPair returns the relations of userId to contactId and back, a missing relation
+// has no state.
+func getPair(ctx context.Context, contacts store.ContactStore, userId int64, contactId int64) (types.Contact, types.Contact, error) {
+	mine, err := getContact(ctx, contacts, userId, contactId)
+	if err != nil {
+		return types.Contact{}, types.Contact{}, err
+	}
+	theirs, err := getContact(ctx, contacts, contactId, userId)
+	if err != nil {
+		return types.Contact{}, types.Contact{}, err
+	}
+	return mine, theirs, nil
+}
+
+// getContact returns the relation of userId to contactId, without state when there is none.
+func getContact(ctx context.Context, contacts store.ContactStore, userId int64, contactId int64) (types.Contact, error) {
+	contact, err := contacts.GetContact(ctx, userId, contactId)
+	if errors.Is(err, store.ErrNotFound) {
+		return types.Contact{UserId: userId, ContactId: contactId}, nil
+	}
+	return contact, err
+}
@@ -0,0 +1,300 @@
+package manage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/service/chat"
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/memory_store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+type contactFixture struct {
+	contacts store.ContactStore
+	conns    map[int64]*testConn
+}
+
+// newContactService returns a contact service of the users 1 to 3 on contacts, the
+// users 1 and 2 are online.
+func newContactService(t *testing.T, contacts store.ContactStore, opts ContactServiceOpts) (service.ContactService, *contactFixture) {
+	t.Helper()
+	users := newFakeUserStore(t, 1, 2, 3)
+	onlineUsers := newFakeOnlineUserService()
+	f := &contactFixture{contacts: contacts, conns: map[int64]*testConn{}}
+	for _, userId := range []int64{1, 2} {
+		f.conns[userId] = onlineUsers.connect(userId, time.Now())
+	}
+	units := memory_store.NewMemoryUnitOfWork(store.Stores{Users: users, Contacts: contacts})
+	return NewContactService(units, contacts, users, onlineUsers, opts), f
+}
+
+// state returns the state of the relation of userId to contactId, 0 when there is none.
+func (f *contactFixture) state(t *testing.T, userId int64, contactId int64) types.ContactState {
+	t.Helper()
+	contact, err := getContact(context.Background(), f.contacts, userId, contactId)
+	if err != nil {
+		t.Fatalf("failed to get contact: %v", err)
+	}
+	return contact.State
+}
+
+func notifiedBy(notifyType chat_service.NotifyType, operatorId int64) func(*chat_service.ChatMessage) bool {
+	return func(frame *chat_service.ChatMessage) bool {
+		return isNotify(notifyType)(frame) && frame.NotifyMessage.OperatorId == operatorId
+	}
+}
+
+func TestContactRequestAccept(t *testing.T) {
+	contacts, f := newContactService(t, memory_store.NewMemoryContactStore(), ContactServiceOpts{})
+	ctx := context.Background()
+
+	contact, err := contacts.SendContactRequest(ctx, 1, 2)
+	if err != nil || contact.State != types.ContactRequested {
+		t.Fatalf("failed to request contact: %+v %v", contact, err)
+	}
+	f.conns[2].nextFrame(t, notifiedBy(chat_service.NotifyType_CONTACT_REQUEST, 1))
+	if _, err := contacts.SendContactRequest(ctx, 1, 2); service.KindOf(err) != service.KindConflict {
+		t.Fatalf("expected a pending request to conflict, got %v", err)
+	}
+	if _, err := contacts.SendContactRequest(ctx, 1, 1); service.KindOf(err) != service.KindInvalidArgument {
+		t.Fatalf("expected a request to oneself to be invalid, got %v", err)
+	}
+	requests, err := contacts.ListContactRequests(ctx, 2)
+	if err != nil || len(requests) != 1 || requests[0].UserId != 1 {
+		t.Fatalf("unexpected requests: %v %v", requests, err)
+	}
+
+	contact, err = contacts.AcceptContactRequest(ctx, 2, 1)
+	if err != nil || contact.State != types.ContactFriend || contact.ContactId != 1 {
+		t.Fatalf("failed to accept request: %+v %v", contact, err)
+	}
+	f.conns[1].nextFrame(t, notifiedBy(chat_service.NotifyType_CONTACT_ACCEPTED, 2))
+	if f.state(t, 1, 2) != types.ContactFriend || f.state(t, 2, 1) != types.ContactFriend {
+		t.Fatalf("expected the users to be contacts of each other")
+	}
+	if _, err := contacts.AcceptContactRequest(ctx, 2, 1); service.KindOf(err) != service.KindNotFound {
+		t.Fatalf("expected no request left to accept, got %v", err)
+	}
+	friends, err := contacts.ListContacts(ctx, 2, types.ContactFriend)
+	if err != nil || len(friends) != 1 || friends[0].ContactId != 1 {
+		t.Fatalf("unexpected contacts: %v %v", friends, err)
+	}
+}
+
+func TestContactRequestMutual(t *testing.T) {
+	contacts, f := newContactService(t, memory_store.NewMemoryContactStore(), ContactServiceOpts{})
+	ctx := context.Background()
+
+	if _, err := contacts.SendContactRequest(ctx, 1, 2); err != nil {
+		t.Fatalf("failed to request contact: %v", err)
+	}
+	// requesting a user who requested you accepts its request
+	contact, err := contacts.SendContactRequest(ctx, 2, 1)
+	if err != nil || contact.State != types.ContactFriend {
+		t.Fatalf("expected the users to become contacts at once, got %+v %v", contact, err)
+	}
+	f.conns[1].nextFrame(t, notifiedBy(chat_service.NotifyType_CONTACT_ACCEPTED, 2))
+	if f.state(t, 1, 2) != types.ContactFriend || f.state(t, 2, 1) != types.ContactFriend {
+		t.Fatalf("expected the users to be contacts of each other")
+	}
+}
+
+// crossingContactStore holds the first two requests until both were made, as if the
+// users requested each other at the same time.
+type crossingContactStore struct {
+	store.ContactStore
+	requests sync.WaitGroup
+}
+
+func (s *crossingContactStore) PutContact(ctx context.Context, contact types.Contact) error {
+	if contact.State == types.ContactRequested {
+		s.requests.Done()
+		s.requests.Wait()
+	}
+	return s.ContactStore.PutContact(ctx, contact)
+}
+
+func TestContactRequestsCrossing(t *testing.T) {
+	crossing := &crossingContactStore{ContactStore: memory_store.NewMemoryContactStore()}
+	crossing.requests.Add(2)
+	contacts, f := newContactService(t, crossing, ContactServiceOpts{})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, pair := range [][2]int64{{1, 2}, {2, 1}} {
+		wg.Go(func() {
+			if _, err := contacts.SendContactRequest(ctx, pair[0], pair[1]); err != nil {
+				t.Errorf("failed to request contact: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if f.state(t, 1, 2) != types.ContactFriend || f.state(t, 2, 1) != types.ContactFriend {
+		t.Fatalf("expected crossing requests to make the users contacts, got %v and %v", f.state(t, 1, 2), f.state(t, 2, 1))
+	}
+}
+
+func TestContactDeclineRemove(t *testing.T) {
+	contacts, f := newContactService(t, memory_store.NewMemoryContactStore(), ContactServiceOpts{})
+	ctx := context.Background()
+
+	if _, err := contacts.SendContactRequest(ctx, 1, 2); err != nil {
+		t.Fatalf("failed to request contact: %v", err)
+	}
+	if err := contacts.DeclineContactRequest(ctx, 2, 1); err != nil {
+		t.Fatalf("failed to decline request: %v", err)
+	}
+	if f.state(t, 1, 2) != 0 {
+		t.Fatalf("expected the declined request to be deleted")
+	}
+	// the requester is not told
+	f.conns[1].noFrame(t, isNotify(chat_service.NotifyType_CONTACT_ACCEPTED))
+	if err := contacts.DeclineContactRequest(ctx, 2, 1); service.KindOf(err) != service.KindNotFound {
+		t.Fatalf("expected no request left to decline, got %v", err)
+	}
+
+	// the requester cancels its request
+	if _, err := contacts.SendContactRequest(ctx, 1, 2); err != nil {
+		t.Fatalf("failed to request contact: %v", err)
+	}
+	if err := contacts.RemoveContact(ctx, 1, 2); err != nil {
+		t.Fatalf("failed to cancel request: %v", err)
+	}
+	if requests, err := contacts.ListContactRequests(ctx, 2); err != nil || len(requests) != 0 {
+		t.Fatalf("expected the canceled request to be deleted, got %v %v", requests, err)
+	}
+
+	// a contact is removed for both users
+	if _, err := contacts.SendContactRequest(ctx, 1, 2); err != nil {
+		t.Fatalf("failed to request contact: %v", err)
+	}
+	if _, err := contacts.AcceptContactRequest(ctx, 2, 1); err != nil {
+		t.Fatalf("failed to accept request: %v", err)
+	}
+	if err := contacts.RemoveContact(ctx, 2, 1); err != nil {
+		t.Fatalf("failed to remove contact: %v", err)
+	}
+	if f.state(t, 1, 2) != 0 || f.state(t, 2, 1) != 0 {
+		t.Fatalf("expected the contact to be removed for both users")
+	}
+	if err := contacts.RemoveContact(ctx, 2, 1); service.KindOf(err) != service.KindNotFound {
+		t.Fatalf("expected no contact left to remove, got %v", err)
+	}
+}
+
+func TestContactBlock(t *testing.T) {
+	contacts, f := newContactService(t, memory_store.NewMemoryContactStore(), ContactServiceOpts{})
+	ctx := context.Background()
+
+	if _, err := contacts.SendContactRequest(ctx, 1, 2); err != nil {
+		t.Fatalf("failed to request contact: %v", err)
+	}
+	if _, err := contacts.AcceptContactRequest(ctx, 2, 1); err != nil {
+		t.Fatalf("failed to accept request: %v", err)
+	}
+	contact, err := contacts.BlockUser(ctx, 1, 2)
+	if err != nil || contact.State != types.ContactBlocked {
+		t.Fatalf("failed to block user: %+v %v", contact, err)
+	}
+	if f.state(t, 1, 2) != types.ContactBlocked || f.state(t, 2, 1) != 0 {
+		t.Fatalf("expected the blocked user to lose the contact")
+	}
+	if _, err := contacts.SendContactRequest(ctx, 2, 1); service.KindOf(err) != service.KindForbidden {
+		t.Fatalf("expected the blocked user not to request, got %v", err)
+	}
+	if _, err := contacts.SendContactRequest(ctx, 1, 2); service.KindOf(err) != service.KindForbidden {
+		t.Fatalf("expected a blocked user not to be requested, got %v", err)
+	}
+
+	// a pending request of the blocked user is deleted
+	if _, err := contacts.SendContactRequest(ctx, 3, 1); err != nil {
+		t.Fatalf("failed to request contact: %v", err)
+	}
+	if _, err := contacts.BlockUser(ctx, 1, 3); err != nil {
+		t.Fatalf("failed to block user: %v", err)
+	}
+	if requests, err := contacts.ListContactRequests(ctx, 1); err != nil || len(requests) != 0 {
+		t.Fatalf("expected the request of the blocked user to be deleted, got %v %v", requests, err)
+	}
+
+	if err := contacts.UnblockUser(ctx, 1, 2); err != nil {
+		t.Fatalf("failed to unblock user: %v", err)
+	}
+	if f.state(t, 1, 2) != 0 {
+		t.Fatalf("expected the unblocked user to be no contact")
+	}
+	if err := contacts.UnblockUser(ctx, 1, 2); service.KindOf(err) != service.KindNotFound {
+		t.Fatalf("expected the user not to be blocked anymore, got %v", err)
+	}
+	if _, err := contacts.SendContactRequest(ctx, 2, 1); err != nil {
+		t.Fatalf("failed to request the user who unblocked: %v", err)
+	}
+}
+
+func TestCheckDirectMessage(t *testing.T) {
+	ctx := context.Background()
+	for _, contactsOnly := range []bool{false, true} {
+		contacts, _ := newContactService(t, memory_store.NewMemoryContactStore(), ContactServiceOpts{ContactsOnly: contactsOnly})
+		if _, err := contacts.SendContactRequest(ctx, 1, 2); err != nil {
+			t.Fatalf("failed to request contact: %v", err)
+		}
+		if _, err := contacts.AcceptContactRequest(ctx, 2, 1); err != nil {
+			t.Fatalf("failed to accept request: %v", err)
+		}
+		if _, err := contacts.BlockUser(ctx, 3, 1); err != nil {
+			t.Fatalf("failed to block user: %v", err)
+		}
+
+		for _, check := range []struct {
+			senderId, targetId int64
+			allowed            bool
+		}{
+			{1, 2, true},
+			{2, 1, true},
+			// users of whom one blocked the other
+			{1, 3, false},
+			{3, 1, false},
+			// users who are no contacts
+			{2, 3, !contactsOnly},
+		} {
+			err := contacts.CheckDirectMessage(ctx, check.senderId, check.targetId)
+			if check.allowed && err != nil || !check.allowed && service.KindOf(err) != service.KindForbidden {
+				t.Fatalf("contacts only %v: message of %d to %d, expected allowed %v, got %v", contactsOnly, check.senderId, check.targetId, check.allowed, err)
+			}
+		}
+	}
+}
+
+// countingChatService counts the direct messages it is asked to deliver.
+type countingChatService struct {
+	chat.ChatService
+	sent int
+}
+
+func (s *countingChatService) SendMsgToUser(ctx context.Context, userId int64, msg *chat_service.ChatMessage) error {
+	s.sent++
+	return nil
+}
+
+func TestContactChatServiceRejectsBlocked(t *testing.T) {
+	contacts, _ := newContactService(t, memory_store.NewMemoryContactStore(), ContactServiceOpts{})
+	ctx := context.Background()
+	if _, err := contacts.BlockUser(ctx, 2, 1); err != nil {
+		t.Fatalf("failed to block user: %v", err)
+	}
+	inner := &countingChatService{}
+	chatService := chat.NewContactChatService(inner, contacts)
+
+	err := chatService.SendMsgToUser(ctx, 2, &chat_service.ChatMessage{SenderId: 1, TargetId: 2, IsUser: true})
+	if service.KindOf(err) != service.KindForbidden || inner.sent != 0 {
+		t.Fatalf("expected the message of the blocked user to be rejected, got %v", err)
+	}
+	if err := chatService.SendMsgToUser(ctx, 1, &chat_service.ChatMessage{SenderId: 3, TargetId: 1, IsUser: true}); err != nil || inner.sent != 1 {
+		t.Fatalf("expected the message to be delivered, got %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package manage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/internal"
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/service/chat"
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// testConn is the connection of an online user, it keeps the frames sent to the user.
+type testConn struct {
+	userId int64
+	frames chan *chat_service.ChatMessage
+
+	mx     sync.Mutex
+	closed bool
+}
+
+func newTestConn(userId int64) *testConn {
+	return &testConn{userId: userId, frames: make(chan *chat_service.ChatMessage, 16)}
+}
+
+func (c *testConn) Send(msg []byte) error {
+	frame := &chat_service.ChatMessage{}
+	if err := proto.Unmarshal(msg, frame); err != nil {
+		return err
+	}
+	c.frames <- frame
+	return nil
+}
+
+func (c *testConn) Push(msg []byte) { c.Send(msg) }
+
+func (c *testConn) OnRecvMsg(handler internal.HandleMessageFunc) {}
+
+func (c *testConn) Close() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *testConn) isClosed() bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.closed
+}
+
+func (c *testConn) RemoteAddr() string { return "10.0.0.1:4000" }
+
+func (c *testConn) UserId() int64 { return c.userId }
+
+// nextFrame returns the next frame sent to the user matching match.
+func (c *testConn) nextFrame(t *testing.T, match func(*chat_service.ChatMessage) bool) *chat_service.ChatMessage {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case frame := <-c.frames:
+			if match(frame) {
+				return frame
+			}
+		case <-timeout:
+			t.Fatalf("no frame sent to user %d", c.userId)
+			return nil
+		}
+	}
+}
+
+// noFrame fails when a frame matching match was sent to the user.
+func (c *testConn) noFrame(t *testing.T, match func(*chat_service.ChatMessage) bool) {
+	t.Helper()
+	for {
+		select {
+		case frame := <-c.frames:
+			if match(frame) {
+				t.Fatalf("unexpected frame sent to user %d: %v", c.userId, frame)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isNotify(notifyType chat_service.NotifyType) func(*chat_service.ChatMessage) bool {
+	return func(frame *chat_service.ChatMessage) bool {
+		return frame.MessageType == chat_service.MessageType_NOTIFY && frame.NotifyMessage.GetNotifyType() == notifyType
+	}
+}
+
+type fakeOnlineUserService struct {
+	mx    sync.Mutex
+	users map[int64]*chat.OnlineUser
+}
+
+func newFakeOnlineUserService() *fakeOnlineUserService {
+	return &fakeOnlineUserService{users: map[int64]*chat.OnlineUser{}}
+}
+
+// connect makes userId online and returns its connection.
+func (s *fakeOnlineUserService) connect(userId int64, connectedAt time.Time) *testConn {
+	conn := newTestConn(userId)
+	s.OnlineUser(context.Background(), &chat.OnlineUser{Conn: conn, UserId: userId, UserName: "user", ConnectedAt: connectedAt})
+	return conn
+}
+
+func (s *fakeOnlineUserService) GetOnlineUser(ctx context.Context, userId int64) (*chat.OnlineUser, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	user, ok := s.users[userId]
+	if !ok {
+		return nil, service.NotFound("user %d is not online", userId)
+	}
+	return user, nil
+}
+
+func (s *fakeOnlineUserService) OfflineUser(ctx context.Context, userId int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.users, userId)
+	return nil
+}
+
+func (s *fakeOnlineUserService) OnlineUser(ctx context.Context, user *chat.OnlineUser) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.users[user.UserId] = user
+	return nil
+}
+
+func (s *fakeOnlineUserService) GetOnlineUsers() []*chat.OnlineUser {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	users := []*chat.OnlineUser{}
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	return users
+}
+
+type fakeOnlineRoomService struct {
+	mx    sync.Mutex
+	rooms map[int64]*chat.OnlineRoom
+}
+
+func newFakeOnlineRoomService() *fakeOnlineRoomService {
+	return &fakeOnlineRoomService{rooms: map[int64]*chat.OnlineRoom{}}
+}
+
+func (s *fakeOnlineRoomService) GetOnlineRoom(ctx context.Context, roomId int64) (*chat.OnlineRoom, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	room, ok := s.rooms[roomId]
+	if !ok {
+		return nil, service.NotFound("room %d is not online", roomId)
+	}
+	return room, nil
+}
+
+func (s *fakeOnlineRoomService) OnlineRoom(ctx context.Context, room *chat.OnlineRoom) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.rooms[room.RoomId] = room
+	return nil
+}
+
+func (s *fakeOnlineRoomService) OfflineRoom(ctx context.Context, roomId int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.rooms, roomId)
+	return nil
+}
+
+func (s *fakeOnlineRoomService) GetOnlineRooms() []*chat.OnlineRoom {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	rooms := []*chat.OnlineRoom{}
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// fakeUserStore keeps the users in a map, the calls it does not implement panic.
+type fakeUserStore struct {
+	store.UserStore
+	mx    sync.Mutex
+	users map[int64]types.User
+}
+
+// newFakeUserStore stores the users of userIds, named after their id.
+func newFakeUserStore(t *testing.T, userIds ...int64) *fakeUserStore {
+	t.Helper()
+	s := &fakeUserStore{users: map[int64]types.User{}}
+	for _, userId := range userIds {
+		if err := s.StoreUser(context.Background(), types.User{UserId: userId, Nickname: fmt.Sprintf("user %d", userId)}); err != nil {
+			t.Fatalf("failed to store user: %v", err)
+		}
+	}
+	return s
+}
+
+func (s *fakeUserStore) StoreUser(ctx context.Context, user types.User) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.users[user.UserId] = user
+	return nil
+}
+
+func (s *fakeUserStore) GetUser(ctx context.Context, id int64) (types.User, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return types.User{}, store.ErrNotFound
+	}
+	return user, nil
+}
+
+// fakeRoomStore keeps the rooms in a map, the calls it does not implement panic.
+type fakeRoomStore struct {
+	store.RoomStore
+	mx    sync.Mutex
+	rooms map[int64]types.Room
+}
+
+func newFakeRoomStore() *fakeRoomStore {
+	return &fakeRoomStore{rooms: map[int64]types.Room{}}
+}
+
+func (s *fakeRoomStore) CreateRoom(ctx context.Context, room types.Room) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.rooms[room.RoomId] = room
+	return nil
+}
+
+func (s *fakeRoomStore) GetRoom(ctx context.Context, id int64) (types.Room, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	room, ok := s.rooms[id]
+	if !ok {
+		return types.Room{}, store.ErrNotFound
+	}
+	return room, nil
+}
+
+// fakeAuditStore keeps the audit trail in a slice, the calls it does not implement panic.
+type fakeAuditStore struct {
+	store.AuditStore
+	mx      sync.Mutex
+	entries []types.AuditEntry
+}
+
+func (s *fakeAuditStore) AppendAudit(ctx context.Context, entry types.AuditEntry) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// last returns the last entry appended.
+func (s *fakeAuditStore) last(t *testing.T) types.AuditEntry {
+	t.Helper()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if len(s.entries) == 0 {
+		t.Fatalf("nothing audited")
+	}
+	return s.entries[len(s.entries)-1]
+}
@@ -2,8 +2,14 @@ package manage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/internal/tracing"
 	"github.com/TheChosenGay/coffee/service"
 	"github.com/TheChosenGay/coffee/service/chat"
 	"github.com/TheChosenGay/coffee/service/store"
@@ -11,19 +17,53 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+var logger = logging.Package("manage")
+
 type roomService struct {
+	// units run the reads and writes of a room which must be consistent
+	units             store.UnitOfWork
 	roomStore         store.RoomStore
 	userStore         store.UserStore
 	idService         service.IdService
 	onlineRoomService chat.OnlineRoomService
 	onlineUserService chat.OnlineUserService
+	auditor           *service.Auditor
+}
+
+func NewRoomService(units store.UnitOfWork, roomStore store.RoomStore, userStore store.UserStore, idService service.IdService, onlineRoomService chat.OnlineRoomService, onlineUserService chat.OnlineUserService, auditor *service.Auditor) service.RoomService {
+	return &roomService{units: units, roomStore: roomStore, userStore: userStore, idService: idService, onlineRoomService: onlineRoomService, onlineUserService: onlineUserService, auditor: auditor}
+}
+
+// roomAudit is a room as recorded by the audit trail, with its units.
+type roomAudit struct {
+	types.Room
+	Units []int64 `json:"units"`
 }
 
-func NewRoomService(roomStore store.RoomStore, userStore store.UserStore, idService service.IdService, onlineRoomService chat.OnlineRoomService, onlineUserService chat.OnlineUserService) service.RoomService {
-	return &roomService{roomStore: roomStore, userStore: userStore, idService: idService, onlineRoomService: onlineRoomService, onlineUserService: onlineUserService}
+func auditRoom(room types.Room) *roomAudit {
+	return &roomAudit{Room: room, Units: slices.Clone(room.Units)}
 }
 
-func (s *roomService) DeleteRoom(ctx context.Context, roomId int) error {
+func roomTarget(roomId int64) string {
+	return "room:" + strconv.FormatInt(roomId, 10)
+}
+
+// maxRoomWriteAttempts bounds the attempts of a write of a room which keeps being
+// written concurrently.
+const maxRoomWriteAttempts = 5
+
+// writeRoom runs write, which reads and writes the room, in a unit of work. It is run
+// again while the room was written since it was read.
+func (s *roomService) writeRoom(ctx context.Context, roomId int64, write func(stores store.Stores) error) error {
+	for range maxRoomWriteAttempts {
+		if err := s.units.Do(ctx, write); !errors.Is(err, store.ErrConflict) {
+			return err
+		}
+	}
+	return service.Conflict("room %d is written concurrently, retry later", roomId)
+}
+
+func (s *roomService) DeleteRoom(ctx context.Context, roomId int64) error {
 	// room, err := s.roomStore.GetRoom(ctx, roomId)
 	// if err != nil {
 	// 	return err
@@ -38,33 +78,54 @@ func (s *roomService) DeleteRoom(ctx context.Context, roomId int) error {
 	return nil
 }
 
-func (s *roomService) BanRoom(ctx context.Context, roomId int) error {
-	room, err := s.roomStore.GetRoom(ctx, roomId)
-	if err != nil {
-		return err
-	}
-	room.State = types.RoomStateBanned
-	return s.roomStore.UpdateRoom(ctx, room)
+func (s *roomService) BanRoom(ctx context.Context, roomId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "RoomService.BanRoom")
+	defer func() { tracing.End(span, err) }()
+
+	return s.setRoomState(ctx, "ban_room", roomId, types.RoomStateBanned)
 }
 
-func (s *roomService) UnBanRoom(ctx context.Context, roomId int) error {
-	room, err := s.roomStore.GetRoom(ctx, roomId)
-	if err != nil {
-		return err
-	}
-	room.State = types.RoomStateNormal
-	return s.roomStore.UpdateRoom(ctx, room)
+func (s *roomService) UnBanRoom(ctx context.Context, roomId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "RoomService.UnBanRoom")
+	defer func() { tracing.End(span, err) }()
+
+	return s.setRoomState(ctx, "unban_room", roomId, types.RoomStateNormal)
+}
+
+func (s *roomService) setRoomState(ctx context.Context, action string, roomId int64, state types.RoomState) (err error) {
+	var before, after *roomAudit
+	defer func() { s.auditor.Record(ctx, action, roomTarget(roomId), before, after, err) }()
+
+	return s.writeRoom(ctx, roomId, func(stores store.Stores) error {
+		room, err := getRoom(ctx, stores.Rooms, roomId)
+		if err != nil {
+			return err
+		}
+		before = auditRoom(room)
+		room.State = state
+		if err := stores.Rooms.UpdateRoom(ctx, room); err != nil {
+			return err
+		}
+		after = auditRoom(room)
+		return nil
+	})
 }
 
-func (s *roomService) CreateRoomBySize(ctx context.Context, maxUnitSize int) (int, error) {
-	roomId := s.idService.GenerateId()
+func (s *roomService) CreateRoomBySize(ctx context.Context, maxUnitSize int) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "RoomService.CreateRoomBySize")
+	defer func() { tracing.End(span, err) }()
+
+	roomId, err := s.idService.GenerateId(ctx)
+	if err != nil {
+		return types.InvalidRoomId, err
+	}
 	room := types.Room{
 		RoomId:      roomId,
 		State:       types.RoomStateNormal,
 		MaxUnitSize: maxUnitSize,
-		Units:       []int{},
+		Units:       []int64{},
 	}
-	err := s.roomStore.CreateRoom(ctx, room)
+	err = s.roomStore.CreateRoom(ctx, room)
 	if err != nil {
 		return types.InvalidRoomId, err
 	}
@@ -75,7 +136,7 @@ func (s *roomService) ListRoom(ctx context.Context) ([]*types.Room, error) {
 	return s.roomStore.ListRoom(ctx)
 }
 
-func (s *roomService) GetRoomUnits(ctx context.Context, roomId int) ([]types.Unit, error) {
+func (s *roomService) GetRoomUnits(ctx context.Context, roomId int64) ([]types.Unit, error) {
 	onlineRoom, err := s.onlineRoomService.GetOnlineRoom(ctx, roomId)
 	if err != nil {
 		return nil, err
@@ -84,81 +145,134 @@ func (s *roomService) GetRoomUnits(ctx context.Context, roomId int) ([]types.Uni
 
 }
 
-func (s *roomService) JoinRoom(ctx context.Context, roomId int, unitId int) error {
-	if _, err := s.userStore.GetUser(ctx, unitId); err != nil {
-		return fmt.Errorf("user not exist: %d, %w", unitId, err)
+func (s *roomService) JoinRoom(ctx context.Context, roomId int64, unitId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "RoomService.JoinRoom")
+	defer func() { tracing.End(span, err) }()
+
+	var before, after *roomAudit
+	defer func() { s.auditor.Record(ctx, "join_room", roomTarget(roomId), before, after, err) }()
+
+	if err := checkUser(ctx, s.userStore, unitId); err != nil {
+		return err
 	}
-	room, err := s.roomStore.GetRoom(ctx, roomId)
+	room, err := getRoom(ctx, s.roomStore, roomId)
 	if err != nil {
-		logrus.WithError(err).Errorf("Room %d not found", roomId)
-		return fmt.Errorf("Failed To Join Room: %w", err)
+		return err
 	}
 	if room.State == types.RoomStateBanned {
-		return fmt.Errorf("Failed To Join Room: %w", err)
-	}
-
-	if len(room.Units) >= room.MaxUnitSize {
-		return fmt.Errorf("Room Is Fulled")
+		return service.Forbidden("room %d is banned", roomId)
 	}
 
 	onlineRoom, err := s.onlineRoomService.GetOnlineRoom(ctx, roomId)
 	if err != nil {
-		logrus.Warnf("Room %d does not online", roomId)
+		logger.Ctx(ctx).Warnf("Room %d does not online", roomId)
 		onlineRoom, err = chat.NewOnlineRoom(roomId, s.roomStore, s.userStore, s.onlineUserService)
 		if err != nil {
-			logrus.WithError(err).Errorf("Failed To Create Online Room: %d", roomId)
+			logger.Ctx(ctx).WithError(err).Errorf("Failed To Create Online Room: %d", roomId)
 			return fmt.Errorf("Failed To Create Online Room: %w", err)
 		}
 		if err := s.onlineRoomService.OnlineRoom(ctx, onlineRoom); err != nil {
-			logrus.WithError(err).Errorf("Failed To Online Room: %d", roomId)
+			logger.Ctx(ctx).WithError(err).Errorf("Failed To Online Room: %d", roomId)
 			return err
 		}
 	}
 	unit, err := s.onlineUserService.GetOnlineUser(ctx, unitId)
 	if err != nil {
-		return fmt.Errorf("user not online: %d, %w", unitId, err)
+		return err
 	}
 
-	onlineRoom.AddUnit(ctx, unit)
-	// TODO: add sync to room.Units.
-	room.Units = append(room.Units, unitId)
-
-	err = s.roomStore.UpdateRoom(ctx, room)
+	member := types.RoomMember{RoomId: roomId, UserId: unitId, Role: types.Member, JoinedAt: time.Now()}
+	// the user may be deleted and the room banned since they were checked
+	err = s.writeRoom(ctx, roomId, func(stores store.Stores) error {
+		if err := checkUser(ctx, stores.Users, unitId); err != nil {
+			return err
+		}
+		room, err := getRoom(ctx, stores.Rooms, roomId)
+		if err != nil {
+			return err
+		}
+		before = auditRoom(room)
+		if room.State == types.RoomStateBanned {
+			return service.Forbidden("room %d is banned", roomId)
+		}
+		err = stores.Rooms.AddRoomMember(ctx, room, member)
+		if errors.Is(err, store.ErrRoomFull) {
+			return service.Conflict("room %d is full", roomId)
+		}
+		if err != nil {
+			return err
+		}
+		after = auditRoom(room)
+		if !slices.Contains(after.Units, unitId) {
+			after.Units = append(after.Units, unitId)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("Failed To Join Room: %w", err)
+		return err
+	}
+	if err := onlineRoom.AddUnit(ctx, unit); err != nil {
+		return err
 	}
-	logrus.WithFields(logrus.Fields{
+	logger.Ctx(ctx).WithFields(logrus.Fields{
 		"room_id": roomId,
 		"unit_id": unitId,
 	}).Info("joined room successfully")
 	return nil
 }
 
-func (s *roomService) QuitRoom(ctx context.Context, roomId int, unitId int) error {
-	if _, err := s.userStore.GetUser(ctx, unitId); err != nil {
-		return fmt.Errorf("user not found")
-	}
+func (s *roomService) QuitRoom(ctx context.Context, roomId int64, unitId int64) (err error) {
+	ctx, span := tracing.Start(ctx, "RoomService.QuitRoom")
+	defer func() { tracing.End(span, err) }()
 
-	room, err := s.roomStore.GetRoom(ctx, roomId)
-	if err != nil {
-		return fmt.Errorf("Failed To Quit Room: %w", err)
-	}
-	onlineRoom, err := s.onlineRoomService.GetOnlineRoom(ctx, roomId)
+	var before, after *roomAudit
+	defer func() { s.auditor.Record(ctx, "quit_room", roomTarget(roomId), before, after, err) }()
+
+	err = s.units.Do(ctx, func(stores store.Stores) error {
+		if err := checkUser(ctx, stores.Users, unitId); err != nil {
+			return err
+		}
+		room, err := getRoom(ctx, stores.Rooms, roomId)
+		if err != nil {
+			return err
+		}
+		before = auditRoom(room)
+		if err := stores.Rooms.RemoveRoomMember(ctx, roomId, unitId); err != nil {
+			return fmt.Errorf("Failed To Quit Room: %w", err)
+		}
+		after = auditRoom(room)
+		after.Units = s.removeUnit(after.Units, unitId)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("Failed To Quit Room: %w", err)
+		return err
 	}
 
-	onlineRoom.RemoveUnit(ctx, unitId)
-	room.Units = s.removeUnit(room.Units, unitId)
-	err = s.roomStore.UpdateRoom(ctx, room)
-	if err != nil {
-		return fmt.Errorf("Failed To Quit Room: %w", err)
+	// the room is only in memory while some of its members are online
+	if onlineRoom, err := s.onlineRoomService.GetOnlineRoom(ctx, roomId); err == nil {
+		onlineRoom.RemoveUnit(ctx, unitId)
 	}
 	return nil
 }
 
-func (r *roomService) removeUnit(units []int, unitId int) []int {
-	var res []int
+func checkUser(ctx context.Context, users store.UserStore, userId int64) error {
+	_, err := users.GetUser(ctx, userId)
+	if errors.Is(err, store.ErrNotFound) {
+		return service.NotFound("user %d not found", userId)
+	}
+	return err
+}
+
+func getRoom(ctx context.Context, rooms store.RoomStore, roomId int64) (types.Room, error) {
+	room, err := rooms.GetRoom(ctx, roomId)
+	if errors.Is(err, store.ErrNotFound) {
+		return room, service.NotFound("room %d not found", roomId)
+	}
+	return room, err
+}
+
+func (r *roomService) removeUnit(units []int64, unitId int64) []int64 {
+	var res []int64
 	for _, id := range units {
 		if id != unitId {
 			res = append(res, id)
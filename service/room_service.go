@@ -7,15 +7,15 @@ import (
 )
 
 type RoomService interface {
-	CreateRoomBySize(ctx context.Context, maxUnitSize int) (int, error)
+	CreateRoomBySize(ctx context.Context, maxUnitSize int) (int64, error)
 	ListRoom(ctx context.Context) ([]*types.Room, error)
-	DeleteRoom(ctx context.Context, roomId int) error
+	DeleteRoom(ctx context.Context, roomId int64) error
 
-	BanRoom(ctx context.Context, roomId int) error
-	UnBanRoom(ctx context.Context, roomId int) error
+	BanRoom(ctx context.Context, roomId int64) error
+	UnBanRoom(ctx context.Context, roomId int64) error
 
-	JoinRoom(ctx context.Context, roomId int, unitId int) error
-	QuitRoom(ctx context.Context, roomId int, unitId int) error
+	JoinRoom(ctx context.Context, roomId int64, unitId int64) error
+	QuitRoom(ctx context.Context, roomId int64, unitId int64) error
 
-	GetRoomUnits(ctx context.Context, roomId int) ([]types.Unit, error)
+	GetRoomUnits(ctx context.Context, roomId int64) ([]types.Unit, error)
 }
@@ -0,0 +1,30 @@
+package cache_store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/cache_store"
+	"github.com/TheChosenGay/coffee/service/store/memory_store"
+	"github.com/TheChosenGay/coffee/service/store/redis_store"
+	"github.com/TheChosenGay/coffee/service/store/storetest"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("users", func(t *testing.T) {
+		storetest.TestUserStore(t, func(t *testing.T) store.UserStore {
+			cache := redis_store.NewRedisUserStore(redis_store.RedisStoreOpts{Addr: miniredis.RunT(t).Addr()}, time.Minute, time.Second)
+			t.Cleanup(func() { cache.Close() })
+			return cache_store.NewCacheUserStore(cache, memory_store.NewMemoryUserStore())
+		})
+	})
+	t.Run("rooms", func(t *testing.T) {
+		storetest.TestRoomStore(t, func(t *testing.T) store.RoomStore {
+			cache := redis_store.NewRedisRoomStore(redis_store.RedisStoreOpts{Addr: miniredis.RunT(t).Addr()}, time.Minute)
+			t.Cleanup(func() { cache.Close() })
+			return cache_store.NewCacheRoomStore(cache, memory_store.NewMemoryRoomStore())
+		})
+	})
+}
@@ -0,0 +1,123 @@
+package cache_store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/internal/metrics"
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger = logging.Package("cache_store")
+
+// RoomCache is the cache of a CacheRoomStore, it keeps single rooms with their units.
+type RoomCache interface {
+	GetRoom(ctx context.Context, id int64) (types.Room, error)
+	UpdateRoom(ctx context.Context, room types.Room) error
+	DeleteRoom(ctx context.Context, id int64) error
+}
+
+// CacheRoomStore reads rooms through the cache and writes them through to the database.
+// Rooms updated, deleted or whose members changed are dropped from the cache before the
+// write returns, so the next read sees the change. So are rooms whose write conflicted,
+// the cached room may be the stale one. Rooms read from the database are cached before
+// the read returns, a read racing a write may still cache the room as it was before
+// until its ttl. The lists of rooms and members are always read from the database.
+type CacheRoomStore struct {
+	cache RoomCache
+	db    store.RoomStore
+}
+
+func NewCacheRoomStore(cache RoomCache, db store.RoomStore) *CacheRoomStore {
+	if cache == nil || db == nil {
+		panic("cache and db cannot be nil")
+	}
+	return &CacheRoomStore{cache: cache, db: db}
+}
+
+func (s *CacheRoomStore) CreateRoom(ctx context.Context, room types.Room) error {
+	if err := s.db.CreateRoom(ctx, room); err != nil {
+		return err
+	}
+	// a new room is likely joined right away
+	s.fill(ctx, room)
+	return nil
+}
+
+func (s *CacheRoomStore) GetRoom(ctx context.Context, id int64) (types.Room, error) {
+	room, err := s.cache.GetRoom(ctx, id)
+	if err == nil {
+		metrics.CacheRequests.WithLabelValues("room", "get", metrics.CacheHit).Inc()
+		trace.SpanFromContext(ctx).AddEvent("room cache hit")
+		return room, nil
+	}
+	metrics.CacheRequests.WithLabelValues("room", "get", metrics.CacheMiss).Inc()
+	trace.SpanFromContext(ctx).AddEvent("room cache miss")
+
+	room, err = s.db.GetRoom(ctx, id)
+	if err != nil {
+		return room, err
+	}
+	s.fill(ctx, room)
+	return room, nil
+}
+
+func (s *CacheRoomStore) UpdateRoom(ctx context.Context, room types.Room) error {
+	err := s.db.UpdateRoom(ctx, room)
+	if err != nil && !errors.Is(err, store.ErrConflict) {
+		return err
+	}
+	s.invalidate(ctx, room.RoomId)
+	return err
+}
+
+func (s *CacheRoomStore) DeleteRoom(ctx context.Context, id int64) error {
+	if err := s.db.DeleteRoom(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate(ctx, id)
+	return nil
+}
+
+func (s *CacheRoomStore) ListRoom(ctx context.Context) ([]*types.Room, error) {
+	return s.db.ListRoom(ctx)
+}
+
+func (s *CacheRoomStore) AddRoomMember(ctx context.Context, room types.Room, member types.RoomMember) error {
+	err := s.db.AddRoomMember(ctx, room, member)
+	if err != nil && !errors.Is(err, store.ErrConflict) {
+		return err
+	}
+	s.invalidate(ctx, room.RoomId)
+	return err
+}
+
+func (s *CacheRoomStore) RemoveRoomMember(ctx context.Context, roomId int64, userId int64) error {
+	if err := s.db.RemoveRoomMember(ctx, roomId, userId); err != nil {
+		return err
+	}
+	s.invalidate(ctx, roomId)
+	return nil
+}
+
+func (s *CacheRoomStore) ListRoomMembers(ctx context.Context, roomId int64) ([]types.RoomMember, error) {
+	return s.db.ListRoomMembers(ctx, roomId)
+}
+
+// fill caches room, a failure is only logged: the room is read from the database again.
+func (s *CacheRoomStore) fill(ctx context.Context, room types.Room) {
+	if err := s.cache.UpdateRoom(context.WithoutCancel(ctx), room); err != nil {
+		logger.Ctx(ctx).WithError(err).Warnf("failed to cache room %d", room.RoomId)
+	}
+}
+
+// invalidate drops the room from the cache, a failure is only logged: the database has
+// the change already and the cached room expires by its ttl.
+func (s *CacheRoomStore) invalidate(ctx context.Context, id int64) {
+	if err := s.cache.DeleteRoom(context.WithoutCancel(ctx), id); err != nil {
+		logger.Ctx(ctx).WithError(err).Warnf("failed to invalidate cached room %d", id)
+	}
+}
@@ -0,0 +1,99 @@
+package cache_store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/redis_store"
+	"github.com/TheChosenGay/coffee/types"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// countingRoomStore is a database counting its reads.
+type countingRoomStore struct {
+	rooms map[int64]types.Room
+	gets  int
+}
+
+func (s *countingRoomStore) CreateRoom(ctx context.Context, room types.Room) error {
+	s.rooms[room.RoomId] = room
+	return nil
+}
+
+func (s *countingRoomStore) GetRoom(ctx context.Context, id int64) (types.Room, error) {
+	s.gets++
+	room, ok := s.rooms[id]
+	if !ok {
+		return types.Room{}, store.ErrNotFound
+	}
+	return room, nil
+}
+
+func (s *countingRoomStore) DeleteRoom(ctx context.Context, id int64) error {
+	delete(s.rooms, id)
+	return nil
+}
+
+func (s *countingRoomStore) UpdateRoom(ctx context.Context, room types.Room) error {
+	s.rooms[room.RoomId] = room
+	return nil
+}
+
+func (s *countingRoomStore) ListRoom(ctx context.Context) ([]*types.Room, error) {
+	return nil, nil
+}
+
+func (s *countingRoomStore) AddRoomMember(ctx context.Context, room types.Room, member types.RoomMember) error {
+	room = s.rooms[room.RoomId]
+	room.Units = append(room.Units, member.UserId)
+	s.rooms[room.RoomId] = room
+	return nil
+}
+
+func (s *countingRoomStore) RemoveRoomMember(ctx context.Context, roomId int64, userId int64) error {
+	return nil
+}
+
+func (s *countingRoomStore) ListRoomMembers(ctx context.Context, roomId int64) ([]types.RoomMember, error) {
+	return nil, nil
+}
+
+func TestCacheRoomStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache := redis_store.NewRedisRoomStore(redis_store.RedisStoreOpts{Addr: server.Addr()}, time.Minute)
+	defer cache.Close()
+	db := &countingRoomStore{rooms: map[int64]types.Room{}}
+	rooms := NewCacheRoomStore(cache, db)
+	ctx := context.Background()
+
+	if err := rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 3, Units: []int64{7}}); err != nil {
+		t.Fatalf("failed to create room: %v", err)
+	}
+	room, err := rooms.GetRoom(ctx, 1)
+	if err != nil || db.gets != 0 || len(room.Units) != 1 {
+		t.Fatalf("expected the created room from the cache with its units, got %+v, %v after %d reads", room, err, db.gets)
+	}
+
+	if err := rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: 8}); err != nil {
+		t.Fatalf("failed to add member: %v", err)
+	}
+	room, err = rooms.GetRoom(ctx, 1)
+	if err != nil || db.gets != 1 || len(room.Units) != 2 {
+		t.Fatalf("expected the room with its new member from the database, got %+v, %v after %d reads", room, err, db.gets)
+	}
+	if _, err := rooms.GetRoom(ctx, 1); err != nil || db.gets != 1 {
+		t.Fatalf("expected the room to be cached again, got %v after %d reads", err, db.gets)
+	}
+
+	if err := rooms.DeleteRoom(ctx, 1); err != nil {
+		t.Fatalf("failed to delete room: %v", err)
+	}
+	if _, err := rooms.GetRoom(ctx, 1); err != store.ErrNotFound {
+		t.Fatalf("expected the deleted room to be gone, got %v", err)
+	}
+	if ttl := server.TTL(redis_store.RoomRedisKeyPrefix + "1"); ttl != 0 {
+		t.Fatalf("expected the deleted room to be dropped from the cache, ttl %v", ttl)
+	}
+}
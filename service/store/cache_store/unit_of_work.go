@@ -0,0 +1,133 @@
+package cache_store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// CacheUnitOfWork runs the units of work of the database past the caches: their reads
+// must see the writes of their transaction, which the caches do not hold. The users and
+// rooms written by a unit are dropped from the caches once its transaction ended, whether
+// it was committed or rolled back, a concurrent read may have cached them meanwhile.
+type CacheUnitOfWork struct {
+	db    store.UnitOfWork
+	users UserCache
+	rooms RoomCache
+}
+
+func NewCacheUnitOfWork(db store.UnitOfWork, users UserCache, rooms RoomCache) *CacheUnitOfWork {
+	if db == nil || users == nil || rooms == nil {
+		panic("db and caches cannot be nil")
+	}
+	return &CacheUnitOfWork{db: db, users: users, rooms: rooms}
+}
+
+func (u *CacheUnitOfWork) Do(ctx context.Context, fn func(stores store.Stores) error) error {
+	written := &writtenIds{users: map[int64]struct{}{}, rooms: map[int64]struct{}{}}
+	defer u.invalidate(ctx, written)
+	return u.db.Do(ctx, func(stores store.Stores) error {
+		return fn(store.Stores{
+			Users:    &writtenUserStore{UserStore: stores.Users, written: written},
+			Rooms:    &writtenRoomStore{RoomStore: stores.Rooms, written: written},
+			Audit:    stores.Audit,
+			Avatars:  stores.Avatars,
+			Contacts: stores.Contacts,
+		})
+	})
+}
+
+// invalidate drops the written users and rooms from the caches, failures are only logged
+// like the invalidations of the stores.
+func (u *CacheUnitOfWork) invalidate(ctx context.Context, written *writtenIds) {
+	ctx = context.WithoutCancel(ctx)
+	for id := range written.users {
+		if err := u.users.DeleteUser(ctx, id); err != nil {
+			logger.Ctx(ctx).WithError(err).Warnf("failed to invalidate cached user %d", id)
+		}
+	}
+	// users created by the unit are not in the index, it must be rebuilt to list them
+	if len(written.users) > 0 {
+		if err := u.users.InvalidateUserIndex(ctx); err != nil {
+			logger.Ctx(ctx).WithError(err).Error("failed to invalidate the user index of the cache")
+		}
+	}
+	for id := range written.rooms {
+		if err := u.rooms.DeleteRoom(ctx, id); err != nil {
+			logger.Ctx(ctx).WithError(err).Warnf("failed to invalidate cached room %d", id)
+		}
+	}
+}
+
+// writtenIds are the users and rooms written by a unit of work.
+type writtenIds struct {
+	mx    sync.Mutex
+	users map[int64]struct{}
+	rooms map[int64]struct{}
+}
+
+func (w *writtenIds) user(id int64) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	w.users[id] = struct{}{}
+}
+
+func (w *writtenIds) room(id int64) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	w.rooms[id] = struct{}{}
+}
+
+// writtenUserStore records the users written through the store of a unit of work.
+type writtenUserStore struct {
+	store.UserStore
+	written *writtenIds
+}
+
+func (s *writtenUserStore) StoreUser(ctx context.Context, user types.User) error {
+	s.written.user(user.UserId)
+	return s.UserStore.StoreUser(ctx, user)
+}
+
+func (s *writtenUserStore) UpdateUser(ctx context.Context, user types.User) error {
+	s.written.user(user.UserId)
+	return s.UserStore.UpdateUser(ctx, user)
+}
+
+func (s *writtenUserStore) DeleteUser(ctx context.Context, id int64) error {
+	s.written.user(id)
+	return s.UserStore.DeleteUser(ctx, id)
+}
+
+// writtenRoomStore records the rooms written through the store of a unit of work.
+type writtenRoomStore struct {
+	store.RoomStore
+	written *writtenIds
+}
+
+func (s *writtenRoomStore) CreateRoom(ctx context.Context, room types.Room) error {
+	s.written.room(room.RoomId)
+	return s.RoomStore.CreateRoom(ctx, room)
+}
+
+func (s *writtenRoomStore) DeleteRoom(ctx context.Context, id int64) error {
+	s.written.room(id)
+	return s.RoomStore.DeleteRoom(ctx, id)
+}
+
+func (s *writtenRoomStore) UpdateRoom(ctx context.Context, room types.Room) error {
+	s.written.room(room.RoomId)
+	return s.RoomStore.UpdateRoom(ctx, room)
+}
+
+func (s *writtenRoomStore) AddRoomMember(ctx context.Context, room types.Room, member types.RoomMember) error {
+	s.written.room(room.RoomId)
+	return s.RoomStore.AddRoomMember(ctx, room, member)
+}
+
+func (s *writtenRoomStore) RemoveRoomMember(ctx context.Context, roomId int64, userId int64) error {
+	s.written.room(roomId)
+	return s.RoomStore.RemoveRoomMember(ctx, roomId, userId)
+}
@@ -0,0 +1,72 @@
+package cache_store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/memory_store"
+	"github.com/TheChosenGay/coffee/service/store/redis_store"
+	"github.com/TheChosenGay/coffee/types"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestCacheUnitOfWork(t *testing.T) {
+	server := miniredis.RunT(t)
+	opts := redis_store.RedisStoreOpts{Addr: server.Addr()}
+	userCache := redis_store.NewRedisUserStore(opts, time.Minute, time.Second)
+	defer userCache.Close()
+	roomCache := redis_store.NewRedisRoomStore(opts, time.Minute)
+	defer roomCache.Close()
+	db := store.Stores{Users: memory_store.NewMemoryUserStore(), Rooms: memory_store.NewMemoryRoomStore()}
+	users := NewCacheUserStore(userCache, db.Users)
+	rooms := NewCacheRoomStore(roomCache, db.Rooms)
+	units := NewCacheUnitOfWork(memory_store.NewMemoryUnitOfWork(db), userCache, roomCache)
+	ctx := context.Background()
+
+	if err := users.StoreUser(ctx, types.User{UserId: 1, Nickname: "latte"}); err != nil {
+		t.Fatalf("failed to store user: %v", err)
+	}
+	if err := rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 2}); err != nil {
+		t.Fatalf("failed to create room: %v", err)
+	}
+	users.ListUser(ctx)
+	users.Flush(ctx)
+	if _, err := userCache.ListUser(ctx); err != nil {
+		t.Fatalf("expected a complete index, got %v", err)
+	}
+
+	// the written records are dropped even when the unit fails, the cache may hold the
+	// writes of a transaction which was rolled back
+	failed := errors.New("failed")
+	err := units.Do(ctx, func(stores store.Stores) error {
+		room, err := stores.Rooms.GetRoom(ctx, 1)
+		if err != nil {
+			return err
+		}
+		if err := stores.Rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: 1, JoinedAt: time.Now()}); err != nil {
+			return err
+		}
+		if err := stores.Users.StoreUser(ctx, types.User{UserId: 2, Nickname: "mocha"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the error of the unit, got %v", err)
+	}
+	if server.Exists(redis_store.RoomRedisKeyPrefix + "1") {
+		t.Fatal("expected the written room to be dropped from the cache")
+	}
+	if server.Exists(redis_store.UserRedisKeyPrefix + "2") {
+		t.Fatal("expected the written user not to be cached")
+	}
+	if _, err := userCache.ListUser(ctx); !errors.Is(err, store.ErrIncomplete) {
+		t.Fatalf("expected the index to be invalidated, got %v", err)
+	}
+	if !server.Exists(redis_store.UserRedisKeyPrefix + "1") {
+		t.Fatal("expected the users not written by the unit to stay cached")
+	}
+}
@@ -2,17 +2,50 @@ package cache_store
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
 
+	"github.com/TheChosenGay/coffee/internal/metrics"
 	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/TheChosenGay/coffee/types"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
+// UserCache is the cache of a CacheUserStore. Besides single users it lists the users
+// of an index, which is complete once rebuilt from the database and kept complete by
+// the writes of the users. Lists of an incomplete index fail with store.ErrIncomplete.
+type UserCache interface {
+	store.UserStore
+	// StoreMissingUser caches that a user does not exist, GetUser then returns an invalid
+	// user. It must not replace a cached user.
+	StoreMissingUser(ctx context.Context, id int64) error
+	// UserIndexVersion must be read before the users passed to RebuildUserIndex.
+	UserIndexVersion(ctx context.Context) (int64, error)
+	// RebuildUserIndex fails when a user was written since version.
+	RebuildUserIndex(ctx context.Context, version int64, users []types.User) error
+	InvalidateUserIndex(ctx context.Context) error
+}
+
+// CacheUserStore reads users through the cache, users not found are cached as missing.
+// Concurrent misses of a user are read once from the database. Users are written to the
+// cache before the writes return, so that a read right after a write sees it. Lists are served by the
+// cache only while its index holds every user, otherwise they are read from the database
+// and the index is rebuilt in background.
 type CacheUserStore struct {
-	cache store.UserStore
+	cache UserCache
 	db    store.UserStore
+
+	// reads of users missing from the cache, by id
+	reads singleflight.Group
+	// pending index rebuilds which are done in background
+	pending    sync.WaitGroup
+	rebuilding atomic.Bool
 }
 
-func NewCacheUserStore(cache store.UserStore, db store.UserStore) *CacheUserStore {
+func NewCacheUserStore(cache UserCache, db store.UserStore) *CacheUserStore {
 	if cache == nil || db == nil {
 		panic("cache and db cannot be nil")
 	}
@@ -23,53 +56,183 @@ func (s *CacheUserStore) StoreUser(ctx context.Context, user types.User) error {
 	if err := s.db.StoreUser(ctx, user); err != nil {
 		return err
 	}
+	s.fill(ctx, user)
+	return nil
+}
 
-	go func() {
-		s.cache.StoreUser(ctx, user)
-	}()
+func (s *CacheUserStore) UpdateUser(ctx context.Context, user types.User) error {
+	if err := s.db.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	// the cached user is stale, it is dropped when it cannot be replaced
+	ctx = context.WithoutCancel(ctx)
+	if err := s.cache.UpdateUser(ctx, user); err != nil {
+		if err := s.cache.DeleteUser(ctx, user.UserId); err != nil {
+			logger.Ctx(ctx).WithError(err).Warnf("failed to invalidate cached user %d", user.UserId)
+		}
+		s.invalidateIndex(ctx, err)
+	}
 	return nil
 }
 
-func (s *CacheUserStore) DeleteUser(ctx context.Context, id int) error {
+func (s *CacheUserStore) DeleteUser(ctx context.Context, id int64) error {
 	if err := s.db.DeleteUser(ctx, id); err != nil {
 		return err
 	}
-	go func() {
-		s.cache.DeleteUser(ctx, id)
-	}()
+	// the database has the change already, a canceled request does not skip the cache
+	ctx = context.WithoutCancel(ctx)
+	if err := s.cache.DeleteUser(ctx, id); err != nil {
+		s.invalidateIndex(ctx, err)
+	}
 	return nil
 }
 
-func (s *CacheUserStore) GetUser(ctx context.Context, id int) (types.User, error) {
+// fill caches user, it is not canceled by the request.
+func (s *CacheUserStore) fill(ctx context.Context, user types.User) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.cache.StoreUser(ctx, user); err != nil {
+		s.invalidateIndex(ctx, err)
+	}
+}
+
+// invalidateIndex marks the index of the cache incomplete after a write to it failed,
+// so that it does not list a user added or deleted in the database only.
+func (s *CacheUserStore) invalidateIndex(ctx context.Context, writeErr error) {
+	logger.Ctx(ctx).WithError(writeErr).Warn("failed to write user to the cache, invalidating its index")
+	if err := s.cache.InvalidateUserIndex(ctx); err != nil {
+		logger.Ctx(ctx).WithError(err).Error("failed to invalidate the user index of the cache")
+	}
+}
+
+func (s *CacheUserStore) GetUser(ctx context.Context, id int64) (types.User, error) {
 	user, err := s.cache.GetUser(ctx, id)
-	if user.IsValid() && err == nil {
+	if err == nil {
+		metrics.CacheRequests.WithLabelValues("user", "get", metrics.CacheHit).Inc()
+		trace.SpanFromContext(ctx).AddEvent("user cache hit")
+		if !user.IsValid() {
+			return types.User{UserId: types.InvalidUserId}, store.ErrNotFound
+		}
 		return user, nil
 	}
+	metrics.CacheRequests.WithLabelValues("user", "get", metrics.CacheMiss).Inc()
+	trace.SpanFromContext(ctx).AddEvent("user cache miss")
+	if !errors.Is(err, store.ErrNotFound) {
+		logger.Ctx(ctx).WithError(err).Warnf("failed to get user %d from the cache", id)
+	}
 
-	user, err = s.db.GetUser(ctx, id)
-	if err != nil || !user.IsValid() {
-		// if user not found, set invalid user to cache to prevent cache miss
-		user = types.User{UserId: types.InvalidUserId}
+	// the read is shared by the concurrent misses, it is not canceled by the request
+	// which started it but every request stops waiting when it is done
+	read := s.reads.DoChan(strconv.FormatInt(id, 10), func() (any, error) {
+		return s.read(context.WithoutCancel(ctx), id)
+	})
+	select {
+	case result := <-read:
+		if result.Err != nil {
+			return types.User{UserId: types.InvalidUserId}, result.Err
+		}
+		return result.Val.(types.User), nil
+	case <-ctx.Done():
+		return types.User{UserId: types.InvalidUserId}, ctx.Err()
 	}
+}
 
+// read gets the user from the database and caches it, or caches it as missing. It is
+// detached from the requests waiting for it.
+func (s *CacheUserStore) read(ctx context.Context, id int64) (types.User, error) {
+	user, err := s.db.GetUser(ctx, id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !user.IsValid()) {
+		if err := s.cache.StoreMissingUser(ctx, id); err != nil {
+			logger.Ctx(ctx).WithError(err).Warnf("failed to cache user %d as missing", id)
+		}
+		return types.User{UserId: types.InvalidUserId}, store.ErrNotFound
+	}
+	if err != nil {
+		return types.User{UserId: types.InvalidUserId}, err
+	}
+	s.fill(ctx, user)
+	return user, nil
+}
+
+// Flush waits for the pending index rebuilds until ctx is done.
+func (s *CacheUserStore) Flush(ctx context.Context) error {
+	done := make(chan struct{})
 	go func() {
-		s.cache.StoreUser(ctx, user)
+		s.pending.Wait()
+		close(done)
 	}()
-	return user, nil
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (s *CacheUserStore) ListUser(ctx context.Context) ([]types.User, error) {
 	users, err := s.cache.ListUser(ctx)
+	if err == nil {
+		metrics.CacheRequests.WithLabelValues("user", "list", metrics.CacheHit).Inc()
+		return users, nil
+	}
+	metrics.CacheRequests.WithLabelValues("user", "list", metrics.CacheMiss).Inc()
+	if !errors.Is(err, store.ErrIncomplete) {
+		logger.Ctx(ctx).WithError(err).Warn("failed to list users from the cache")
+	}
+
+	// the version is read before the users, so that users written meanwhile fail the rebuild
+	version, versionErr := s.cache.UserIndexVersion(ctx)
+	users, err = s.db.ListUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if len(users) > 0 {
+	if versionErr == nil {
+		s.rebuildIndex(ctx, func(context.Context) (int64, []types.User, error) { return version, users, nil })
+	}
+	return users, nil
+}
+
+func (s *CacheUserStore) ListUserPage(ctx context.Context, afterId int64, limit int) ([]types.User, error) {
+	users, err := s.cache.ListUserPage(ctx, afterId, limit)
+	if err == nil {
+		metrics.CacheRequests.WithLabelValues("user", "list", metrics.CacheHit).Inc()
 		return users, nil
 	}
+	metrics.CacheRequests.WithLabelValues("user", "list", metrics.CacheMiss).Inc()
+	if !errors.Is(err, store.ErrIncomplete) {
+		logger.Ctx(ctx).WithError(err).Warn("failed to list users from the cache")
+	}
 
-	users, err = s.db.ListUser(ctx)
+	users, err = s.db.ListUserPage(ctx, afterId, limit)
 	if err != nil {
 		return nil, err
 	}
+	// a page is not enough to rebuild the index, all the users are read in background
+	s.rebuildIndex(ctx, func(ctx context.Context) (int64, []types.User, error) {
+		version, err := s.cache.UserIndexVersion(ctx)
+		if err != nil {
+			return 0, nil, err
+		}
+		users, err := s.db.ListUser(ctx)
+		return version, users, err
+	})
 	return users, nil
 }
+
+// rebuildIndex rebuilds the index of the cache in background from the users returned by
+// read, one rebuild at a time.
+func (s *CacheUserStore) rebuildIndex(ctx context.Context, read func(ctx context.Context) (int64, []types.User, error)) {
+	if !s.rebuilding.CompareAndSwap(false, true) {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	s.pending.Go(func() {
+		defer s.rebuilding.Store(false)
+		version, users, err := read(ctx)
+		if err == nil {
+			err = s.cache.RebuildUserIndex(ctx, version, users)
+		}
+		if err != nil {
+			logger.Ctx(ctx).WithError(err).Warn("failed to rebuild the user index of the cache")
+		}
+	})
+}
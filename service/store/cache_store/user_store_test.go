@@ -0,0 +1,220 @@
+package cache_store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/redis_store"
+	"github.com/TheChosenGay/coffee/types"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// mapUserStore is a database of users in a map, its gets wait for gate when it is set.
+type mapUserStore struct {
+	users map[int64]types.User
+	lists int
+	gets  atomic.Int32
+	gate  chan struct{}
+}
+
+func (s *mapUserStore) StoreUser(ctx context.Context, user types.User) error {
+	s.users[user.UserId] = user
+	return nil
+}
+
+func (s *mapUserStore) GetUser(ctx context.Context, id int64) (types.User, error) {
+	s.gets.Add(1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	user, ok := s.users[id]
+	if !ok {
+		return types.User{UserId: types.InvalidUserId}, store.ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *mapUserStore) UpdateUser(ctx context.Context, user types.User) error {
+	if _, ok := s.users[user.UserId]; !ok {
+		return store.ErrNotFound
+	}
+	s.users[user.UserId] = user
+	return nil
+}
+
+func (s *mapUserStore) DeleteUser(ctx context.Context, id int64) error {
+	delete(s.users, id)
+	return nil
+}
+
+func (s *mapUserStore) ListUser(ctx context.Context) ([]types.User, error) {
+	s.lists++
+	users := []types.User{}
+	for _, id := range slices.Sorted(maps.Keys(s.users)) {
+		users = append(users, s.users[id])
+	}
+	return users, nil
+}
+
+func (s *mapUserStore) ListUserPage(ctx context.Context, afterId int64, limit int) ([]types.User, error) {
+	users, _ := s.ListUser(ctx)
+	users = slices.DeleteFunc(users, func(user types.User) bool { return user.UserId <= afterId })
+	return users[:min(limit, len(users))], nil
+}
+
+func userIds(users []types.User) []int64 {
+	ids := make([]int64, len(users))
+	for i, user := range users {
+		ids[i] = user.UserId
+	}
+	return ids
+}
+
+func TestCacheUserStoreList(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache := redis_store.NewRedisUserStore(redis_store.RedisStoreOpts{Addr: server.Addr()}, time.Minute, time.Second)
+	defer cache.Close()
+	// snowflake ids are not ordered as their decimal strings
+	db := &mapUserStore{users: map[int64]types.User{}}
+	for _, id := range []int64{9, 10, 7340032405323632640} {
+		db.users[id] = types.User{UserId: id, Nickname: "user"}
+	}
+	users := NewCacheUserStore(cache, db)
+	ctx := context.Background()
+
+	if _, err := cache.ListUser(ctx); !errors.Is(err, store.ErrIncomplete) {
+		t.Fatalf("expected an incomplete index before it is rebuilt, got %v", err)
+	}
+	list, err := users.ListUser(ctx)
+	if err != nil || !slices.Equal(userIds(list), []int64{9, 10, 7340032405323632640}) {
+		t.Fatalf("unexpected users from the database: %v %v", list, err)
+	}
+	users.Flush(ctx)
+
+	page, err := users.ListUserPage(ctx, 0, 2)
+	if err != nil || !slices.Equal(userIds(page), []int64{9, 10}) {
+		t.Fatalf("unexpected first page: %v %v", page, err)
+	}
+	page, err = users.ListUserPage(ctx, 10, 2)
+	if err != nil || !slices.Equal(userIds(page), []int64{7340032405323632640}) {
+		t.Fatalf("unexpected second page: %v %v", page, err)
+	}
+	if db.lists != 1 {
+		t.Fatalf("expected the pages to be served by the cache, the database was listed %d times", db.lists)
+	}
+
+	// writes keep the index complete
+	if err := users.StoreUser(ctx, types.User{UserId: 11}); err != nil {
+		t.Fatalf("failed to store user: %v", err)
+	}
+	if err := users.DeleteUser(ctx, 9); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+	users.Flush(ctx)
+	list, err = cache.ListUser(ctx)
+	if err != nil || !slices.Equal(userIds(list), []int64{10, 11, 7340032405323632640}) {
+		t.Fatalf("unexpected users of the cache after writes: %v %v", list, err)
+	}
+
+	// a user evicted from the cache makes the index incomplete
+	server.Del(redis_store.UserRedisKeyPrefix + "10")
+	if _, err := cache.ListUser(ctx); !errors.Is(err, store.ErrIncomplete) {
+		t.Fatalf("expected an incomplete index after an eviction, got %v", err)
+	}
+}
+
+func TestRedisUserStoreRebuildAfterWrite(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache := redis_store.NewRedisUserStore(redis_store.RedisStoreOpts{Addr: server.Addr()}, time.Minute, time.Second)
+	defer cache.Close()
+	ctx := context.Background()
+
+	version, err := cache.UserIndexVersion(ctx)
+	if err != nil {
+		t.Fatalf("failed to read the version: %v", err)
+	}
+	// a user is written between the read of the database and the rebuild
+	if err := cache.StoreUser(ctx, types.User{UserId: 2}); err != nil {
+		t.Fatalf("failed to store user: %v", err)
+	}
+	if err := cache.RebuildUserIndex(ctx, version, []types.User{{UserId: 1}}); err == nil {
+		t.Fatal("expected the rebuild to fail after a write")
+	}
+	if _, err := cache.ListUser(ctx); !errors.Is(err, store.ErrIncomplete) {
+		t.Fatalf("expected an incomplete index, got %v", err)
+	}
+}
+
+func TestCacheUserStoreGet(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache := redis_store.NewRedisUserStore(redis_store.RedisStoreOpts{Addr: server.Addr()}, time.Minute, time.Second)
+	defer cache.Close()
+	db := &mapUserStore{users: map[int64]types.User{}}
+	users := NewCacheUserStore(cache, db)
+	ctx := context.Background()
+
+	// a missing user is cached as missing until its ttl
+	for range 2 {
+		if _, err := users.GetUser(ctx, 5); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected user 5 not to be found, got %v", err)
+		}
+		users.Flush(ctx)
+	}
+	if gets := db.gets.Load(); gets != 1 {
+		t.Fatalf("expected the missing user to be read once from the database, got %d", gets)
+	}
+	db.users[5] = types.User{UserId: 5}
+	server.FastForward(time.Second)
+	if user, err := users.GetUser(ctx, 5); err != nil || user.UserId != 5 {
+		t.Fatalf("expected user 5 once its miss expired: %v %v", user, err)
+	}
+
+	// a registered user replaces the miss right away
+	if _, err := users.GetUser(ctx, 6); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected user 6 not to be found, got %v", err)
+	}
+	users.Flush(ctx)
+	if err := users.StoreUser(ctx, types.User{UserId: 6}); err != nil {
+		t.Fatalf("failed to store user: %v", err)
+	}
+	users.Flush(ctx)
+	if user, err := users.GetUser(ctx, 6); err != nil || user.UserId != 6 {
+		t.Fatalf("expected user 6 once registered: %v %v", user, err)
+	}
+
+	// concurrent misses are read once
+	db.users[7] = types.User{UserId: 7}
+	db.gets.Store(0)
+	db.gate = make(chan struct{})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if user, err := users.GetUser(ctx, 7); err != nil || user.UserId != 7 {
+				t.Errorf("unexpected user 7: %v %v", user, err)
+			}
+		})
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(db.gate)
+	wg.Wait()
+	if gets := db.gets.Load(); gets != 1 {
+		t.Fatalf("expected concurrent misses to be read once from the database, got %d", gets)
+	}
+
+	// a canceled request stops waiting for the read
+	db.gate = make(chan struct{})
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := users.GetUser(canceled, 8); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the canceled request to fail, got %v", err)
+	}
+	close(db.gate)
+	users.Flush(ctx)
+}
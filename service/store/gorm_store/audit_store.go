@@ -0,0 +1,97 @@
+package gorm_store
+
+import (
+	"context"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	"gorm.io/gorm"
+)
+
+// AuditModel is the table of the audit trail, rows are only inserted.
+type AuditModel struct {
+	Id        int64     `gorm:"primaryKey;autoIncrement"`
+	Actor     string    `gorm:"size:128;index"`
+	Action    string    `gorm:"size:64;index"`
+	Target    string    `gorm:"size:128;index"`
+	RequestId string    `gorm:"size:64"`
+	Before    string    `gorm:"type:text"`
+	After     string    `gorm:"type:text"`
+	Error     string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+type gormAuditStore struct {
+	db *gorm.DB
+}
+
+func NewGormAuditStore(db *gorm.DB) *gormAuditStore {
+	return &gormAuditStore{db: db}
+}
+
+func (s *gormAuditStore) AppendAudit(ctx context.Context, entry types.AuditEntry) error {
+	model := AuditModel{
+		Actor:     entry.Actor,
+		Action:    entry.Action,
+		Target:    entry.Target,
+		RequestId: entry.RequestId,
+		Before:    string(entry.Before),
+		After:     string(entry.After),
+		Error:     entry.Error,
+		CreatedAt: entry.CreatedAt,
+	}
+	return s.db.WithContext(ctx).Create(&model).Error
+}
+
+func (s *gormAuditStore) ListAudit(ctx context.Context, filter store.AuditFilter) ([]types.AuditEntry, error) {
+	query := s.db.WithContext(ctx).Model(&AuditModel{}).Order("id DESC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Target != "" {
+		query = query.Where("target = ?", filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+	if filter.BeforeId > 0 {
+		query = query.Where("id < ?", filter.BeforeId)
+	}
+
+	var models []AuditModel
+	if err := query.Find(&models).Error; err != nil {
+		return nil, err
+	}
+	entries := make([]types.AuditEntry, 0, len(models))
+	for _, model := range models {
+		entries = append(entries, types.AuditEntry{
+			Id:        model.Id,
+			Actor:     model.Actor,
+			Action:    model.Action,
+			Target:    model.Target,
+			RequestId: model.RequestId,
+			Before:    rawJson(model.Before),
+			After:     rawJson(model.After),
+			Error:     model.Error,
+			CreatedAt: model.CreatedAt,
+		})
+	}
+	return entries, nil
+}
+
+func rawJson(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return []byte(s)
+}
@@ -0,0 +1,58 @@
+package gorm_store
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+func TestAuditStoreFiltersAndPages(t *testing.T) {
+	db := SetupDatabase(t)
+	defer func() {
+		os.Remove("test.db")
+	}()
+	audits := NewGormAuditStore(db)
+	ctx := context.Background()
+	start := time.Now().UTC()
+	for i, entry := range []types.AuditEntry{
+		{Actor: "admin:alice", Action: "ban_room", Target: "room:1", Before: []byte(`{"state":0}`), After: []byte(`{"state":1}`)},
+		{Actor: "admin:bob", Action: "delete_user", Target: "user:7", Before: []byte(`{"UserId":7}`)},
+		{Actor: "admin:alice", Action: "unban_room", Target: "room:1"},
+	} {
+		entry.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		if err := audits.AppendAudit(ctx, entry); err != nil {
+			t.Fatalf("failed to append audit entry: %v", err)
+		}
+	}
+
+	entries, err := audits.ListAudit(ctx, store.AuditFilter{Actor: "admin:alice"})
+	if err != nil {
+		t.Fatalf("failed to list audit: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != "unban_room" || entries[1].Action != "ban_room" {
+		t.Fatalf("expected the entries of alice newest first, got %+v", entries)
+	}
+	if string(entries[1].After) != `{"state":1}` || entries[0].Before != nil {
+		t.Fatalf("unexpected before and after: %+v", entries)
+	}
+
+	page, err := audits.ListAudit(ctx, store.AuditFilter{BeforeId: entries[0].Id, Limit: 1})
+	if err != nil {
+		t.Fatalf("failed to list audit: %v", err)
+	}
+	if len(page) != 1 || page[0].Action != "delete_user" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	recent, err := audits.ListAudit(ctx, store.AuditFilter{Since: start.Add(time.Second), Until: start.Add(2 * time.Second)})
+	if err != nil {
+		t.Fatalf("failed to list audit: %v", err)
+	}
+	if len(recent) != 1 || recent[0].Target != "user:7" {
+		t.Fatalf("unexpected entries in time range: %+v", recent)
+	}
+}
@@ -0,0 +1,49 @@
+package gorm_store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AvatarModel is the avatar of a user, replaced by every upload.
+type AvatarModel struct {
+	UserId      int64  `gorm:"primaryKey;autoIncrement:false"`
+	ContentType string `gorm:"size:64"`
+	Data        []byte
+	UpdatedAt   time.Time `gorm:"autoUpdateTime:false"`
+}
+
+type gormAvatarStore struct {
+	db *gorm.DB
+}
+
+func NewGormAvatarStore(db *gorm.DB) *gormAvatarStore {
+	return &gormAvatarStore{db: db}
+}
+
+func (s *gormAvatarStore) StoreAvatar(ctx context.Context, avatar types.Avatar) error {
+	model := AvatarModel(avatar)
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&model).Error
+}
+
+func (s *gormAvatarStore) GetAvatar(ctx context.Context, userId int64) (types.Avatar, error) {
+	var model AvatarModel
+	result := s.db.WithContext(ctx).Where("user_id = ?", userId).First(&model)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return types.Avatar{}, store.ErrNotFound
+	}
+	if result.Error != nil {
+		return types.Avatar{}, result.Error
+	}
+	return types.Avatar(model), nil
+}
+
+func (s *gormAvatarStore) DeleteAvatar(ctx context.Context, userId int64) error {
+	return s.db.WithContext(ctx).Where("user_id = ?", userId).Delete(&AvatarModel{}).Error
+}
@@ -0,0 +1,85 @@
+package gorm_store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/storetest"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// mysqlDsnEnv names the dsn of a mysql database the conformance suite runs against, the
+// suite drops its tables. It is skipped when the variable is not set.
+const mysqlDsnEnv = "COFFEE_TEST_MYSQL_DSN"
+
+func TestConformance(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) *gorm.DB{
+		"sqlite": openSqlite,
+		"mysql":  openMySql,
+	} {
+		t.Run(name, func(t *testing.T) {
+			open(t)
+			t.Run("users", func(t *testing.T) {
+				storetest.TestUserStore(t, func(t *testing.T) store.UserStore { return NewGormUserStore(open(t)) })
+			})
+			t.Run("rooms", func(t *testing.T) {
+				storetest.TestRoomStore(t, func(t *testing.T) store.RoomStore { return NewGormRoomStore(open(t)) })
+			})
+			t.Run("audit", func(t *testing.T) {
+				storetest.TestAuditStore(t, func(t *testing.T) store.AuditStore { return NewGormAuditStore(open(t)) })
+			})
+			t.Run("avatars", func(t *testing.T) {
+				storetest.TestAvatarStore(t, func(t *testing.T) store.AvatarStore { return NewGormAvatarStore(open(t)) })
+			})
+			t.Run("contacts", func(t *testing.T) {
+				storetest.TestContactStore(t, func(t *testing.T) store.ContactStore { return NewGormContactStore(open(t)) })
+			})
+		})
+	}
+}
+
+// openSqlite opens an empty database at the latest schema, closed by the end of the test.
+func openSqlite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), gormConfig())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// as NewSqliteDatabase, concurrent transactions wait for each other
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	return migrateEmpty(t, db)
+}
+
+// openMySql empties the database of mysqlDsnEnv and migrates it to the latest schema.
+func openMySql(t *testing.T) *gorm.DB {
+	dsn := os.Getenv(mysqlDsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", mysqlDsnEnv)
+	}
+	db, err := gorm.Open(mysql.Open(dsn), gormConfig())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	return migrateEmpty(t, db)
+}
+
+func migrateEmpty(t *testing.T, db *gorm.DB) *gorm.DB {
+	t.Cleanup(func() { CloseDatabase(db) })
+	migrator, err := NewSchemaMigrator(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+	if err := migrator.Down(ctx, 0); err != nil {
+		t.Fatalf("failed to empty database: %v", err)
+	}
+	if err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
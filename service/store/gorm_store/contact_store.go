@@ -0,0 +1,73 @@
+package gorm_store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContactModel is the relation of a user to one of its contacts.
+type ContactModel struct {
+	UserId    int64              `gorm:"primaryKey;autoIncrement:false"`
+	ContactId int64              `gorm:"primaryKey;autoIncrement:false;index:idx_contact_models_contact_id_state,priority:1"`
+	State     types.ContactState `gorm:"not null;index:idx_contact_models_contact_id_state,priority:2"`
+	UpdatedAt time.Time          `gorm:"autoUpdateTime:false"`
+}
+
+type gormContactStore struct {
+	db *gorm.DB
+}
+
+func NewGormContactStore(db *gorm.DB) *gormContactStore {
+	return &gormContactStore{db: db}
+}
+
+func (s *gormContactStore) GetContact(ctx context.Context, userId int64, contactId int64) (types.Contact, error) {
+	var model ContactModel
+	result := s.db.WithContext(ctx).Where("user_id = ? AND contact_id = ?", userId, contactId).First(&model)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return types.Contact{}, store.ErrNotFound
+	}
+	if result.Error != nil {
+		return types.Contact{}, result.Error
+	}
+	return types.Contact(model), nil
+}
+
+func (s *gormContactStore) PutContact(ctx context.Context, contact types.Contact) error {
+	model := ContactModel(contact)
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&model).Error
+}
+
+func (s *gormContactStore) DeleteContact(ctx context.Context, userId int64, contactId int64) error {
+	return s.db.WithContext(ctx).Where("user_id = ? AND contact_id = ?", userId, contactId).Delete(&ContactModel{}).Error
+}
+
+func (s *gormContactStore) DeleteUserContacts(ctx context.Context, userId int64) error {
+	return s.db.WithContext(ctx).Where("user_id = ? OR contact_id = ?", userId, userId).Delete(&ContactModel{}).Error
+}
+
+func (s *gormContactStore) ListContacts(ctx context.Context, userId int64, state types.ContactState) ([]types.Contact, error) {
+	var models []ContactModel
+	err := s.db.WithContext(ctx).Where("user_id = ? AND state = ?", userId, state).Order("contact_id").Find(&models).Error
+	return contacts(models), err
+}
+
+func (s *gormContactStore) ListContactRequests(ctx context.Context, userId int64) ([]types.Contact, error) {
+	var models []ContactModel
+	err := s.db.WithContext(ctx).Where("contact_id = ? AND state = ?", userId, types.ContactRequested).Order("user_id").Find(&models).Error
+	return contacts(models), err
+}
+
+func contacts(models []ContactModel) []types.Contact {
+	contacts := make([]types.Contact, len(models))
+	for i, model := range models {
+		contacts[i] = types.Contact(model)
+	}
+	return contacts
+}
@@ -1,14 +1,24 @@
 package gorm_store
 
 import (
+	"context"
 	"fmt"
-	"log"
 
+	"github.com/TheChosenGay/coffee/internal/logging"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
+var logger = logging.Package("gorm_store")
+
+// gormConfig translates the errors of the drivers, e.g. duplicated keys become
+// gorm.ErrDuplicatedKey.
+func gormConfig() *gorm.Config {
+	return &gorm.Config{TranslateError: true}
+}
+
 type MySqlDatabaseOpts struct {
 	Username string
 	Password string
@@ -20,7 +30,7 @@ type MySqlDatabaseOpts struct {
 func NewMySqlDatabase(opts MySqlDatabaseOpts) *gorm.DB {
 	// refer https://github.com/go-sql-driver/mysql#dsn-data-source-name for details
 	dsnWithOutDB := fmt.Sprintf("%s:%s@%s(%s)/?charset=utf8mb4&parseTime=True&loc=Local", opts.Username, opts.Password, opts.Protocol, opts.Addr)
-	log.Println("mysql dsn:", dsnWithOutDB)
+	logger.WithField("addr", opts.Addr).Info("connecting to mysql")
 	db, err := gorm.Open(mysql.Open(dsnWithOutDB), &gorm.Config{})
 	if err != nil {
 		panic(err)
@@ -36,22 +46,54 @@ func NewMySqlDatabase(opts MySqlDatabaseOpts) *gorm.DB {
 	sqlDB.Close()
 
 	dsn := fmt.Sprintf("%s:%s@%s(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", opts.Username, opts.Password, opts.Protocol, opts.Addr, opts.DBName)
-	log.Println("mysql dsn:", dsn)
-	db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err = gorm.Open(mysql.Open(dsn), gormConfig())
 	if err != nil {
 		return nil
 	}
+	useTracing(db)
 	return db
 }
 
+// useTracing creates a span for every query made with a context carrying a span.
+func useTracing(db *gorm.DB) {
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+		panic(fmt.Sprintf("failed to install tracing plugin: %v", err))
+	}
+}
+
+func CloseDatabase(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// PingDatabase checks that the database is reachable, it is used as a health check.
+func PingDatabase(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 type SqliteDatabaseOpts struct {
 	Path string
 }
 
 func NewSqliteDatabase(opts SqliteDatabaseOpts) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(opts.Path), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(opts.Path), gormConfig())
+	if err != nil {
+		panic(err)
+	}
+	// sqlite has a single writer, concurrent transactions would fail as busy instead of
+	// waiting for each other
+	sqlDB, err := db.DB()
 	if err != nil {
 		panic(err)
 	}
+	sqlDB.SetMaxOpenConns(1)
+	useTracing(db)
 	return db
 }
@@ -0,0 +1,109 @@
+package gorm_store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/TheChosenGay/coffee/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdSequenceModel keeps the next unallocated id of every named sequence.
+type IdSequenceModel struct {
+	Name   string `gorm:"primaryKey;size:64"`
+	NextId int64
+}
+
+type GormIdServiceOpts struct {
+	// Name of the sequence, e.g. "user" or "room"
+	Name string
+	// BlockSize is how many ids are reserved from the database at once (hi/lo).
+	BlockSize int64
+	// SeedTable and SeedColumn are used to start a new sequence after the ids already stored,
+	// so existing rows never collide with new ones.
+	SeedTable  string
+	SeedColumn string
+}
+
+type gormIdService struct {
+	db   *gorm.DB
+	opts GormIdServiceOpts
+
+	mx   sync.Mutex
+	next int64 // next id to hand out
+	max  int64 // last id of the reserved block
+}
+
+// NewGormIdService returns an id generator backed by the database. Ids are reserved
+// in blocks, so only one round trip is made every BlockSize ids and ids are never reused after a restart.
+func NewGormIdService(db *gorm.DB, opts GormIdServiceOpts) service.IdService {
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = 100
+	}
+	return &gormIdService{db: db, opts: opts}
+}
+
+func (s *gormIdService) GenerateId(ctx context.Context) (int64, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.next == 0 || s.next > s.max {
+		start, err := s.reserveBlock(ctx)
+		if err != nil {
+			return 0, err
+		}
+		s.next = start
+		s.max = start + s.opts.BlockSize - 1
+	}
+	id := s.next
+	s.next++
+	return id, nil
+}
+
+// reserveBlock moves the sequence forward by one block and returns the first id of the block.
+func (s *gormIdService) reserveBlock(ctx context.Context) (int64, error) {
+	var start int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var seq IdSequenceModel
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", s.opts.Name).First(&seq)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			seed, err := s.seed(tx)
+			if err != nil {
+				return err
+			}
+			// another node may start the sequence meanwhile, its row is kept and locked
+			// instead of failing on the duplicate key
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&IdSequenceModel{Name: s.opts.Name, NextId: seed}).Error; err != nil {
+				return err
+			}
+			result = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", s.opts.Name).First(&seq)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		start = seq.NextId
+		return tx.Model(&IdSequenceModel{}).Where("name = ?", s.opts.Name).Update("next_id", seq.NextId+s.opts.BlockSize).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve ids of sequence %s: %w", s.opts.Name, err)
+	}
+	return start, nil
+}
+
+func (s *gormIdService) seed(tx *gorm.DB) (int64, error) {
+	if s.opts.SeedTable == "" || s.opts.SeedColumn == "" || !tx.Migrator().HasTable(s.opts.SeedTable) {
+		return 1, nil
+	}
+	var maxId *int64
+	if err := tx.Table(s.opts.SeedTable).Select(fmt.Sprintf("MAX(%s)", s.opts.SeedColumn)).Scan(&maxId).Error; err != nil {
+		return 0, err
+	}
+	if maxId == nil {
+		return 1, nil
+	}
+	return *maxId + 1, nil
+}
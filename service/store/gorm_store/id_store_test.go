@@ -0,0 +1,44 @@
+package gorm_store
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/types"
+)
+
+func TestGormIdServiceSeedsFromExistingRows(t *testing.T) {
+	db := SetupDatabase(t)
+	defer func() {
+		os.Remove("test.db")
+	}()
+	store := NewGormUserStore(db)
+	if err := store.StoreUser(context.Background(), types.User{UserId: 42, Nickname: "test"}); err != nil {
+		t.Fatalf("failed to store user: %v", err)
+	}
+
+	opts := GormIdServiceOpts{Name: "user", BlockSize: 2, SeedTable: "user_models", SeedColumn: "user_id"}
+	idService := NewGormIdService(db, opts)
+	seen := map[int64]bool{}
+	for i := 0; i < 3; i++ {
+		id, err := idService.GenerateId(context.Background())
+		if err != nil {
+			t.Fatalf("failed to generate id: %v", err)
+		}
+		if id <= 42 || seen[id] {
+			t.Fatalf("id %d collides with existing ids", id)
+		}
+		seen[id] = true
+	}
+
+	// a restarted service must continue after the blocks reserved before.
+	restarted := NewGormIdService(db, opts)
+	id, err := restarted.GenerateId(context.Background())
+	if err != nil {
+		t.Fatalf("failed to generate id: %v", err)
+	}
+	if seen[id] {
+		t.Fatalf("id %d is reused after restart", id)
+	}
+}
@@ -0,0 +1,308 @@
+package gorm_store
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles are the migrations of every dialect, migrations/<dialect>/NNNN_name.up.sql
+// and its NNNN_name.down.sql. Versions start at 1 and have no gaps, the first one is the
+// schema created by AutoMigrate before the schema was versioned.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	// ErrSchemaBehind is returned by Check when migrations are pending.
+	ErrSchemaBehind = errors.New("database schema is behind, run `coffee migrate up`")
+	// ErrSchemaAhead is returned by Check when the database was migrated by a newer
+	// release, it must be migrated down by that release before running this one.
+	ErrSchemaAhead = errors.New("database schema is ahead of this release")
+)
+
+// Migration is a numbered change of the schema, Down reverts Up.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// SchemaMigrationModel records an applied migration.
+type SchemaMigrationModel struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (SchemaMigrationModel) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus is a migration and when it was applied, nil when it is pending.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// SchemaMigrator applies the migrations of the dialect of a database. Every migration is
+// applied in a transaction with its record, sqlite rolls back a failed migration but
+// mysql commits every DDL statement: a failed mysql migration must be fixed by hand.
+type SchemaMigrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewSchemaMigrator(db *gorm.DB) (*SchemaMigrator, error) {
+	migrations, err := loadMigrations(migrationFiles, path.Join("migrations", db.Dialector.Name()))
+	if err != nil {
+		return nil, err
+	}
+	return &SchemaMigrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations reads the migrations of dir ordered by version.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", path.Base(dir), err)
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = splitStatements(string(data))
+		} else {
+			migration.Down = splitStatements(string(data))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version := 1; version <= len(byVersion); version++ {
+		migration := byVersion[version]
+		if migration == nil {
+			return nil, fmt.Errorf("migration %d is missing", version)
+		}
+		if len(migration.Up) == 0 || len(migration.Down) == 0 {
+			return nil, fmt.Errorf("migration %d requires up and down statements", version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	return migrations, nil
+}
+
+// splitStatements splits a migration file in statements, a statement ends by a ; at the
+// end of a line. Comment lines are dropped.
+func splitStatements(sql string) []string {
+	var statements []string
+	var statement strings.Builder
+	for line := range strings.Lines(sql) {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		statement.WriteString(line)
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(statement.String()))
+			statement.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(statement.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// Latest returns the version of the last migration.
+func (m *SchemaMigrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version returns the version of the schema, 0 before the first migration.
+func (m *SchemaMigrator) Version(ctx context.Context) (int, error) {
+	db := m.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&SchemaMigrationModel{}) {
+		return 0, nil
+	}
+	var version *int
+	if err := db.Model(&SchemaMigrationModel{}).Select("MAX(version)").Scan(&version).Error; err != nil {
+		return 0, err
+	}
+	if version == nil {
+		return 0, nil
+	}
+	return *version, nil
+}
+
+// Status lists the migrations and the migrations applied by a newer release.
+func (m *SchemaMigrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := MigrationStatus{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		status = append(status, s)
+	}
+	for _, version := range slices.Sorted(maps.Keys(applied)) {
+		record := applied[version]
+		status = append(status, MigrationStatus{Migration: Migration{Version: version, Name: record.Name}, AppliedAt: &record.AppliedAt})
+	}
+	return status, nil
+}
+
+func (m *SchemaMigrator) applied(ctx context.Context) (map[int]SchemaMigrationModel, error) {
+	applied := map[int]SchemaMigrationModel{}
+	db := m.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&SchemaMigrationModel{}) {
+		return applied, nil
+	}
+	var records []SchemaMigrationModel
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// Check fails unless the schema is at the latest version, the stores must not run against
+// a schema they were not written for.
+func (m *SchemaMigrator) Check(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case version < m.Latest():
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaBehind, version, m.Latest())
+	case version > m.Latest():
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaAhead, version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies the migrations up to version target, the latest when target is 0.
+func (m *SchemaMigrator) Up(ctx context.Context, target int) error {
+	if target == 0 {
+		target = m.Latest()
+	}
+	if target < 0 || target > m.Latest() {
+		return fmt.Errorf("unknown schema version %d", target)
+	}
+	if err := m.init(ctx); err != nil {
+		return err
+	}
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version > m.Latest() {
+		return fmt.Errorf("%w: version %d", ErrSchemaAhead, version)
+	}
+	for _, migration := range m.migrations[min(version, target):target] {
+		err := m.apply(ctx, migration.Up, func(tx *gorm.DB) error {
+			return tx.Create(&SchemaMigrationModel{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		logger.WithField("version", migration.Version).Infof("applied migration %s", migration.Name)
+	}
+	return nil
+}
+
+// Down reverts the migrations down to version target, 0 reverts all of them.
+func (m *SchemaMigrator) Down(ctx context.Context, target int) error {
+	if target < 0 {
+		return fmt.Errorf("unknown schema version %d", target)
+	}
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version > m.Latest() {
+		return fmt.Errorf("%w: version %d, migrate down with the release which migrated up", ErrSchemaAhead, version)
+	}
+	for i := version - 1; i >= target; i-- {
+		migration := m.migrations[i]
+		err := m.apply(ctx, migration.Down, func(tx *gorm.DB) error {
+			return tx.Delete(&SchemaMigrationModel{}, migration.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to revert migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		logger.WithField("version", migration.Version).Infof("reverted migration %s", migration.Name)
+	}
+	return nil
+}
+
+// apply runs the statements of a migration and records it in a transaction.
+func (m *SchemaMigrator) apply(ctx context.Context, statements []string, record func(tx *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
+
+// init creates the table of the applied migrations. A database created by AutoMigrate
+// before the schema was versioned is brought to the first version and recorded at it.
+func (m *SchemaMigrator) init(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	if db.Migrator().HasTable(&SchemaMigrationModel{}) {
+		return nil
+	}
+	legacy := db.Migrator().HasTable("user_models")
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&SchemaMigrationModel{}); err != nil {
+			return err
+		}
+		if !legacy {
+			return nil
+		}
+		if err := adoptLegacySchema(tx); err != nil {
+			return fmt.Errorf("failed to adopt the schema created before it was versioned: %w", err)
+		}
+		logger.Info("adopted the schema created before it was versioned")
+		return tx.Create(&SchemaMigrationModel{Version: 1, Name: m.migrations[0].Name, AppliedAt: time.Now()}).Error
+	})
+}
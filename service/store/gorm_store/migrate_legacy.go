@@ -0,0 +1,101 @@
+package gorm_store
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The models as AutoMigrate created them before the schema was versioned, they are
+// frozen: the current models follow the migrations.
+
+type legacyUserModel struct {
+	gorm.Model
+	UserId   int64
+	Nickname string
+	Sex      int
+	Age      int
+	Birthday int64
+}
+
+func (legacyUserModel) TableName() string { return "user_models" }
+
+type legacyRoomModel struct {
+	gorm.Model
+	RoomId      int64
+	MaxUnitSize int
+	State       int
+	Version     int64 `gorm:"not null;default:0"`
+}
+
+func (legacyRoomModel) TableName() string { return "room_models" }
+
+type legacyRoomMemberModel struct {
+	RoomId   int64 `gorm:"primaryKey;autoIncrement:false"`
+	UserId   int64 `gorm:"primaryKey;autoIncrement:false;index:idx_room_member_models_user_id"`
+	Role     int
+	JoinedAt time.Time
+}
+
+func (legacyRoomMemberModel) TableName() string { return "room_member_models" }
+
+type legacyAuditModel struct {
+	Id        int64     `gorm:"primaryKey;autoIncrement"`
+	Actor     string    `gorm:"size:128;index:idx_audit_models_actor"`
+	Action    string    `gorm:"size:64;index:idx_audit_models_action"`
+	Target    string    `gorm:"size:128;index:idx_audit_models_target"`
+	RequestId string    `gorm:"size:64"`
+	Before    string    `gorm:"type:text"`
+	After     string    `gorm:"type:text"`
+	Error     string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"index:idx_audit_models_created_at"`
+}
+
+func (legacyAuditModel) TableName() string { return "audit_models" }
+
+type legacyIdSequenceModel struct {
+	Name   string `gorm:"primaryKey;size:64"`
+	NextId int64
+}
+
+func (legacyIdSequenceModel) TableName() string { return "id_sequence_models" }
+
+// legacyMemberRole is the value of types.Member when the units were moved to the
+// members, it is frozen like the models.
+const legacyMemberRole = 2
+
+// adoptLegacySchema brings a schema created by AutoMigrate, by any release before the
+// schema was versioned, to the first migration.
+func adoptLegacySchema(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(&legacyUserModel{}, &legacyRoomModel{}, &legacyRoomMemberModel{}, &legacyAuditModel{}, &legacyIdSequenceModel{}); err != nil {
+		return err
+	}
+	return migrateRoomUnits(tx)
+}
+
+// migrateRoomUnits moves the units of the rooms, which were a json column of the rooms,
+// to their members.
+func migrateRoomUnits(tx *gorm.DB) error {
+	if !tx.Migrator().HasColumn(&legacyRoomModel{}, "units") {
+		return nil
+	}
+	var rooms []struct {
+		RoomId int64
+		Units  []int64 `gorm:"serializer:json"`
+	}
+	if err := tx.Model(&legacyRoomModel{}).Unscoped().Select("room_id", "units").Find(&rooms).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, room := range rooms {
+		for _, userId := range room.Units {
+			member := legacyRoomMemberModel{RoomId: room.RoomId, UserId: userId, Role: legacyMemberRole, JoinedAt: now}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+				return err
+			}
+		}
+	}
+	logger.WithField("rooms", len(rooms)).Info("moved the units of the rooms to their members")
+	return tx.Migrator().DropColumn(&legacyRoomModel{}, "units")
+}
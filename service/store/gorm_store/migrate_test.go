@@ -0,0 +1,184 @@
+package gorm_store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSchemaMigrator(t *testing.T) {
+	db := SetupDatabase(t)
+	defer func() {
+		os.Remove("test.db")
+	}()
+	migrator, err := NewSchemaMigrator(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("expected the latest schema, got %v", err)
+	}
+
+	if err := migrator.Down(ctx, 0); err != nil {
+		t.Fatalf("failed to revert migrations: %v", err)
+	}
+	if db.Migrator().HasTable("user_models") {
+		t.Fatal("expected the tables to be dropped")
+	}
+	if err := migrator.Check(ctx); !errors.Is(err, ErrSchemaBehind) {
+		t.Fatalf("expected the schema to be behind, got %v", err)
+	}
+	if err := migrator.Up(ctx, 1); err != nil {
+		t.Fatalf("failed to apply the first migration: %v", err)
+	}
+	if version, err := migrator.Version(ctx); err != nil || version != 1 {
+		t.Fatalf("expected version 1, got %d %v", version, err)
+	}
+	if err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	// a newer release migrated the schema
+	if err := db.Create(&SchemaMigrationModel{Version: migrator.Latest() + 1, Name: "future"}).Error; err != nil {
+		t.Fatalf("failed to record migration: %v", err)
+	}
+	if err := migrator.Check(ctx); !errors.Is(err, ErrSchemaAhead) {
+		t.Fatalf("expected the schema to be ahead, got %v", err)
+	}
+	status, err := migrator.Status(ctx)
+	if err != nil || len(status) != migrator.Latest()+1 || status[len(status)-1].Name != "future" {
+		t.Fatalf("unexpected status: %+v %v", status, err)
+	}
+}
+
+// unitsRoomModel is a room before its units were moved to its members.
+type unitsRoomModel struct {
+	gorm.Model
+	RoomId      int64
+	MaxUnitSize int
+	State       int
+	Units       []int64 `gorm:"serializer:json"`
+}
+
+func (unitsRoomModel) TableName() string {
+	return "room_models"
+}
+
+func TestAdoptLegacySchema(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() {
+		os.Remove("test.db")
+	}()
+	if err := db.AutoMigrate(&legacyUserModel{}, &unitsRoomModel{}); err != nil {
+		t.Fatalf("failed to migrate legacy tables: %v", err)
+	}
+	if err := db.Create(&unitsRoomModel{RoomId: 1, MaxUnitSize: 3, Units: []int64{7, 8}}).Error; err != nil {
+		t.Fatalf("failed to create legacy room: %v", err)
+	}
+
+	migrator, err := NewSchemaMigrator(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+	if err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("failed to migrate legacy database: %v", err)
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("expected the latest schema, got %v", err)
+	}
+	if db.Migrator().HasColumn(&RoomModel{}, "units") {
+		t.Fatal("expected the units column to be dropped")
+	}
+
+	rooms := NewGormRoomStore(db)
+	room, err := rooms.GetRoom(ctx, 1)
+	if err != nil || room.MaxUnitSize != 3 || !slices.Equal(room.Units, []int64{7, 8}) {
+		t.Fatalf("unexpected migrated room: %+v, %v", room, err)
+	}
+	if err := rooms.UpdateRoom(ctx, room); err != nil {
+		t.Fatalf("failed to update migrated room: %v", err)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for name, files := range map[string]fstest.MapFS{
+		"gap": {
+			"m/0001_a.up.sql": {Data: []byte("CREATE TABLE a (id integer);")}, "m/0001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+			"m/0003_c.up.sql": {Data: []byte("CREATE TABLE c (id integer);")}, "m/0003_c.down.sql": {Data: []byte("DROP TABLE c;")},
+		},
+		"no down": {
+			"m/0001_a.up.sql": {Data: []byte("CREATE TABLE a (id integer);")},
+		},
+		"bad name": {
+			"m/a.sql": {Data: []byte("CREATE TABLE a (id integer);")},
+		},
+	} {
+		if _, err := loadMigrations(files, "m"); err == nil {
+			t.Fatalf("%s: expected invalid migrations", name)
+		}
+	}
+
+	statements := splitStatements("-- a comment\nCREATE TABLE a (\n  id integer\n);\n\nDROP TABLE b;\n")
+	if !slices.Equal(statements, []string{"CREATE TABLE a (\n  id integer\n);", "DROP TABLE b;"}) {
+		t.Fatalf("unexpected statements: %q", statements)
+	}
+}
+
+func TestUniqueNicknamesMigration(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), gormConfig())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer CloseDatabase(db)
+	migrator, err := NewSchemaMigrator(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+	if err := migrator.Up(ctx, 2); err != nil {
+		t.Fatalf("failed to migrate to version 2: %v", err)
+	}
+	// the users before nicknames were unique, 4 is deleted
+	for _, user := range []struct {
+		id       int64
+		nickname string
+		deleted  bool
+	}{{1, "latte", false}, {2, "latte", false}, {3, "mocha", false}, {4, "mocha", true}} {
+		var deletedAt any
+		if user.deleted {
+			deletedAt = time.Now()
+		}
+		if err := db.Exec("INSERT INTO user_models (user_id, nickname, deleted_at) VALUES (?, ?, ?)",
+			user.id, user.nickname, deletedAt).Error; err != nil {
+			t.Fatalf("failed to insert user: %v", err)
+		}
+	}
+	if err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	users := NewGormUserStore(db)
+	for id, nickname := range map[int64]string{1: "latte", 2: "latte#2", 3: "mocha"} {
+		if user, err := users.GetUser(ctx, id); err != nil || user.Nickname != nickname {
+			t.Fatalf("expected user %d to be named %q, got %+v %v", id, nickname, user, err)
+		}
+	}
+	if err := users.StoreUser(ctx, types.User{UserId: 5, Nickname: "mocha"}); !errors.Is(err, store.ErrNicknameTaken) {
+		t.Fatalf("expected the nicknames of the users to be reserved, got %v", err)
+	}
+}
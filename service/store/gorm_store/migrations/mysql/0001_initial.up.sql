@@ -0,0 +1,60 @@
+-- the schema created by AutoMigrate before it was versioned, databases created then are
+-- adopted at this version
+CREATE TABLE `user_models` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `user_id` bigint,
+  `nickname` longtext,
+  `sex` bigint,
+  `age` bigint,
+  `birthday` bigint,
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_models_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE `room_models` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  `room_id` bigint,
+  `max_unit_size` bigint,
+  `state` bigint,
+  `version` bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  INDEX `idx_room_models_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE `room_member_models` (
+  `room_id` bigint,
+  `user_id` bigint,
+  `role` bigint,
+  `joined_at` datetime(3) NULL,
+  PRIMARY KEY (`room_id`,`user_id`),
+  INDEX `idx_room_member_models_user_id` (`user_id`)
+);
+
+CREATE TABLE `audit_models` (
+  `id` bigint AUTO_INCREMENT,
+  `actor` varchar(128),
+  `action` varchar(64),
+  `target` varchar(128),
+  `request_id` varchar(64),
+  `before` text,
+  `after` text,
+  `error` text,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_audit_models_actor` (`actor`),
+  INDEX `idx_audit_models_action` (`action`),
+  INDEX `idx_audit_models_target` (`target`),
+  INDEX `idx_audit_models_created_at` (`created_at`)
+);
+
+CREATE TABLE `id_sequence_models` (
+  `name` varchar(64),
+  `next_id` bigint,
+  PRIMARY KEY (`name`)
+);
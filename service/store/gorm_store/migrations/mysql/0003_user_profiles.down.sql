@@ -0,0 +1,4 @@
+-- the nicknames renamed by the up migration are kept
+DROP TABLE `avatar_models`;
+DROP TABLE `user_nickname_models`;
+ALTER TABLE `user_models` DROP COLUMN `status`, DROP COLUMN `bio`, DROP COLUMN `avatar_url`;
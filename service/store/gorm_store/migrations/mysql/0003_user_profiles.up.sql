@@ -0,0 +1,28 @@
+-- the profile of a user besides its nickname
+ALTER TABLE `user_models`
+  ADD `avatar_url` varchar(512) NOT NULL DEFAULT '',
+  ADD `bio` varchar(256) NOT NULL DEFAULT '',
+  ADD `status` varchar(64) NOT NULL DEFAULT '';
+
+-- nicknames are unique among the users which are not deleted, users sharing a nickname
+-- keep it for the first of them and their ids are appended to it for the others. They
+-- are compared like sqlite compares them, byte by byte.
+UPDATE `user_models` SET `nickname` = CONCAT(`nickname`, '#', `user_id`)
+  WHERE `deleted_at` IS NULL
+  AND `id` NOT IN (SELECT `id` FROM (SELECT MIN(`id`) AS `id` FROM `user_models` WHERE `deleted_at` IS NULL GROUP BY BINARY `nickname`) AS `firsts`);
+CREATE TABLE `user_nickname_models` (
+  `nickname` varchar(255) COLLATE utf8mb4_bin,
+  `user_id` bigint NOT NULL,
+  PRIMARY KEY (`nickname`),
+  UNIQUE INDEX `idx_user_nickname_models_user_id` (`user_id`)
+);
+INSERT INTO `user_nickname_models` (`nickname`, `user_id`)
+  SELECT `nickname`, `user_id` FROM `user_models` WHERE `deleted_at` IS NULL;
+
+CREATE TABLE `avatar_models` (
+  `user_id` bigint,
+  `content_type` varchar(64),
+  `data` mediumblob,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`user_id`)
+);
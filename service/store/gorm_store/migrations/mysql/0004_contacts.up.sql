@@ -0,0 +1,9 @@
+-- the relations of the users to their contacts, requests to a user are listed by contact
+CREATE TABLE `contact_models` (
+  `user_id` bigint,
+  `contact_id` bigint,
+  `state` bigint NOT NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`user_id`,`contact_id`),
+  INDEX `idx_contact_models_contact_id_state` (`contact_id`,`state`)
+);
@@ -0,0 +1,5 @@
+DROP TABLE `id_sequence_models`;
+DROP TABLE `audit_models`;
+DROP TABLE `room_member_models`;
+DROP TABLE `room_models`;
+DROP TABLE `user_models`;
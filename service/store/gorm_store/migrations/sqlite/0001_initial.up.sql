@@ -0,0 +1,18 @@
+-- the schema created by AutoMigrate before it was versioned, databases created then are
+-- adopted at this version
+CREATE TABLE `user_models` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`user_id` integer,`nickname` text,`sex` integer,`age` integer,`birthday` integer);
+CREATE INDEX `idx_user_models_deleted_at` ON `user_models`(`deleted_at`);
+
+CREATE TABLE `room_models` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`room_id` integer,`max_unit_size` integer,`state` integer,`version` integer NOT NULL DEFAULT 0);
+CREATE INDEX `idx_room_models_deleted_at` ON `room_models`(`deleted_at`);
+
+CREATE TABLE `room_member_models` (`room_id` integer,`user_id` integer,`role` integer,`joined_at` datetime,PRIMARY KEY (`room_id`,`user_id`));
+CREATE INDEX `idx_room_member_models_user_id` ON `room_member_models`(`user_id`);
+
+CREATE TABLE `audit_models` (`id` integer PRIMARY KEY AUTOINCREMENT,`actor` text,`action` text,`target` text,`request_id` text,`before` text,`after` text,`error` text,`created_at` datetime);
+CREATE INDEX `idx_audit_models_actor` ON `audit_models`(`actor`);
+CREATE INDEX `idx_audit_models_action` ON `audit_models`(`action`);
+CREATE INDEX `idx_audit_models_target` ON `audit_models`(`target`);
+CREATE INDEX `idx_audit_models_created_at` ON `audit_models`(`created_at`);
+
+CREATE TABLE `id_sequence_models` (`name` text,`next_id` integer,PRIMARY KEY (`name`));
@@ -0,0 +1,2 @@
+DROP INDEX `idx_room_models_room_id`;
+DROP INDEX `idx_user_models_user_id`;
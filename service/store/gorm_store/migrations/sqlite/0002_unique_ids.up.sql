@@ -0,0 +1,3 @@
+-- users and rooms are looked up and paged by their ids
+CREATE UNIQUE INDEX `idx_user_models_user_id` ON `user_models`(`user_id`);
+CREATE UNIQUE INDEX `idx_room_models_room_id` ON `room_models`(`room_id`);
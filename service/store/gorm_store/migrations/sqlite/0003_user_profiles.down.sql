@@ -0,0 +1,6 @@
+-- the nicknames renamed by the up migration are kept
+DROP TABLE `avatar_models`;
+DROP TABLE `user_nickname_models`;
+ALTER TABLE `user_models` DROP COLUMN `status`;
+ALTER TABLE `user_models` DROP COLUMN `bio`;
+ALTER TABLE `user_models` DROP COLUMN `avatar_url`;
@@ -0,0 +1,16 @@
+-- the profile of a user besides its nickname
+ALTER TABLE `user_models` ADD `avatar_url` varchar(512) NOT NULL DEFAULT '';
+ALTER TABLE `user_models` ADD `bio` varchar(256) NOT NULL DEFAULT '';
+ALTER TABLE `user_models` ADD `status` varchar(64) NOT NULL DEFAULT '';
+
+-- nicknames are unique among the users which are not deleted, users sharing a nickname
+-- keep it for the first of them and their ids are appended to it for the others
+UPDATE `user_models` SET `nickname` = `nickname` || '#' || `user_id`
+  WHERE `deleted_at` IS NULL
+  AND `id` NOT IN (SELECT MIN(`id`) FROM `user_models` WHERE `deleted_at` IS NULL GROUP BY `nickname`);
+CREATE TABLE `user_nickname_models` (`nickname` varchar(255),`user_id` integer NOT NULL,PRIMARY KEY (`nickname`));
+CREATE UNIQUE INDEX `idx_user_nickname_models_user_id` ON `user_nickname_models`(`user_id`);
+INSERT INTO `user_nickname_models` (`nickname`,`user_id`)
+  SELECT `nickname`,`user_id` FROM `user_models` WHERE `deleted_at` IS NULL;
+
+CREATE TABLE `avatar_models` (`user_id` integer,`content_type` varchar(64),`data` blob,`updated_at` datetime,PRIMARY KEY (`user_id`));
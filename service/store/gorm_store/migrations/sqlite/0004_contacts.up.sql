@@ -0,0 +1,3 @@
+-- the relations of the users to their contacts, requests to a user are listed by contact
+CREATE TABLE `contact_models` (`user_id` integer,`contact_id` integer,`state` integer NOT NULL,`updated_at` datetime,PRIMARY KEY (`user_id`,`contact_id`));
+CREATE INDEX `idx_contact_models_contact_id_state` ON `contact_models`(`contact_id`,`state`);
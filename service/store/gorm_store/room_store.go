@@ -2,9 +2,10 @@ package gorm_store
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"time"
 
-	"github.com/TheChosenGay/coffee/service"
+	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/TheChosenGay/coffee/types"
 	"gorm.io/gorm"
 )
@@ -14,69 +15,177 @@ type RoomModel struct {
 	types.Room
 }
 
+// RoomMemberModel is the membership of a user in a room, a room is written with its
+// members in a transaction which increments its version.
+type RoomMemberModel struct {
+	RoomId   int64 `gorm:"primaryKey;autoIncrement:false"`
+	UserId   int64 `gorm:"primaryKey;autoIncrement:false;index"`
+	Role     types.RoleType
+	JoinedAt time.Time
+}
+
 type gormRoomStore struct {
-	db        *gorm.DB
-	idService service.IdService
+	db *gorm.DB
 }
 
 func NewGormRoomStore(db *gorm.DB) *gormRoomStore {
-	// 自动迁移表结构
-	if err := db.AutoMigrate(&RoomModel{}); err != nil {
-		panic(fmt.Sprintf("failed to migrate RoomModel: %v", err))
-	}
 	return &gormRoomStore{db: db}
 }
 
 func (s *gormRoomStore) CreateRoom(ctx context.Context, room types.Room) error {
-
-	roomModel := RoomModel{
-		Room: room,
-	}
-	result := s.db.Create(&roomModel)
-	if result.Error != nil {
-		return result.Error
-	}
-	return nil
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&RoomModel{Room: room}).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return store.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+		for _, userId := range room.Units {
+			member := RoomMemberModel{RoomId: room.RoomId, UserId: userId, Role: types.Member, JoinedAt: time.Now()}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (s *gormRoomStore) GetRoom(ctx context.Context, id int) (types.Room, error) {
+func (s *gormRoomStore) GetRoom(ctx context.Context, id int64) (types.Room, error) {
 	var room RoomModel
-	result := s.db.Where("room_id = ?", id).First(&room)
+	result := s.db.WithContext(ctx).Where("room_id = ?", id).First(&room)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return types.Room{}, store.ErrNotFound
+	}
 	if result.Error != nil {
 		return types.Room{}, result.Error
 	}
+	members, err := s.ListRoomMembers(ctx, id)
+	if err != nil {
+		return types.Room{}, err
+	}
+	room.Units = make([]int64, len(members))
+	for i, member := range members {
+		room.Units[i] = member.UserId
+	}
 	return room.Room, nil
 }
 
-func (s *gormRoomStore) DeleteRoom(ctx context.Context, id int) error {
-	result := s.db.Where("room_id = ?", id).Delete(&RoomModel{})
+func (s *gormRoomStore) DeleteRoom(ctx context.Context, id int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ?", id).Delete(&RoomMemberModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("room_id = ?", id).Delete(&RoomModel{}).Error
+	})
+}
+
+func (s *gormRoomStore) UpdateRoom(ctx context.Context, room types.Room) error {
+	// a map writes the zero values of the fields too, e.g. the normal state
+	result := s.db.WithContext(ctx).Model(&RoomModel{}).
+		Where("room_id = ? AND version = ?", room.RoomId, room.Version).
+		Updates(map[string]any{
+			"max_unit_size": room.MaxUnitSize,
+			"state":         room.State,
+			"version":       gorm.Expr("version + 1"),
+		})
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return s.missingOrConflict(s.db.WithContext(ctx), room.RoomId)
+	}
 	return nil
 }
 
-func (s *gormRoomStore) UpdateRoom(ctx context.Context, room types.Room) error {
-	roomModel := RoomModel{
-		Room: room,
+// missingOrConflict tells why a write conditioned on the version of a room wrote nothing.
+func (s *gormRoomStore) missingOrConflict(db *gorm.DB, roomId int64) error {
+	var count int64
+	if err := db.Model(&RoomModel{}).Where("room_id = ?", roomId).Count(&count).Error; err != nil {
+		return err
 	}
-	result := s.db.Model(&RoomModel{}).Where("room_id = ?", room.RoomId).Updates(roomModel)
-	if result.Error != nil {
-		return result.Error
+	if count == 0 {
+		return store.ErrNotFound
 	}
-	return nil
+	return store.ErrConflict
 }
 
 func (s *gormRoomStore) ListRoom(ctx context.Context) ([]*types.Room, error) {
 	var rooms []RoomModel
-	result := s.db.Find(&rooms)
+	result := s.db.WithContext(ctx).Find(&rooms)
 
 	if result.Error != nil {
 		return []*types.Room{}, result.Error
 	}
+	var members []RoomMemberModel
+	if err := s.db.WithContext(ctx).Order("joined_at, user_id").Find(&members).Error; err != nil {
+		return []*types.Room{}, err
+	}
+	units := map[int64][]int64{}
+	for _, member := range members {
+		units[member.RoomId] = append(units[member.RoomId], member.UserId)
+	}
 	retRooms := make([]*types.Room, len(rooms))
 	for i, room := range rooms {
+		room.Units = units[room.RoomId]
 		retRooms[i] = &room.Room
 	}
 	return retRooms, nil
 }
+
+func (s *gormRoomStore) AddRoomMember(ctx context.Context, room types.Room, member types.RoomMember) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&RoomMemberModel{}).Where("room_id = ? AND user_id = ?", room.RoomId, member.UserId).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+		var members int64
+		if err := tx.Model(&RoomMemberModel{}).Where("room_id = ?", room.RoomId).Count(&members).Error; err != nil {
+			return err
+		}
+		if members >= int64(room.MaxUnitSize) {
+			return store.ErrRoomFull
+		}
+		model := RoomMemberModel{RoomId: room.RoomId, UserId: member.UserId, Role: member.Role, JoinedAt: member.JoinedAt}
+		if err := tx.Create(&model).Error; err != nil {
+			return err
+		}
+		// the members were counted at the version of the room, a concurrent add
+		// increments it and this one is rolled back
+		result := tx.Model(&RoomModel{}).
+			Where("room_id = ? AND version = ?", room.RoomId, room.Version).
+			Update("version", gorm.Expr("version + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return s.missingOrConflict(tx, room.RoomId)
+		}
+		return nil
+	})
+}
+
+func (s *gormRoomStore) RemoveRoomMember(ctx context.Context, roomId int64, userId int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("room_id = ? AND user_id = ?", roomId, userId).Delete(&RoomMemberModel{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&RoomModel{}).Where("room_id = ?", roomId).Update("version", gorm.Expr("version + 1")).Error
+	})
+}
+
+func (s *gormRoomStore) ListRoomMembers(ctx context.Context, roomId int64) ([]types.RoomMember, error) {
+	var models []RoomMemberModel
+	if err := s.db.WithContext(ctx).Where("room_id = ?", roomId).Order("joined_at, user_id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	members := make([]types.RoomMember, len(models))
+	for i, model := range models {
+		members[i] = types.RoomMember(model)
+	}
+	return members, nil
+}
@@ -0,0 +1,84 @@
+package gorm_store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+func TestRoomMembersCapacity(t *testing.T) {
+	db := SetupDatabase(t)
+	defer func() {
+		os.Remove("test.db")
+	}()
+	// as NewSqliteDatabase, concurrent transactions wait for each other
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	rooms := NewGormRoomStore(db)
+	ctx := context.Background()
+	if err := rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 3}); err != nil {
+		t.Fatalf("failed to create room: %v", err)
+	}
+
+	// concurrent joins retry on conflicts like the room service
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	full := 0
+	for userId := range int64(10) {
+		wg.Go(func() {
+			for {
+				room, err := rooms.GetRoom(ctx, 1)
+				if err != nil {
+					t.Errorf("failed to get room: %v", err)
+					return
+				}
+				err = rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: userId, Role: types.Member, JoinedAt: time.Now()})
+				if errors.Is(err, store.ErrConflict) {
+					continue
+				}
+				if errors.Is(err, store.ErrRoomFull) {
+					mx.Lock()
+					full++
+					mx.Unlock()
+				} else if err != nil {
+					t.Errorf("failed to add member %d: %v", userId, err)
+				}
+				return
+			}
+		})
+	}
+	wg.Wait()
+	room, err := rooms.GetRoom(ctx, 1)
+	if err != nil || len(room.Units) != 3 || full != 7 {
+		t.Fatalf("expected 3 members and 7 full rooms, got %+v, %v and %d full", room, err, full)
+	}
+
+	// a member is added once, a stale room is not written
+	if err := rooms.RemoveRoomMember(ctx, 1, room.Units[0]); err != nil {
+		t.Fatalf("failed to remove member: %v", err)
+	}
+	if err := rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: 42}); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected a conflict adding to a stale room, got %v", err)
+	}
+	room.State = types.RoomStateBanned
+	if err := rooms.UpdateRoom(ctx, room); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected a conflict updating a stale room, got %v", err)
+	}
+	room, _ = rooms.GetRoom(ctx, 1)
+	if err := rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: room.Units[0]}); err != nil {
+		t.Fatalf("expected adding a member twice to do nothing, got %v", err)
+	}
+	room.State = types.RoomStateNormal
+	if err := rooms.UpdateRoom(ctx, room); err != nil {
+		t.Fatalf("failed to update room: %v", err)
+	}
+	if err := rooms.UpdateRoom(ctx, types.Room{RoomId: 2}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected a missing room, got %v", err)
+	}
+}
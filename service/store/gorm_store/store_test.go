@@ -13,11 +13,15 @@ import (
 )
 
 func SetupDatabase(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open("test.db"), gormConfig())
 	if err != nil {
 		t.Fatalf("failed to open database: %v", err)
 	}
-	if err := db.AutoMigrate(&UserModel{}); err != nil {
+	migrator, err := NewSchemaMigrator(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := migrator.Up(context.Background(), 0); err != nil {
 		t.Fatalf("failed to migrate database: %v", err)
 	}
 	return db
@@ -47,7 +51,7 @@ func TestGetUser(t *testing.T) {
 	}()
 	store := NewGormUserStore(db)
 	// Create a user
-	userId := 80809090
+	userId := int64(80809090)
 	if err := store.StoreUser(context.Background(), types.User{
 		UserId:   userId,
 		Nickname: "test",
@@ -76,7 +80,7 @@ func TestDeleteUser(t *testing.T) {
 	}()
 	store := NewGormUserStore(db)
 	// Create a user
-	userId := 80809090
+	userId := int64(80809090)
 	if err := store.StoreUser(context.Background(), types.User{
 		UserId:   userId,
 		Nickname: "test",
@@ -0,0 +1,30 @@
+package gorm_store
+
+import (
+	"context"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"gorm.io/gorm"
+)
+
+// gormUnitOfWork runs the units of work in transactions, the transactions of the stores
+// called by a unit become savepoints of its transaction.
+type gormUnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewGormUnitOfWork(db *gorm.DB) *gormUnitOfWork {
+	return &gormUnitOfWork{db: db}
+}
+
+func (u *gormUnitOfWork) Do(ctx context.Context, fn func(stores store.Stores) error) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(store.Stores{
+			Users:    NewGormUserStore(tx),
+			Rooms:    NewGormRoomStore(tx),
+			Audit:    NewGormAuditStore(tx),
+			Avatars:  NewGormAvatarStore(tx),
+			Contacts: NewGormContactStore(tx),
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package gorm_store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+func TestGormUnitOfWork(t *testing.T) {
+	db := openSqlite(t)
+	units := NewGormUnitOfWork(db)
+	users := NewGormUserStore(db)
+	rooms := NewGormRoomStore(db)
+	ctx := context.Background()
+
+	// a failed unit writes nothing
+	failed := errors.New("failed")
+	err := units.Do(ctx, func(stores store.Stores) error {
+		if err := stores.Users.StoreUser(ctx, types.User{UserId: 1, Nickname: "latte"}); err != nil {
+			return err
+		}
+		if err := stores.Rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 2, Units: []int64{1}}); err != nil {
+			return err
+		}
+		// the unit reads its own writes
+		if room, err := stores.Rooms.GetRoom(ctx, 1); err != nil || len(room.Units) != 1 {
+			t.Errorf("expected the room of the unit, got %+v %v", room, err)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the error of the unit, got %v", err)
+	}
+	if _, err := users.GetUser(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected the user to be rolled back, got %v", err)
+	}
+	if members, _ := rooms.ListRoomMembers(ctx, 1); len(members) != 0 {
+		t.Fatalf("expected the members to be rolled back, got %+v", members)
+	}
+
+	// the transactions of the stores are savepoints of the unit
+	if err := rooms.CreateRoom(ctx, types.Room{RoomId: 2, MaxUnitSize: 2}); err != nil {
+		t.Fatalf("failed to create room: %v", err)
+	}
+	err = units.Do(ctx, func(stores store.Stores) error {
+		room, err := stores.Rooms.GetRoom(ctx, 2)
+		if err != nil {
+			return err
+		}
+		if err := stores.Rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 2, UserId: 7, JoinedAt: time.Now()}); err != nil {
+			return err
+		}
+		if err := stores.Rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 2, UserId: 8, JoinedAt: time.Now()}); !errors.Is(err, store.ErrConflict) {
+			t.Errorf("expected a conflict adding to a stale room, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to run unit: %v", err)
+	}
+	room, err := rooms.GetRoom(ctx, 2)
+	if err != nil || len(room.Units) != 1 || room.Units[0] != 7 {
+		t.Fatalf("expected the first member only, got %+v %v", room, err)
+	}
+}
@@ -2,8 +2,9 @@ package gorm_store
 
 import (
 	"context"
-	"fmt"
+	"errors"
 
+	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/TheChosenGay/coffee/types"
 	"gorm.io/gorm"
 )
@@ -13,42 +14,93 @@ type UserModel struct {
 	types.User
 }
 
+// UserNicknameModel reserves the nickname of a user which is not deleted, nicknames are
+// unique by its primary key.
+type UserNicknameModel struct {
+	Nickname string `gorm:"primaryKey;size:255"`
+	UserId   int64  `gorm:"not null;uniqueIndex"`
+}
+
 type gormUserStore struct {
 	db *gorm.DB
 }
 
 func NewGormUserStore(db *gorm.DB) *gormUserStore {
-	if err := db.AutoMigrate(&UserModel{}); err != nil {
-		panic(fmt.Sprintf("failed to migrate UserModel: %v", err))
-	}
 	return &gormUserStore{db: db}
 }
 
 // MARK: User Store
 
 func (s *gormUserStore) StoreUser(ctx context.Context, user types.User) error {
-	userModel := UserModel{
-		User: user,
-	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		userModel := UserModel{
+			User: user,
+		}
+		result := tx.Create(&userModel)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return store.ErrConflict
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		return reserveNickname(tx, user)
+	})
+}
 
-	result := s.db.Create(&userModel)
-	if result.Error != nil {
-		return result.Error
+// reserveNickname reserves the nickname of user, which must not have one reserved.
+func reserveNickname(tx *gorm.DB, user types.User) error {
+	err := tx.Create(&UserNicknameModel{Nickname: user.Nickname, UserId: user.UserId}).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return store.ErrNicknameTaken
 	}
-
-	return nil
+	return err
 }
 
-func (s *gormUserStore) DeleteUser(ctx context.Context, id int) error {
-	result := s.db.Where("user_id = ?", id).Delete(&UserModel{})
-	if result.Error != nil {
-		return result.Error
-	}
-	return nil
+func (s *gormUserStore) UpdateUser(ctx context.Context, user types.User) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored UserModel
+		result := tx.Where("user_id = ?", user.UserId).First(&stored)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return store.ErrNotFound
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if stored.Nickname != user.Nickname {
+			if err := tx.Where("user_id = ?", user.UserId).Delete(&UserNicknameModel{}).Error; err != nil {
+				return err
+			}
+			if err := reserveNickname(tx, user); err != nil {
+				return err
+			}
+		}
+		// a map writes the zero values of the fields too, e.g. an empty bio
+		return tx.Model(&stored).Updates(map[string]any{
+			"nickname":   user.Nickname,
+			"sex":        user.Sex,
+			"age":        user.Age,
+			"birthday":   user.Birthday,
+			"avatar_url": user.AvatarUrl,
+			"bio":        user.Bio,
+			"status":     user.Status,
+		}).Error
+	})
 }
 
-func (s *gormUserStore) GetUser(ctx context.Context, id int) (types.User, error) {
-	result := s.db.Where("user_id = ?", id).First(&UserModel{})
+func (s *gormUserStore) DeleteUser(ctx context.Context, id int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&UserModel{}).Error; err != nil {
+			return err
+		}
+		// the user is kept deleted, its nickname is free again
+		return tx.Where("user_id = ?", id).Delete(&UserNicknameModel{}).Error
+	})
+}
+func (s *gormUserStore) GetUser(ctx context.Context, id int64) (types.User, error) {
+	result := s.db.WithContext(ctx).Where("user_id = ?", id).First(&UserModel{})
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return types.User{UserId: types.InvalidUserId}, store.ErrNotFound
+	}
 	if result.Error != nil {
 		return types.User{UserId: types.InvalidUserId}, result.Error
 	}
@@ -59,7 +111,20 @@ func (s *gormUserStore) GetUser(ctx context.Context, id int) (types.User, error)
 
 func (s *gormUserStore) ListUser(ctx context.Context) ([]types.User, error) {
 	var users []UserModel
-	result := s.db.Find(&users)
+	result := s.db.WithContext(ctx).Order("user_id").Find(&users)
+	if result.Error != nil {
+		return []types.User{}, result.Error
+	}
+	retUsers := make([]types.User, len(users))
+	for i, user := range users {
+		retUsers[i] = user.User
+	}
+	return retUsers, nil
+}
+
+func (s *gormUserStore) ListUserPage(ctx context.Context, afterId int64, limit int) ([]types.User, error) {
+	var users []UserModel
+	result := s.db.WithContext(ctx).Where("user_id > ?", afterId).Order("user_id").Limit(limit).Find(&users)
 	if result.Error != nil {
 		return []types.User{}, result.Error
 	}
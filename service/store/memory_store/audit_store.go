@@ -0,0 +1,70 @@
+package memory_store
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+type memoryAuditStore struct {
+	mx sync.RWMutex
+	// entries in the order they were appended, their ids are their positions from 1
+	entries []types.AuditEntry
+}
+
+func NewMemoryAuditStore() *memoryAuditStore {
+	return &memoryAuditStore{}
+}
+
+func (s *memoryAuditStore) AppendAudit(ctx context.Context, entry types.AuditEntry) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	entry.Id = int64(len(s.entries) + 1)
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	entry.Before = rawJson(entry.Before)
+	entry.After = rawJson(entry.After)
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *memoryAuditStore) ListAudit(ctx context.Context, filter store.AuditFilter) ([]types.AuditEntry, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	entries := []types.AuditEntry{}
+	for _, entry := range slices.Backward(s.entries) {
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+		if matches(filter, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func matches(filter store.AuditFilter, entry types.AuditEntry) bool {
+	switch {
+	case filter.Actor != "" && entry.Actor != filter.Actor,
+		filter.Action != "" && entry.Action != filter.Action,
+		filter.Target != "" && entry.Target != filter.Target,
+		!filter.Since.IsZero() && entry.CreatedAt.Before(filter.Since),
+		!filter.Until.IsZero() && !entry.CreatedAt.Before(filter.Until),
+		filter.BeforeId > 0 && entry.Id >= filter.BeforeId:
+		return false
+	}
+	return true
+}
+
+// rawJson returns a copy of raw, nil when it is empty like the database reads it.
+func rawJson(raw []byte) []byte {
+	if len(raw) == 0 {
+		return nil
+	}
+	return slices.Clone(raw)
+}
@@ -0,0 +1,45 @@
+package memory_store
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+type memoryAvatarStore struct {
+	mx      sync.RWMutex
+	avatars map[int64]types.Avatar
+}
+
+func NewMemoryAvatarStore() *memoryAvatarStore {
+	return &memoryAvatarStore{avatars: map[int64]types.Avatar{}}
+}
+
+func (s *memoryAvatarStore) StoreAvatar(ctx context.Context, avatar types.Avatar) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	avatar.Data = slices.Clone(avatar.Data)
+	s.avatars[avatar.UserId] = avatar
+	return nil
+}
+
+func (s *memoryAvatarStore) GetAvatar(ctx context.Context, userId int64) (types.Avatar, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	avatar, ok := s.avatars[userId]
+	if !ok {
+		return types.Avatar{}, store.ErrNotFound
+	}
+	avatar.Data = slices.Clone(avatar.Data)
+	return avatar, nil
+}
+
+func (s *memoryAvatarStore) DeleteAvatar(ctx context.Context, userId int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.avatars, userId)
+	return nil
+}
@@ -0,0 +1,30 @@
+package memory_store
+
+import (
+	"context"
+	"slices"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// memoryCoffeeStore is a catalog of coffees, it is read only.
+type memoryCoffeeStore struct {
+	coffees []types.Coffee
+}
+
+func NewMemoryCoffeeStore(coffees ...types.Coffee) *memoryCoffeeStore {
+	return &memoryCoffeeStore{coffees: slices.Clone(coffees)}
+}
+
+func (s *memoryCoffeeStore) ListCoffees(ctx context.Context) ([]types.Coffee, error) {
+	return slices.Clone(s.coffees), nil
+}
+
+func (s *memoryCoffeeStore) GetCoffeeById(ctx context.Context, id int64) (types.Coffee, error) {
+	i := slices.IndexFunc(s.coffees, func(coffee types.Coffee) bool { return coffee.Id == id })
+	if i < 0 {
+		return types.Coffee{}, store.ErrNotFound
+	}
+	return s.coffees[i], nil
+}
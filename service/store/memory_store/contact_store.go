@@ -0,0 +1,87 @@
+package memory_store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// contactKey is a user and one of its contacts.
+type contactKey struct {
+	userId    int64
+	contactId int64
+}
+
+type memoryContactStore struct {
+	mx       sync.RWMutex
+	contacts map[contactKey]types.Contact
+}
+
+func NewMemoryContactStore() *memoryContactStore {
+	return &memoryContactStore{contacts: map[contactKey]types.Contact{}}
+}
+
+func (s *memoryContactStore) GetContact(ctx context.Context, userId int64, contactId int64) (types.Contact, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	contact, ok := s.contacts[contactKey{userId, contactId}]
+	if !ok {
+		return types.Contact{}, store.ErrNotFound
+	}
+	return contact, nil
+}
+
+func (s *memoryContactStore) PutContact(ctx context.Context, contact types.Contact) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.contacts[contactKey{contact.UserId, contact.ContactId}] = contact
+	return nil
+}
+
+func (s *memoryContactStore) DeleteContact(ctx context.Context, userId int64, contactId int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.contacts, contactKey{userId, contactId})
+	return nil
+}
+
+func (s *memoryContactStore) DeleteUserContacts(ctx context.Context, userId int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for key := range s.contacts {
+		if key.userId == userId || key.contactId == userId {
+			delete(s.contacts, key)
+		}
+	}
+	return nil
+}
+
+func (s *memoryContactStore) ListContacts(ctx context.Context, userId int64, state types.ContactState) ([]types.Contact, error) {
+	contacts := s.list(func(contact types.Contact) bool { return contact.UserId == userId && contact.State == state })
+	slices.SortFunc(contacts, func(a, b types.Contact) int { return cmp.Compare(a.ContactId, b.ContactId) })
+	return contacts, nil
+}
+
+func (s *memoryContactStore) ListContactRequests(ctx context.Context, userId int64) ([]types.Contact, error) {
+	contacts := s.list(func(contact types.Contact) bool {
+		return contact.ContactId == userId && contact.State == types.ContactRequested
+	})
+	slices.SortFunc(contacts, func(a, b types.Contact) int { return cmp.Compare(a.UserId, b.UserId) })
+	return contacts, nil
+}
+
+func (s *memoryContactStore) list(match func(contact types.Contact) bool) []types.Contact {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	contacts := []types.Contact{}
+	for _, contact := range s.contacts {
+		if match(contact) {
+			contacts = append(contacts, contact)
+		}
+	}
+	return contacts
+}
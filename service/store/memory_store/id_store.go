@@ -0,0 +1,21 @@
+package memory_store
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/TheChosenGay/coffee/service"
+)
+
+// memoryIdService hands out ids from 1, they start over when the process restarts.
+type memoryIdService struct {
+	last atomic.Int64
+}
+
+func NewMemoryIdService() service.IdService {
+	return &memoryIdService{}
+}
+
+func (s *memoryIdService) GenerateId(ctx context.Context) (int64, error) {
+	return s.last.Add(1), nil
+}
@@ -0,0 +1,31 @@
+package memory_store
+
+import (
+	"testing"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/storetest"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+func TestMemoryStores(t *testing.T) {
+	t.Run("users", func(t *testing.T) {
+		storetest.TestUserStore(t, func(t *testing.T) store.UserStore { return NewMemoryUserStore() })
+	})
+	t.Run("rooms", func(t *testing.T) {
+		storetest.TestRoomStore(t, func(t *testing.T) store.RoomStore { return NewMemoryRoomStore() })
+	})
+	t.Run("audit", func(t *testing.T) {
+		storetest.TestAuditStore(t, func(t *testing.T) store.AuditStore { return NewMemoryAuditStore() })
+	})
+	t.Run("avatars", func(t *testing.T) {
+		storetest.TestAvatarStore(t, func(t *testing.T) store.AvatarStore { return NewMemoryAvatarStore() })
+	})
+	t.Run("contacts", func(t *testing.T) {
+		storetest.TestContactStore(t, func(t *testing.T) store.ContactStore { return NewMemoryContactStore() })
+	})
+	t.Run("coffees", func(t *testing.T) {
+		coffees := []types.Coffee{{Id: 2, Name: "latte"}, {Id: 1, Name: "mocha"}}
+		storetest.TestCoffeeStore(t, NewMemoryCoffeeStore(coffees...), coffees)
+	})
+}
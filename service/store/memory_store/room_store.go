@@ -0,0 +1,159 @@
+package memory_store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+type memoryRoomStore struct {
+	mx    sync.RWMutex
+	rooms map[int64]types.Room
+	// members of every room by the time they joined
+	members map[int64][]types.RoomMember
+}
+
+func NewMemoryRoomStore() *memoryRoomStore {
+	return &memoryRoomStore{rooms: map[int64]types.Room{}, members: map[int64][]types.RoomMember{}}
+}
+
+func (s *memoryRoomStore) CreateRoom(ctx context.Context, room types.Room) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.rooms[room.RoomId]; ok {
+		return store.ErrConflict
+	}
+	members := make([]types.RoomMember, 0, len(room.Units))
+	for _, userId := range room.Units {
+		members = append(members, types.RoomMember{RoomId: room.RoomId, UserId: userId, Role: types.Member, JoinedAt: time.Now()})
+	}
+	room.Units = nil
+	s.rooms[room.RoomId] = room
+	s.members[room.RoomId] = sortMembers(members)
+	return nil
+}
+
+func (s *memoryRoomStore) GetRoom(ctx context.Context, id int64) (types.Room, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	room, ok := s.rooms[id]
+	if !ok {
+		return types.Room{}, store.ErrNotFound
+	}
+	return s.withUnits(room), nil
+}
+
+func (s *memoryRoomStore) DeleteRoom(ctx context.Context, id int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.rooms, id)
+	delete(s.members, id)
+	return nil
+}
+
+func (s *memoryRoomStore) UpdateRoom(ctx context.Context, room types.Room) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	stored, err := s.atVersion(room)
+	if err != nil {
+		return err
+	}
+	stored.MaxUnitSize = room.MaxUnitSize
+	stored.State = room.State
+	stored.Version++
+	s.rooms[room.RoomId] = stored
+	return nil
+}
+
+// atVersion returns the stored room, it fails unless the room is still at the version
+// it was read at.
+func (s *memoryRoomStore) atVersion(room types.Room) (types.Room, error) {
+	stored, ok := s.rooms[room.RoomId]
+	if !ok {
+		return types.Room{}, store.ErrNotFound
+	}
+	if stored.Version != room.Version {
+		return types.Room{}, store.ErrConflict
+	}
+	return stored, nil
+}
+
+func (s *memoryRoomStore) ListRoom(ctx context.Context) ([]*types.Room, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	rooms := make([]*types.Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		room = s.withUnits(room)
+		rooms = append(rooms, &room)
+	}
+	return rooms, nil
+}
+
+// withUnits returns room with the ids of its members.
+func (s *memoryRoomStore) withUnits(room types.Room) types.Room {
+	members := s.members[room.RoomId]
+	room.Units = make([]int64, len(members))
+	for i, member := range members {
+		room.Units[i] = member.UserId
+	}
+	return room
+}
+
+func (s *memoryRoomStore) AddRoomMember(ctx context.Context, room types.Room, member types.RoomMember) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	members := s.members[room.RoomId]
+	if slices.ContainsFunc(members, func(m types.RoomMember) bool { return m.UserId == member.UserId }) {
+		return nil
+	}
+	if len(members) >= room.MaxUnitSize {
+		return store.ErrRoomFull
+	}
+	stored, err := s.atVersion(room)
+	if err != nil {
+		return err
+	}
+	member.RoomId = room.RoomId
+	s.members[room.RoomId] = sortMembers(append(slices.Clone(members), member))
+	stored.Version++
+	s.rooms[room.RoomId] = stored
+	return nil
+}
+
+func (s *memoryRoomStore) RemoveRoomMember(ctx context.Context, roomId int64, userId int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	members := s.members[roomId]
+	i := slices.IndexFunc(members, func(m types.RoomMember) bool { return m.UserId == userId })
+	if i < 0 {
+		return nil
+	}
+	s.members[roomId] = slices.Delete(slices.Clone(members), i, i+1)
+	if room, ok := s.rooms[roomId]; ok {
+		room.Version++
+		s.rooms[roomId] = room
+	}
+	return nil
+}
+
+func (s *memoryRoomStore) ListRoomMembers(ctx context.Context, roomId int64) ([]types.RoomMember, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return slices.Clone(s.members[roomId]), nil
+}
+
+// sortMembers orders members like the database, by the time they joined then by id.
+func sortMembers(members []types.RoomMember) []types.RoomMember {
+	slices.SortFunc(members, func(a, b types.RoomMember) int {
+		if c := a.JoinedAt.Compare(b.JoinedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.UserId, b.UserId)
+	})
+	return members
+}
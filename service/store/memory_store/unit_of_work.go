@@ -0,0 +1,22 @@
+package memory_store
+
+import (
+	"context"
+
+	"github.com/TheChosenGay/coffee/service/store"
+)
+
+// memoryUnitOfWork runs the units of work with the stores directly, it is compatible
+// with the interface but not atomic: the writes of a failed unit are not rolled back and
+// concurrent units see each other's writes.
+type memoryUnitOfWork struct {
+	stores store.Stores
+}
+
+func NewMemoryUnitOfWork(stores store.Stores) *memoryUnitOfWork {
+	return &memoryUnitOfWork{stores: stores}
+}
+
+func (u *memoryUnitOfWork) Do(ctx context.Context, fn func(stores store.Stores) error) error {
+	return fn(u.stores)
+}
@@ -0,0 +1,101 @@
+// Package memory_store keeps the records of the stores in memory, they are lost when
+// the process exits. It serves tests and local development without a database.
+package memory_store
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+type memoryUserStore struct {
+	mx    sync.RWMutex
+	users map[int64]types.User
+	// the ids of the users by their nicknames
+	nicknames map[string]int64
+}
+
+func NewMemoryUserStore() *memoryUserStore {
+	return &memoryUserStore{users: map[int64]types.User{}, nicknames: map[string]int64{}}
+}
+
+func (s *memoryUserStore) StoreUser(ctx context.Context, user types.User) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if _, ok := s.users[user.UserId]; ok {
+		return store.ErrConflict
+	}
+	if _, ok := s.nicknames[user.Nickname]; ok {
+		return store.ErrNicknameTaken
+	}
+	s.users[user.UserId] = user
+	s.nicknames[user.Nickname] = user.UserId
+	return nil
+}
+
+func (s *memoryUserStore) UpdateUser(ctx context.Context, user types.User) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	stored, ok := s.users[user.UserId]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if stored.Nickname != user.Nickname {
+		if _, ok := s.nicknames[user.Nickname]; ok {
+			return store.ErrNicknameTaken
+		}
+		delete(s.nicknames, stored.Nickname)
+		s.nicknames[user.Nickname] = user.UserId
+	}
+	s.users[user.UserId] = user
+	return nil
+}
+
+func (s *memoryUserStore) DeleteUser(ctx context.Context, id int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if user, ok := s.users[id]; ok {
+		delete(s.nicknames, user.Nickname)
+	}
+	delete(s.users, id)
+	return nil
+}
+
+func (s *memoryUserStore) GetUser(ctx context.Context, id int64) (types.User, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	user, ok := s.users[id]
+	if !ok {
+		return types.User{UserId: types.InvalidUserId}, store.ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *memoryUserStore) ListUser(ctx context.Context) ([]types.User, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	users := make([]types.User, 0, len(s.users))
+	for _, id := range slices.Sorted(maps.Keys(s.users)) {
+		users = append(users, s.users[id])
+	}
+	return users, nil
+}
+
+func (s *memoryUserStore) ListUserPage(ctx context.Context, afterId int64, limit int) ([]types.User, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	users := []types.User{}
+	for _, id := range slices.Sorted(maps.Keys(s.users)) {
+		if len(users) == limit {
+			break
+		}
+		if id > afterId {
+			users = append(users, s.users[id])
+		}
+	}
+	return users, nil
+}
@@ -0,0 +1,72 @@
+package redis_store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/cache_store"
+	"github.com/TheChosenGay/coffee/service/store/storetest"
+	"github.com/TheChosenGay/coffee/types"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// The redis stores are the caches of the cache stores, whose conformance tests run the
+// store suite over them. They run the cache suite on their own.
+func TestConformance(t *testing.T) {
+	t.Run("users", func(t *testing.T) {
+		storetest.TestUserCache(t, func(t *testing.T) cache_store.UserCache {
+			users := NewRedisUserStore(RedisStoreOpts{Addr: miniredis.RunT(t).Addr()}, time.Minute, time.Second)
+			t.Cleanup(func() { users.Close() })
+			return users
+		})
+	})
+	t.Run("rooms", func(t *testing.T) {
+		storetest.TestRoomCache(t, func(t *testing.T) cache_store.RoomCache {
+			rooms := NewRedisRoomStore(RedisStoreOpts{Addr: miniredis.RunT(t).Addr()}, time.Minute)
+			t.Cleanup(func() { rooms.Close() })
+			return rooms
+		})
+	})
+}
+
+func TestExpiry(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	users := NewRedisUserStore(RedisStoreOpts{Addr: server.Addr()}, time.Minute, time.Second)
+	defer users.Close()
+	rooms := NewRedisRoomStore(RedisStoreOpts{Addr: server.Addr()}, time.Minute)
+	defer rooms.Close()
+
+	if err := users.RebuildUserIndex(ctx, 0, []types.User{{UserId: 1, Nickname: "latte"}}); err != nil {
+		t.Fatalf("failed to rebuild index: %v", err)
+	}
+	if err := users.StoreMissingUser(ctx, 2); err != nil {
+		t.Fatalf("failed to cache missing user: %v", err)
+	}
+	if err := rooms.UpdateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 3}); err != nil {
+		t.Fatalf("failed to cache room: %v", err)
+	}
+
+	// users found missing expire first
+	server.FastForward(2 * time.Second)
+	if _, err := users.GetUser(ctx, 2); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected the missing user to expire, got %v", err)
+	}
+	if _, err := users.ListUser(ctx); err != nil {
+		t.Fatalf("expected the index complete before its ttl, got %v", err)
+	}
+
+	server.FastForward(time.Minute)
+	if _, err := users.GetUser(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected the user to expire, got %v", err)
+	}
+	if _, err := users.ListUser(ctx); !errors.Is(err, store.ErrIncomplete) {
+		t.Fatalf("expected the index incomplete once its users expired, got %v", err)
+	}
+	if _, err := rooms.GetRoom(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected the room to expire, got %v", err)
+	}
+}
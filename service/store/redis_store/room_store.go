@@ -0,0 +1,90 @@
+package redis_store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const RoomRedisKeyPrefix string = "redis_room:"
+
+// errRoomListUnsupported is returned by ListRoom, redis only holds the rooms read recently,
+// they are not the list of all rooms.
+var errRoomListUnsupported = errors.New("redis room store does not list rooms")
+
+// redisRoom is the json of a room in redis, the units and the version are not part of
+// the json of types.Room.
+type redisRoom struct {
+	types.Room
+	Version int64   `json:"version"`
+	Units   []int64 `json:"units"`
+}
+
+// RedisRoomStore caches single rooms in redis, it is meant to be used by a
+// cache_store.CacheRoomStore in front of the database.
+type RedisRoomStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisRoomStore returns a store keeping rooms for ttl, so that a missed invalidation
+// does not serve a stale room forever.
+func NewRedisRoomStore(opts RedisStoreOpts, ttl time.Duration) *RedisRoomStore {
+	return &RedisRoomStore{client: NewRedisClient(opts), ttl: ttl}
+}
+
+func (s *RedisRoomStore) CreateRoom(ctx context.Context, room types.Room) error {
+	return s.UpdateRoom(ctx, room)
+}
+
+func (s *RedisRoomStore) UpdateRoom(ctx context.Context, room types.Room) error {
+	json, err := json.Marshal(redisRoom{Room: room, Version: room.Version, Units: room.Units})
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.getKey(room.RoomId), json, s.ttl).Err()
+}
+
+func (s *RedisRoomStore) DeleteRoom(ctx context.Context, roomId int64) error {
+	return s.client.Del(ctx, s.getKey(roomId)).Err()
+}
+
+func (s *RedisRoomStore) GetRoom(ctx context.Context, roomId int64) (types.Room, error) {
+	jsonStr, err := s.client.Get(ctx, s.getKey(roomId)).Result()
+	if errors.Is(err, redis.Nil) {
+		return types.Room{RoomId: types.InvalidRoomId}, store.ErrNotFound
+	}
+	if err != nil {
+		return types.Room{RoomId: types.InvalidRoomId}, err
+	}
+	var room redisRoom
+	if err := json.Unmarshal([]byte(jsonStr), &room); err != nil {
+		return types.Room{RoomId: types.InvalidRoomId}, err
+	}
+	room.Room.Version = room.Version
+	room.Room.Units = room.Units
+	return room.Room, nil
+}
+
+func (s *RedisRoomStore) ListRoom(ctx context.Context) ([]*types.Room, error) {
+	return nil, errRoomListUnsupported
+}
+
+// Ping checks that redis is reachable, it is used as a health check.
+func (s *RedisRoomStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisRoomStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisRoomStore) getKey(roomId int64) string {
+	return fmt.Sprintf("%s%d", RoomRedisKeyPrefix, roomId)
+}
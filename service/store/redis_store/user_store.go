@@ -3,14 +3,31 @@ package redis_store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/TheChosenGay/coffee/types"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	redis "github.com/redis/go-redis/v9"
 )
 
 const UserRedisKeyPrefix string = "redis_user:"
 
+const (
+	// userIndexKey is a sorted set of the ids of the cached users. The ids are zero padded
+	// members of score 0 listed by their lexical order, which is their numeric order,
+	// because scores are doubles which do not hold 64 bit ids exactly.
+	userIndexKey = "redis_user_index"
+	// userIndexCompleteKey is set while the index holds every user of the database.
+	userIndexCompleteKey = "redis_user_index:complete"
+	// userIndexVersionKey is incremented by every write of a user, a rebuild of the index
+	// from users read before a write is dropped.
+	userIndexVersionKey = "redis_user_index:version"
+)
+
 type RedisStoreOpts struct {
 	Addr     string
 	Password string
@@ -18,16 +35,29 @@ type RedisStoreOpts struct {
 }
 
 type RedisUserStore struct {
-	client *redis.Client
+	client  *redis.Client
+	ttl     time.Duration
+	missTTL time.Duration
+}
+
+// NewRedisUserStore returns a store keeping users for ttl and users cached as missing
+// for missTTL. The index is trusted to be complete for ttl after a rebuild, as long as
+// the users written by the rebuild.
+func NewRedisUserStore(opts RedisStoreOpts, ttl, missTTL time.Duration) *RedisUserStore {
+	return &RedisUserStore{client: NewRedisClient(opts), ttl: ttl, missTTL: missTTL}
 }
 
-func NewRedisUserStore(opts RedisStoreOpts) *RedisUserStore {
+// NewRedisClient returns a client of the redis of opts whose commands are traced.
+func NewRedisClient(opts RedisStoreOpts) *redis.Client {
 	client := redis.NewClient(&redis.Options{
 		Addr:     opts.Addr,
 		Password: opts.Password,
 		DB:       opts.DB,
 	})
-	return &RedisUserStore{client: client}
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		panic(fmt.Sprintf("failed to instrument redis tracing: %v", err))
+	}
+	return client
 }
 
 func (s *RedisUserStore) StoreUser(ctx context.Context, user types.User) error {
@@ -35,18 +65,52 @@ func (s *RedisUserStore) StoreUser(ctx context.Context, user types.User) error {
 	if err != nil {
 		return err
 	}
-	return s.client.Set(ctx, s.getKey(user.UserId), json, 0).Err()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.getKey(user.UserId), json, s.ttl)
+		pipe.ZAdd(ctx, userIndexKey, redis.Z{Member: indexMember(user.UserId)})
+		pipe.Incr(ctx, userIndexVersionKey)
+		return nil
+	})
+	return err
 }
 
-func (s *RedisUserStore) DeleteUser(ctx context.Context, userId int) error {
-	return s.client.Del(ctx, s.getKey(userId)).Err()
+// UpdateUser replaces the cached user, the index holds it already.
+func (s *RedisUserStore) UpdateUser(ctx context.Context, user types.User) error {
+	return s.StoreUser(ctx, user)
 }
 
-func (s *RedisUserStore) GetUser(ctx context.Context, userId int) (types.User, error) {
+func (s *RedisUserStore) DeleteUser(ctx context.Context, userId int64) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.getKey(userId))
+		pipe.ZRem(ctx, userIndexKey, indexMember(userId))
+		pipe.Incr(ctx, userIndexVersionKey)
+		return nil
+	})
+	return err
+}
+
+// missingUser is the value of a user cached as missing.
+const missingUser = "missing"
+
+// StoreMissingUser caches that the user does not exist, unless the user is cached
+// already: a user registered since it was found missing is kept.
+func (s *RedisUserStore) StoreMissingUser(ctx context.Context, userId int64) error {
+	return s.client.SetNX(ctx, s.getKey(userId), missingUser, s.missTTL).Err()
+}
+
+// GetUser returns store.ErrNotFound when the user is not cached, and an invalid user
+// when it is cached as missing.
+func (s *RedisUserStore) GetUser(ctx context.Context, userId int64) (types.User, error) {
 	jsonStr, err := s.client.Get(ctx, s.getKey(userId)).Result()
+	if errors.Is(err, redis.Nil) {
+		return types.User{UserId: types.InvalidUserId}, store.ErrNotFound
+	}
 	if err != nil {
 		return types.User{UserId: types.InvalidUserId}, err
 	}
+	if jsonStr == missingUser {
+		return types.User{UserId: types.InvalidUserId}, nil
+	}
 	var user types.User
 	if err := json.Unmarshal([]byte(jsonStr), &user); err != nil {
 		return types.User{UserId: types.InvalidUserId}, err
@@ -54,10 +118,120 @@ func (s *RedisUserStore) GetUser(ctx context.Context, userId int) (types.User, e
 	return user, nil
 }
 
+// ListUser lists the users of the index, it returns store.ErrIncomplete unless the index
+// was rebuilt from the database by RebuildUserIndex and kept complete since.
 func (s *RedisUserStore) ListUser(ctx context.Context) ([]types.User, error) {
-	return nil, nil
+	return s.listIndex(ctx, "-", 0)
+}
+
+func (s *RedisUserStore) ListUserPage(ctx context.Context, afterId int64, limit int) ([]types.User, error) {
+	return s.listIndex(ctx, "("+indexMember(afterId), limit)
+}
+
+func (s *RedisUserStore) listIndex(ctx context.Context, min string, limit int) ([]types.User, error) {
+	complete, err := s.client.Exists(ctx, userIndexCompleteKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if complete == 0 {
+		return nil, store.ErrIncomplete
+	}
+	members, err := s.client.ZRangeByLex(ctx, userIndexKey, &redis.ZRangeBy{Min: min, Max: "+", Count: int64(limit)}).Result()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]types.User, 0, len(members))
+	if len(members) == 0 {
+		return users, nil
+	}
+	keys := make([]string, len(members))
+	for i, member := range members {
+		userId, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member %q of the user index: %w", member, err)
+		}
+		keys[i] = s.getKey(userId)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		jsonStr, ok := value.(string)
+		if !ok || jsonStr == missingUser {
+			// the user expired or was evicted, the index cannot be listed until it is rebuilt
+			if err := s.InvalidateUserIndex(ctx); err != nil {
+				return nil, err
+			}
+			return nil, store.ErrIncomplete
+		}
+		var user types.User
+		if err := json.Unmarshal([]byte(jsonStr), &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
 }
 
-func (s *RedisUserStore) getKey(userId int) string {
+// UserIndexVersion returns the version of the index, it must be read before the users
+// passed to RebuildUserIndex are read from the database.
+func (s *RedisUserStore) UserIndexVersion(ctx context.Context) (int64, error) {
+	version, err := s.client.Get(ctx, userIndexVersionKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// RebuildUserIndex replaces the index by users, all the users of the database, and marks
+// it complete. It fails with redis.TxFailedErr when a user was written since version.
+func (s *RedisUserStore) RebuildUserIndex(ctx context.Context, version int64, users []types.User) error {
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, userIndexVersionKey).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if current != version {
+			return redis.TxFailedErr
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, userIndexKey)
+			for _, user := range users {
+				json, err := json.Marshal(user)
+				if err != nil {
+					return err
+				}
+				pipe.Set(ctx, s.getKey(user.UserId), json, s.ttl)
+				pipe.ZAdd(ctx, userIndexKey, redis.Z{Member: indexMember(user.UserId)})
+			}
+			// the index is incomplete once the users above expire
+			pipe.Set(ctx, userIndexCompleteKey, 1, s.ttl)
+			return nil
+		})
+		return err
+	}, userIndexVersionKey)
+}
+
+// InvalidateUserIndex marks the index incomplete, e.g. when a write to it failed.
+func (s *RedisUserStore) InvalidateUserIndex(ctx context.Context) error {
+	return s.client.Del(ctx, userIndexCompleteKey).Err()
+}
+
+// Ping checks that redis is reachable, it is used as a health check.
+func (s *RedisUserStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisUserStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisUserStore) getKey(userId int64) string {
 	return fmt.Sprintf("%s%d", UserRedisKeyPrefix, userId)
 }
+
+// indexMember returns the member of userId in the index, ids are positive.
+func indexMember(userId int64) string {
+	return fmt.Sprintf("%019d", userId)
+}
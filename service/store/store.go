@@ -2,28 +2,128 @@ package store
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/TheChosenGay/coffee/types"
 )
 
+// ErrNotFound is returned, possibly wrapped, by stores when the requested record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned by stores when a record was written since it was read, or when
+// a record is created with the id of an existing one.
+var ErrConflict = errors.New("record was written concurrently")
+
+// ErrRoomFull is returned when a member is added to a room at its capacity.
+var ErrRoomFull = errors.New("room is full")
+
+// ErrNicknameTaken is returned when a user is stored with the nickname of another user.
+var ErrNicknameTaken = errors.New("nickname is taken")
+
+// ErrIncomplete is returned by caches asked to list records when they may not hold all of them.
+var ErrIncomplete = errors.New("cache is incomplete")
+
+// Stores are the stores a unit of work runs with.
+type Stores struct {
+	Users    UserStore
+	Rooms    RoomStore
+	Audit    AuditStore
+	Avatars  AvatarStore
+	Contacts ContactStore
+}
+
+// UnitOfWork runs several store calls atomically: the writes of fn through stores are
+// committed when it returns nil and rolled back otherwise, and its reads do not see the
+// writes of concurrent units. fn must not call stores other than the ones it is given,
+// the database may be waiting for its transaction to end.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(stores Stores) error) error
+}
+
 type CoffeeStore interface {
 	ListCoffees(ctx context.Context) ([]types.Coffee, error)
-	GetCoffeeById(ctx context.Context, id int) (types.Coffee, error)
+	GetCoffeeById(ctx context.Context, id int64) (types.Coffee, error)
 }
 
 type RoomStore interface {
 	// room
+	// CreateRoom creates the room with its units as members, it fails with ErrConflict
+	// when its id is taken.
 	CreateRoom(ctx context.Context, room types.Room) error
-	GetRoom(ctx context.Context, id int) (types.Room, error)
-	DeleteRoom(ctx context.Context, id int) error
+	GetRoom(ctx context.Context, id int64) (types.Room, error)
+	DeleteRoom(ctx context.Context, id int64) error
+	// UpdateRoom fails with ErrConflict when the room was written since it was read.
 	UpdateRoom(ctx context.Context, room types.Room) error
 	ListRoom(ctx context.Context) ([]*types.Room, error)
+
+	// members
+	// AddRoomMember adds member to room, the room as read by the caller. Adding a member
+	// twice does nothing. It fails with ErrRoomFull when the room is at its capacity and
+	// with ErrConflict when the room was written since it was read.
+	AddRoomMember(ctx context.Context, room types.Room, member types.RoomMember) error
+	RemoveRoomMember(ctx context.Context, roomId int64, userId int64) error
+	// ListRoomMembers lists the members of the room by the time they joined.
+	ListRoomMembers(ctx context.Context, roomId int64) ([]types.RoomMember, error)
 }
 
 type UserStore interface {
 	// user
+	// StoreUser creates the user, it fails with ErrConflict when its id is taken and with
+	// ErrNicknameTaken when its nickname is.
 	StoreUser(context.Context, types.User) error
-	DeleteUser(ctx context.Context, id int) error
-	GetUser(ctx context.Context, id int) (types.User, error)
+	// UpdateUser replaces the user, it fails with ErrNotFound when the user does not exist
+	// and with ErrNicknameTaken when its nickname is taken by another user.
+	UpdateUser(ctx context.Context, user types.User) error
+	DeleteUser(ctx context.Context, id int64) error
+	GetUser(ctx context.Context, id int64) (types.User, error)
+	// ListUser lists all users ordered by id.
 	ListUser(ctx context.Context) ([]types.User, error)
+	// ListUserPage lists up to limit users with ids greater than afterId, ordered by id.
+	ListUserPage(ctx context.Context, afterId int64, limit int) ([]types.User, error)
+}
+
+// AvatarStore keeps the avatars uploaded by the users, one per user.
+type AvatarStore interface {
+	// StoreAvatar replaces the avatar of the user.
+	StoreAvatar(ctx context.Context, avatar types.Avatar) error
+	GetAvatar(ctx context.Context, userId int64) (types.Avatar, error)
+	DeleteAvatar(ctx context.Context, userId int64) error
+}
+
+// ContactStore keeps the relations of the users to their contacts, one per pair and
+// direction.
+type ContactStore interface {
+	// GetContact returns the relation of userId to contactId, it fails with ErrNotFound
+	// when there is none.
+	GetContact(ctx context.Context, userId int64, contactId int64) (types.Contact, error)
+	// PutContact creates or replaces the relation of contact.UserId to contact.ContactId.
+	PutContact(ctx context.Context, contact types.Contact) error
+	DeleteContact(ctx context.Context, userId int64, contactId int64) error
+	// DeleteUserContacts deletes the relations of the user and the relations to it.
+	DeleteUserContacts(ctx context.Context, userId int64) error
+	// ListContacts lists the relations of userId in state, ordered by contact id.
+	ListContacts(ctx context.Context, userId int64, state types.ContactState) ([]types.Contact, error)
+	// ListContactRequests lists the pending requests to userId, ordered by the ids of
+	// the requesting users.
+	ListContactRequests(ctx context.Context, userId int64) ([]types.Contact, error)
+}
+
+// AuditStore is append-only, entries are listed newest first.
+type AuditStore interface {
+	AppendAudit(ctx context.Context, entry types.AuditEntry) error
+	ListAudit(ctx context.Context, filter AuditFilter) ([]types.AuditEntry, error)
+}
+
+// AuditFilter selects audit entries, zero fields match every entry.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	// Since and Until bound the time of the entries, Until is excluded
+	Since time.Time
+	Until time.Time
+	// BeforeId pages through the entries, it is the id of the last entry of the previous page
+	BeforeId int64
+	Limit    int
 }
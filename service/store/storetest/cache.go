@@ -0,0 +1,159 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/service/store/cache_store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// TestUserCache checks the semantics of a cache_store.UserCache, newCache returns an
+// empty cache. A cache is not a store: it holds the users written to it, whatever their
+// ids and nicknames, and lists them only while its index is complete.
+func TestUserCache(t *testing.T, newCache func(t *testing.T) cache_store.UserCache) {
+	ctx := context.Background()
+
+	t.Run("get", func(t *testing.T) {
+		users := newCache(t)
+		if _, err := users.GetUser(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected a user not cached, got %v", err)
+		}
+		user := types.User{
+			UserId: 1, Nickname: "latte", Sex: types.Female, Age: 20, Birthday: 946684800,
+			AvatarUrl: "https://example.com/latte.png", Bio: "milk first", Status: "brewing",
+		}
+		if err := users.StoreUser(ctx, user); err != nil {
+			t.Fatalf("failed to cache user: %v", err)
+		}
+		if got, err := users.GetUser(ctx, 1); err != nil || got != user {
+			t.Fatalf("expected %+v, got %+v %v", user, got, err)
+		}
+		user.Nickname = "mocha"
+		if err := users.UpdateUser(ctx, user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if got, err := users.GetUser(ctx, 1); err != nil || got != user {
+			t.Fatalf("expected the updated user %+v, got %+v %v", user, got, err)
+		}
+		if err := users.DeleteUser(ctx, 1); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		if _, err := users.GetUser(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected the deleted user not to be cached, got %v", err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		users := newCache(t)
+		if err := users.StoreMissingUser(ctx, 1); err != nil {
+			t.Fatalf("failed to cache missing user: %v", err)
+		}
+		if user, err := users.GetUser(ctx, 1); err != nil || user.UserId != types.InvalidUserId {
+			t.Fatalf("expected an invalid user, got %+v %v", user, err)
+		}
+		// a user registered since it was found missing replaces it
+		if err := users.StoreUser(ctx, types.User{UserId: 1, Nickname: "latte"}); err != nil {
+			t.Fatalf("failed to cache user: %v", err)
+		}
+		if err := users.StoreMissingUser(ctx, 1); err != nil {
+			t.Fatalf("failed to cache missing user: %v", err)
+		}
+		if user, err := users.GetUser(ctx, 1); err != nil || user.Nickname != "latte" {
+			t.Fatalf("expected the cached user to be kept, got %+v %v", user, err)
+		}
+	})
+
+	t.Run("index", func(t *testing.T) {
+		users := newCache(t)
+		if _, err := users.ListUser(ctx); !errors.Is(err, store.ErrIncomplete) {
+			t.Fatalf("expected an incomplete index before its rebuild, got %v", err)
+		}
+		version, err := users.UserIndexVersion(ctx)
+		if err != nil {
+			t.Fatalf("failed to read index version: %v", err)
+		}
+		rebuilt := []types.User{{UserId: 3, Nickname: "mocha"}, {UserId: snowflakeId, Nickname: "flat white"}, {UserId: 1, Nickname: "latte"}}
+		if err := users.RebuildUserIndex(ctx, version, rebuilt); err != nil {
+			t.Fatalf("failed to rebuild index: %v", err)
+		}
+		if list, err := users.ListUser(ctx); err != nil || !slices.Equal(userIds(list), []int64{1, 3, snowflakeId}) {
+			t.Fatalf("expected the users ordered by id, got %v %v", userIds(list), err)
+		}
+		if page, err := users.ListUserPage(ctx, 1, 1); err != nil || !slices.Equal(userIds(page), []int64{3}) {
+			t.Fatalf("unexpected page after 1: %v %v", userIds(page), err)
+		}
+
+		// writes keep the index complete
+		if err := users.StoreUser(ctx, types.User{UserId: 2, Nickname: "cortado"}); err != nil {
+			t.Fatalf("failed to cache user: %v", err)
+		}
+		if err := users.DeleteUser(ctx, 3); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		if list, err := users.ListUser(ctx); err != nil || !slices.Equal(userIds(list), []int64{1, 2, snowflakeId}) {
+			t.Fatalf("expected the writes in the index, got %v %v", userIds(list), err)
+		}
+
+		// a rebuild from users read before a write is dropped
+		version, err = users.UserIndexVersion(ctx)
+		if err != nil {
+			t.Fatalf("failed to read index version: %v", err)
+		}
+		if err := users.StoreUser(ctx, types.User{UserId: 4, Nickname: "ristretto"}); err != nil {
+			t.Fatalf("failed to cache user: %v", err)
+		}
+		if err := users.RebuildUserIndex(ctx, version, rebuilt); err == nil {
+			t.Fatalf("rebuilt the index from users read before a write")
+		}
+		if list, err := users.ListUser(ctx); err != nil || !slices.Equal(userIds(list), []int64{1, 2, 4, snowflakeId}) {
+			t.Fatalf("expected the index kept, got %v %v", userIds(list), err)
+		}
+
+		if err := users.InvalidateUserIndex(ctx); err != nil {
+			t.Fatalf("failed to invalidate index: %v", err)
+		}
+		if _, err := users.ListUser(ctx); !errors.Is(err, store.ErrIncomplete) {
+			t.Fatalf("expected an incomplete index after its invalidation, got %v", err)
+		}
+	})
+}
+
+// TestRoomCache checks the semantics of a cache_store.RoomCache, newCache returns an
+// empty cache.
+func TestRoomCache(t *testing.T, newCache func(t *testing.T) cache_store.RoomCache) {
+	ctx := context.Background()
+	rooms := newCache(t)
+
+	if _, err := rooms.GetRoom(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected a room not cached, got %v", err)
+	}
+	room := types.Room{RoomId: 1, MaxUnitSize: 3, State: types.RoomStateBanned, Version: 4, Units: []int64{7, 8}}
+	if err := rooms.UpdateRoom(ctx, room); err != nil {
+		t.Fatalf("failed to cache room: %v", err)
+	}
+	// the version and the units are cached with the room
+	got, err := rooms.GetRoom(ctx, 1)
+	if err != nil || got.MaxUnitSize != 3 || got.State != types.RoomStateBanned || got.Version != 4 || !slices.Equal(got.Units, []int64{7, 8}) {
+		t.Fatalf("expected %+v, got %+v %v", room, got, err)
+	}
+	room.Version, room.Units = 5, []int64{7}
+	if err := rooms.UpdateRoom(ctx, room); err != nil {
+		t.Fatalf("failed to update room: %v", err)
+	}
+	if got, err := rooms.GetRoom(ctx, 1); err != nil || got.Version != 5 || !slices.Equal(got.Units, []int64{7}) {
+		t.Fatalf("expected the updated room, got %+v %v", got, err)
+	}
+	if err := rooms.DeleteRoom(ctx, 1); err != nil {
+		t.Fatalf("failed to delete room: %v", err)
+	}
+	if _, err := rooms.GetRoom(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected the deleted room not to be cached, got %v", err)
+	}
+	if err := rooms.DeleteRoom(ctx, 1); err != nil {
+		t.Fatalf("failed to delete a room not cached: %v", err)
+	}
+}
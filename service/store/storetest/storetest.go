@@ -0,0 +1,525 @@
+// Package storetest is the conformance suite of the store interfaces, every implementation
+// runs it from its tests so that they can replace each other.
+package storetest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheChosenGay/coffee/service/store"
+	"github.com/TheChosenGay/coffee/types"
+)
+
+// Flusher is implemented by stores writing in background, the suite flushes them before
+// it checks what was written.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+func flush(t *testing.T, s any) {
+	t.Helper()
+	if f, ok := s.(Flusher); ok {
+		if err := f.Flush(context.Background()); err != nil {
+			t.Fatalf("failed to flush store: %v", err)
+		}
+	}
+}
+
+// snowflakeId is larger than the ids whose decimal strings sort like their numbers.
+const snowflakeId int64 = 7340032405323632640
+
+func userIds(users []types.User) []int64 {
+	ids := make([]int64, len(users))
+	for i, user := range users {
+		ids[i] = user.UserId
+	}
+	return ids
+}
+
+// TestUserStore checks the semantics of a store.UserStore, newStore returns an empty store.
+func TestUserStore(t *testing.T, newStore func(t *testing.T) store.UserStore) {
+	ctx := context.Background()
+
+	t.Run("get", func(t *testing.T) {
+		users := newStore(t)
+		if _, err := users.GetUser(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected a missing user, got %v", err)
+		}
+		user := types.User{
+			UserId: 1, Nickname: "latte", Sex: types.Female, Age: 20, Birthday: 946684800,
+			AvatarUrl: "https://example.com/latte.png", Bio: "milk first", Status: "brewing",
+		}
+		if err := users.StoreUser(ctx, user); err != nil {
+			t.Fatalf("failed to store user: %v", err)
+		}
+		if got, err := users.GetUser(ctx, 1); err != nil || got != user {
+			t.Fatalf("expected %+v, got %+v %v", user, got, err)
+		}
+		if err := users.StoreUser(ctx, types.User{UserId: 1, Nickname: "mocha"}); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("expected a conflict storing an existing user, got %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		users := newStore(t)
+		if err := users.StoreUser(ctx, types.User{UserId: 1, Nickname: "latte"}); err != nil {
+			t.Fatalf("failed to store user: %v", err)
+		}
+		if _, err := users.GetUser(ctx, 1); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if err := users.DeleteUser(ctx, 1); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		if _, err := users.GetUser(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected the deleted user to be missing, got %v", err)
+		}
+		if err := users.DeleteUser(ctx, 2); err != nil {
+			t.Fatalf("expected deleting a missing user to do nothing, got %v", err)
+		}
+	})
+
+	t.Run("nickname", func(t *testing.T) {
+		users := newStore(t)
+		if err := users.StoreUser(ctx, types.User{UserId: 1, Nickname: "latte"}); err != nil {
+			t.Fatalf("failed to store user: %v", err)
+		}
+		if err := users.StoreUser(ctx, types.User{UserId: 2, Nickname: "latte"}); !errors.Is(err, store.ErrNicknameTaken) {
+			t.Fatalf("expected the nickname to be taken, got %v", err)
+		}
+		if _, err := users.GetUser(ctx, 2); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected the user with a taken nickname not to be stored, got %v", err)
+		}
+		// nicknames differing in case are distinct
+		if err := users.StoreUser(ctx, types.User{UserId: 2, Nickname: "Latte"}); err != nil {
+			t.Fatalf("failed to store user: %v", err)
+		}
+		if err := users.DeleteUser(ctx, 1); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		if err := users.StoreUser(ctx, types.User{UserId: 3, Nickname: "latte"}); err != nil {
+			t.Fatalf("expected the nickname of a deleted user to be free, got %v", err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		users := newStore(t)
+		for _, user := range []types.User{{UserId: 1, Nickname: "latte", Bio: "milk first"}, {UserId: 2, Nickname: "mocha"}} {
+			if err := users.StoreUser(ctx, user); err != nil {
+				t.Fatalf("failed to store user: %v", err)
+			}
+		}
+		if _, err := users.GetUser(ctx, 1); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		// an empty bio is written too
+		user := types.User{UserId: 1, Nickname: "flat white", Sex: types.Female, Age: 30, AvatarUrl: "https://example.com/a.png", Status: "away"}
+		if err := users.UpdateUser(ctx, user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if got, err := users.GetUser(ctx, 1); err != nil || got != user {
+			t.Fatalf("expected %+v, got %+v %v", user, got, err)
+		}
+		if err := users.StoreUser(ctx, types.User{UserId: 3, Nickname: "latte"}); err != nil {
+			t.Fatalf("expected the previous nickname to be free, got %v", err)
+		}
+		if err := users.UpdateUser(ctx, types.User{UserId: 1, Nickname: "mocha"}); !errors.Is(err, store.ErrNicknameTaken) {
+			t.Fatalf("expected the nickname to be taken, got %v", err)
+		}
+		if got, err := users.GetUser(ctx, 1); err != nil || got != user {
+			t.Fatalf("expected the user to be kept after a taken nickname, got %+v %v", got, err)
+		}
+		if err := users.UpdateUser(ctx, types.User{UserId: 4, Nickname: "cortado"}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected updating a missing user to fail, got %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		users := newStore(t)
+		for _, id := range []int64{snowflakeId, 10, 9, 11} {
+			if err := users.StoreUser(ctx, types.User{UserId: id, Nickname: fmt.Sprintf("user %d", id)}); err != nil {
+				t.Fatalf("failed to store user: %v", err)
+			}
+		}
+		if err := users.DeleteUser(ctx, 11); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		flush(t, users)
+
+		// twice, a cache may serve the second list
+		for range 2 {
+			list, err := users.ListUser(ctx)
+			if err != nil || !slices.Equal(userIds(list), []int64{9, 10, snowflakeId}) {
+				t.Fatalf("expected the users ordered by id, got %v %v", userIds(list), err)
+			}
+			flush(t, users)
+		}
+		page, err := users.ListUserPage(ctx, 0, 2)
+		if err != nil || !slices.Equal(userIds(page), []int64{9, 10}) {
+			t.Fatalf("unexpected first page: %v %v", userIds(page), err)
+		}
+		page, err = users.ListUserPage(ctx, 10, 2)
+		if err != nil || !slices.Equal(userIds(page), []int64{snowflakeId}) {
+			t.Fatalf("unexpected last page: %v %v", userIds(page), err)
+		}
+		page, err = users.ListUserPage(ctx, snowflakeId, 2)
+		if err != nil || len(page) != 0 {
+			t.Fatalf("expected an empty page, got %v %v", userIds(page), err)
+		}
+	})
+}
+
+// TestRoomStore checks the semantics of a store.RoomStore, newStore returns an empty store.
+func TestRoomStore(t *testing.T, newStore func(t *testing.T) store.RoomStore) {
+	ctx := context.Background()
+
+	t.Run("get", func(t *testing.T) {
+		rooms := newStore(t)
+		if _, err := rooms.GetRoom(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected a missing room, got %v", err)
+		}
+		if err := rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 3, Units: []int64{7, 8}}); err != nil {
+			t.Fatalf("failed to create room: %v", err)
+		}
+		room, err := rooms.GetRoom(ctx, 1)
+		if err != nil || room.MaxUnitSize != 3 || room.State != types.RoomStateNormal || !slices.Equal(room.Units, []int64{7, 8}) {
+			t.Fatalf("unexpected room: %+v %v", room, err)
+		}
+		if err := rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 5}); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("expected a conflict creating an existing room, got %v", err)
+		}
+		if err := rooms.DeleteRoom(ctx, 1); err != nil {
+			t.Fatalf("failed to delete room: %v", err)
+		}
+		if _, err := rooms.GetRoom(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected the deleted room to be missing, got %v", err)
+		}
+		if members, err := rooms.ListRoomMembers(ctx, 1); err != nil || len(members) != 0 {
+			t.Fatalf("expected the members of the deleted room to be deleted, got %v %v", members, err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		rooms := newStore(t)
+		if err := rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 3}); err != nil {
+			t.Fatalf("failed to create room: %v", err)
+		}
+		room, _ := rooms.GetRoom(ctx, 1)
+		stale := room
+		room.State = types.RoomStateBanned
+		if err := rooms.UpdateRoom(ctx, room); err != nil {
+			t.Fatalf("failed to update room: %v", err)
+		}
+		if err := rooms.UpdateRoom(ctx, stale); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("expected a conflict updating a stale room, got %v", err)
+		}
+		room, _ = rooms.GetRoom(ctx, 1)
+		if room.State != types.RoomStateBanned || room.Version == stale.Version {
+			t.Fatalf("expected the banned room at a new version, got %+v", room)
+		}
+		// zero values are written too
+		room.State = types.RoomStateNormal
+		if err := rooms.UpdateRoom(ctx, room); err != nil {
+			t.Fatalf("failed to update room: %v", err)
+		}
+		if room, _ = rooms.GetRoom(ctx, 1); room.State != types.RoomStateNormal {
+			t.Fatalf("expected the room to be unbanned, got %+v", room)
+		}
+		if err := rooms.UpdateRoom(ctx, types.Room{RoomId: 2}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected a missing room, got %v", err)
+		}
+	})
+
+	t.Run("members", func(t *testing.T) {
+		rooms := newStore(t)
+		if err := rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 2}); err != nil {
+			t.Fatalf("failed to create room: %v", err)
+		}
+		joinedAt := time.Now().Truncate(time.Second)
+		for i, userId := range []int64{8, 7} {
+			room, _ := rooms.GetRoom(ctx, 1)
+			member := types.RoomMember{RoomId: 1, UserId: userId, Role: types.Member, JoinedAt: joinedAt.Add(time.Duration(i) * time.Second)}
+			if err := rooms.AddRoomMember(ctx, room, member); err != nil {
+				t.Fatalf("failed to add member %d: %v", userId, err)
+			}
+		}
+		room, _ := rooms.GetRoom(ctx, 1)
+		if !slices.Equal(room.Units, []int64{8, 7}) {
+			t.Fatalf("expected the units by the time they joined, got %v", room.Units)
+		}
+		if err := rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: 8, Role: types.Admin, JoinedAt: time.Now()}); err != nil {
+			t.Fatalf("expected adding a member twice to do nothing, got %v", err)
+		}
+		if err := rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: 9, Role: types.Member, JoinedAt: time.Now()}); !errors.Is(err, store.ErrRoomFull) {
+			t.Fatalf("expected the room to be full, got %v", err)
+		}
+		members, err := rooms.ListRoomMembers(ctx, 1)
+		if err != nil || len(members) != 2 || members[0].UserId != 8 || members[0].Role != types.Member ||
+			members[0].JoinedAt.Sub(joinedAt).Abs() >= time.Millisecond {
+			t.Fatalf("unexpected members: %+v %v", members, err)
+		}
+
+		if err := rooms.RemoveRoomMember(ctx, 1, 8); err != nil {
+			t.Fatalf("failed to remove member: %v", err)
+		}
+		if err := rooms.RemoveRoomMember(ctx, 1, 8); err != nil {
+			t.Fatalf("expected removing a missing member to do nothing, got %v", err)
+		}
+		// room was read before the member was removed
+		if err := rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: 9, JoinedAt: time.Now()}); !errors.Is(err, store.ErrConflict) {
+			t.Fatalf("expected a conflict adding to a stale room, got %v", err)
+		}
+		room, _ = rooms.GetRoom(ctx, 1)
+		if err := rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: 9, JoinedAt: joinedAt.Add(2 * time.Second)}); err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+		if room, _ = rooms.GetRoom(ctx, 1); !slices.Equal(room.Units, []int64{7, 9}) {
+			t.Fatalf("unexpected units: %v", room.Units)
+		}
+	})
+
+	t.Run("concurrent members", func(t *testing.T) {
+		rooms := newStore(t)
+		if err := rooms.CreateRoom(ctx, types.Room{RoomId: 1, MaxUnitSize: 3}); err != nil {
+			t.Fatalf("failed to create room: %v", err)
+		}
+		var wg sync.WaitGroup
+		var mx sync.Mutex
+		full := 0
+		for userId := range int64(10) {
+			wg.Go(func() {
+				// retry on conflicts like the room service
+				for {
+					room, err := rooms.GetRoom(ctx, 1)
+					if err != nil {
+						t.Errorf("failed to get room: %v", err)
+						return
+					}
+					err = rooms.AddRoomMember(ctx, room, types.RoomMember{RoomId: 1, UserId: userId, Role: types.Member, JoinedAt: time.Now()})
+					if errors.Is(err, store.ErrConflict) {
+						continue
+					}
+					if errors.Is(err, store.ErrRoomFull) {
+						mx.Lock()
+						full++
+						mx.Unlock()
+					} else if err != nil {
+						t.Errorf("failed to add member %d: %v", userId, err)
+					}
+					return
+				}
+			})
+		}
+		wg.Wait()
+		room, err := rooms.GetRoom(ctx, 1)
+		if err != nil || len(room.Units) != 3 || full != 7 {
+			t.Fatalf("expected 3 members and 7 full rooms, got %+v, %v and %d full", room, err, full)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		rooms := newStore(t)
+		for _, room := range []types.Room{{RoomId: 2, MaxUnitSize: 2, Units: []int64{7}}, {RoomId: 1, MaxUnitSize: 3}} {
+			if err := rooms.CreateRoom(ctx, room); err != nil {
+				t.Fatalf("failed to create room: %v", err)
+			}
+		}
+		list, err := rooms.ListRoom(ctx)
+		if err != nil || len(list) != 2 {
+			t.Fatalf("unexpected rooms: %v %v", list, err)
+		}
+		slices.SortFunc(list, func(a, b *types.Room) int { return cmp.Compare(a.RoomId, b.RoomId) })
+		if list[0].MaxUnitSize != 3 || len(list[0].Units) != 0 || !slices.Equal(list[1].Units, []int64{7}) {
+			t.Fatalf("unexpected rooms: %+v %+v", list[0], list[1])
+		}
+	})
+}
+
+// TestAuditStore checks the semantics of a store.AuditStore, newStore returns an empty store.
+func TestAuditStore(t *testing.T, newStore func(t *testing.T) store.AuditStore) {
+	ctx := context.Background()
+	audits := newStore(t)
+	start := time.Now().UTC().Truncate(time.Second)
+	for i, entry := range []types.AuditEntry{
+		{Actor: "admin:alice", Action: "ban_room", Target: "room:1", Before: []byte(`{"state":0}`), After: []byte(`{"state":1}`)},
+		{Actor: "admin:bob", Action: "delete_user", Target: "user:7", Error: "user 7 not found"},
+		{Actor: "admin:alice", Action: "unban_room", Target: "room:1"},
+	} {
+		entry.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		if err := audits.AppendAudit(ctx, entry); err != nil {
+			t.Fatalf("failed to append audit entry: %v", err)
+		}
+	}
+
+	entries, err := audits.ListAudit(ctx, store.AuditFilter{})
+	if err != nil || len(entries) != 3 || entries[0].Action != "unban_room" || entries[0].Id <= entries[1].Id {
+		t.Fatalf("expected the entries newest first, got %+v %v", entries, err)
+	}
+	if string(entries[2].After) != `{"state":1}` || entries[0].Before != nil || entries[1].Error != "user 7 not found" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries, _ := audits.ListAudit(ctx, store.AuditFilter{Actor: "admin:alice", Target: "room:1"}); len(entries) != 2 {
+		t.Fatalf("expected the entries of alice, got %+v", entries)
+	}
+	if entries, _ := audits.ListAudit(ctx, store.AuditFilter{Action: "delete_user"}); len(entries) != 1 {
+		t.Fatalf("expected the entries of the action, got %+v", entries)
+	}
+	page, err := audits.ListAudit(ctx, store.AuditFilter{BeforeId: entries[0].Id, Limit: 1})
+	if err != nil || len(page) != 1 || page[0].Action != "delete_user" {
+		t.Fatalf("unexpected page: %+v %v", page, err)
+	}
+	recent, err := audits.ListAudit(ctx, store.AuditFilter{Since: start.Add(time.Second), Until: start.Add(2 * time.Second)})
+	if err != nil || len(recent) != 1 || recent[0].Target != "user:7" {
+		t.Fatalf("unexpected entries in time range: %+v %v", recent, err)
+	}
+}
+
+// TestCoffeeStore checks the semantics of a store.CoffeeStore holding coffees.
+func TestCoffeeStore(t *testing.T, coffees store.CoffeeStore, want []types.Coffee) {
+	ctx := context.Background()
+	list, err := coffees.ListCoffees(ctx)
+	if err != nil || !slices.Equal(list, want) {
+		t.Fatalf("expected %+v, got %+v %v", want, list, err)
+	}
+	for _, coffee := range want {
+		if got, err := coffees.GetCoffeeById(ctx, coffee.Id); err != nil || got != coffee {
+			t.Fatalf("expected %+v, got %+v %v", coffee, got, err)
+		}
+	}
+	if _, err := coffees.GetCoffeeById(ctx, -1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected a missing coffee, got %v", err)
+	}
+}
+
+// TestAvatarStore checks the semantics of a store.AvatarStore, newStore returns an empty store.
+func TestAvatarStore(t *testing.T, newStore func(t *testing.T) store.AvatarStore) {
+	ctx := context.Background()
+	avatars := newStore(t)
+
+	if _, err := avatars.GetAvatar(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected a missing avatar, got %v", err)
+	}
+	updatedAt := time.Now().Truncate(time.Second)
+	for _, avatar := range []types.Avatar{
+		{UserId: 1, ContentType: "image/png", Data: []byte("png"), UpdatedAt: updatedAt},
+		{UserId: 1, ContentType: "image/gif", Data: []byte("gif"), UpdatedAt: updatedAt.Add(time.Second)},
+	} {
+		if err := avatars.StoreAvatar(ctx, avatar); err != nil {
+			t.Fatalf("failed to store avatar: %v", err)
+		}
+	}
+	avatar, err := avatars.GetAvatar(ctx, 1)
+	if err != nil || avatar.ContentType != "image/gif" || string(avatar.Data) != "gif" || !avatar.UpdatedAt.Equal(updatedAt.Add(time.Second)) {
+		t.Fatalf("expected the replaced avatar, got %+v %v", avatar, err)
+	}
+	if err := avatars.DeleteAvatar(ctx, 1); err != nil {
+		t.Fatalf("failed to delete avatar: %v", err)
+	}
+	if _, err := avatars.GetAvatar(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected the deleted avatar to be missing, got %v", err)
+	}
+	if err := avatars.DeleteAvatar(ctx, 2); err != nil {
+		t.Fatalf("expected deleting a missing avatar to do nothing, got %v", err)
+	}
+}
+
+func contactIds(contacts []types.Contact) [][2]int64 {
+	ids := make([][2]int64, len(contacts))
+	for i, contact := range contacts {
+		ids[i] = [2]int64{contact.UserId, contact.ContactId}
+	}
+	return ids
+}
+
+// TestContactStore checks the semantics of a store.ContactStore, newStore returns an empty store.
+func TestContactStore(t *testing.T, newStore func(t *testing.T) store.ContactStore) {
+	ctx := context.Background()
+	updatedAt := time.Now().Truncate(time.Second)
+
+	t.Run("get", func(t *testing.T) {
+		contacts := newStore(t)
+		if _, err := contacts.GetContact(ctx, 1, 2); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected a missing contact, got %v", err)
+		}
+		for _, contact := range []types.Contact{
+			{UserId: 1, ContactId: 2, State: types.ContactRequested, UpdatedAt: updatedAt},
+			{UserId: 1, ContactId: 2, State: types.ContactFriend, UpdatedAt: updatedAt.Add(time.Second)},
+		} {
+			if err := contacts.PutContact(ctx, contact); err != nil {
+				t.Fatalf("failed to put contact: %v", err)
+			}
+		}
+		contact, err := contacts.GetContact(ctx, 1, 2)
+		if err != nil || contact.State != types.ContactFriend || !contact.UpdatedAt.Equal(updatedAt.Add(time.Second)) {
+			t.Fatalf("expected the replaced contact, got %+v %v", contact, err)
+		}
+		// the relations are directed
+		if _, err := contacts.GetContact(ctx, 2, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected the reverse contact to be missing, got %v", err)
+		}
+		if err := contacts.DeleteContact(ctx, 1, 2); err != nil {
+			t.Fatalf("failed to delete contact: %v", err)
+		}
+		if _, err := contacts.GetContact(ctx, 1, 2); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected the deleted contact to be missing, got %v", err)
+		}
+		if err := contacts.DeleteContact(ctx, 1, 2); err != nil {
+			t.Fatalf("expected deleting a missing contact to do nothing, got %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		contacts := newStore(t)
+		for _, contact := range []types.Contact{
+			{UserId: 1, ContactId: snowflakeId, State: types.ContactFriend},
+			{UserId: 1, ContactId: 3, State: types.ContactFriend},
+			{UserId: 1, ContactId: 4, State: types.ContactBlocked},
+			{UserId: 1, ContactId: 5, State: types.ContactRequested},
+			{UserId: 6, ContactId: 1, State: types.ContactRequested},
+			{UserId: 2, ContactId: 1, State: types.ContactRequested},
+			{UserId: 7, ContactId: 1, State: types.ContactBlocked},
+		} {
+			contact.UpdatedAt = updatedAt
+			if err := contacts.PutContact(ctx, contact); err != nil {
+				t.Fatalf("failed to put contact: %v", err)
+			}
+		}
+		list, err := contacts.ListContacts(ctx, 1, types.ContactFriend)
+		if err != nil || !slices.Equal(contactIds(list), [][2]int64{{1, 3}, {1, snowflakeId}}) {
+			t.Fatalf("expected the friends ordered by id, got %v %v", contactIds(list), err)
+		}
+		list, err = contacts.ListContacts(ctx, 1, types.ContactBlocked)
+		if err != nil || !slices.Equal(contactIds(list), [][2]int64{{1, 4}}) {
+			t.Fatalf("unexpected blocked contacts: %v %v", contactIds(list), err)
+		}
+		list, err = contacts.ListContactRequests(ctx, 1)
+		if err != nil || !slices.Equal(contactIds(list), [][2]int64{{2, 1}, {6, 1}}) {
+			t.Fatalf("expected the requests ordered by requesting user, got %v %v", contactIds(list), err)
+		}
+		list, err = contacts.ListContacts(ctx, 8, types.ContactFriend)
+		if err != nil || list == nil || len(list) != 0 {
+			t.Fatalf("expected an empty list, got %v %v", list, err)
+		}
+
+		if err := contacts.DeleteUserContacts(ctx, 1); err != nil {
+			t.Fatalf("failed to delete the contacts of the user: %v", err)
+		}
+		list, err = contacts.ListContacts(ctx, 1, types.ContactFriend)
+		if err != nil || len(list) != 0 {
+			t.Fatalf("expected the contacts of the user to be deleted, got %v %v", contactIds(list), err)
+		}
+		list, err = contacts.ListContactRequests(ctx, 1)
+		if err != nil || len(list) != 0 {
+			t.Fatalf("expected the requests to the user to be deleted, got %v %v", contactIds(list), err)
+		}
+		if _, err := contacts.GetContact(ctx, 7, 1); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("expected the relations to the user to be deleted, got %v", err)
+		}
+	})
+}
@@ -2,49 +2,299 @@ package service
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/TheChosenGay/coffee/internal/logging"
+	"github.com/TheChosenGay/coffee/internal/tracing"
 	"github.com/TheChosenGay/coffee/service/store"
 	"github.com/TheChosenGay/coffee/types"
 )
 
+var logger = logging.Package("service")
+
 type UserService interface {
-	RegisterUser(ctx context.Context, user types.User) (int, error)
-	DeleteUser(ctx context.Context, id int) error
-	GetUser(ctx context.Context, id int) (types.User, error)
+	RegisterUser(ctx context.Context, user types.User) (int64, error)
+	DeleteUser(ctx context.Context, id int64) error
+	GetUser(ctx context.Context, id int64) (types.User, error)
 	ListUser(ctx context.Context) ([]types.User, error)
+	// ListUserPage lists up to limit users with ids greater than afterId, ordered by id.
+	ListUserPage(ctx context.Context, afterId int64, limit int) ([]types.User, error)
+	// UpdateProfile changes the fields of update which are set and returns the user.
+	UpdateProfile(ctx context.Context, id int64, update types.ProfileUpdate) (types.User, error)
+	// UploadAvatar replaces the avatar of the user by the image of data, the avatar url
+	// of the returned user serves it.
+	UploadAvatar(ctx context.Context, id int64, data []byte) (types.User, error)
+	GetAvatar(ctx context.Context, id int64) (types.Avatar, error)
 }
 
+// MaxUserPageLimit bounds the users of a page.
+const MaxUserPageLimit = 1000
+
+// Bounds of the profiles of users, lengths are counted in characters.
+const (
+	MaxNicknameLength  = 32
+	MaxBioLength       = 256
+	MaxStatusLength    = 64
+	MaxAvatarUrlLength = 512
+	MaxAge             = 150
+	// MaxAvatarSize bounds the bytes of an uploaded avatar.
+	MaxAvatarSize = 1 << 20
+)
+
+// AvatarContentTypes are the images accepted as avatars, detected from their content.
+var AvatarContentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// avatarUrlFormat is the url of an uploaded avatar on the json api, its version changes
+// with every upload so that clients do not keep the previous image.
+const avatarUrlFormat = "/v2/users/%d/avatar?v=%d"
+
 type userService struct {
+	units     store.UnitOfWork
 	store     store.UserStore
+	avatars   store.AvatarStore
 	idService IdService
+	auditor   *Auditor
 }
 
-func NewUserService(store store.UserStore, idService IdService) UserService {
+func NewUserService(units store.UnitOfWork, store store.UserStore, avatars store.AvatarStore, idService IdService, auditor *Auditor) UserService {
 	return &userService{
+		units:     units,
 		store:     store,
+		avatars:   avatars,
 		idService: idService,
+		auditor:   auditor,
 	}
 }
 
-func (s *userService) RegisterUser(ctx context.Context, user types.User) (int, error) {
-	user.UserId = s.idService.GenerateId()
-	err := s.store.StoreUser(ctx, user)
+func (s *userService) RegisterUser(ctx context.Context, user types.User) (_ int64, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RegisterUser")
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateProfile(profileOf(user)); err != nil {
+		return types.InvalidUserId, err
+	}
+	userId, err := s.idService.GenerateId(ctx)
+	if err != nil {
+		return types.InvalidUserId, err
+	}
+	user.UserId = userId
+	err = s.store.StoreUser(ctx, user)
+	if errors.Is(err, store.ErrNicknameTaken) {
+		return types.InvalidUserId, Conflict("nickname %q is taken", user.Nickname)
+	}
 	if err != nil {
 		return types.InvalidUserId, err
 	}
 	return user.UserId, nil
 }
 
-func (s *userService) DeleteUser(ctx context.Context, id int) error {
-	return s.store.DeleteUser(ctx, id)
+func (s *userService) DeleteUser(ctx context.Context, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser")
+	defer func() { tracing.End(span, err) }()
+
+	var before *types.User
+	err = s.units.Do(ctx, func(stores store.Stores) error {
+		if user, err := stores.Users.GetUser(ctx, id); err == nil {
+			before = &user
+		}
+		if err := stores.Avatars.DeleteAvatar(ctx, id); err != nil {
+			return err
+		}
+		if err := stores.Contacts.DeleteUserContacts(ctx, id); err != nil {
+			return err
+		}
+		return stores.Users.DeleteUser(ctx, id)
+	})
+	s.auditor.Record(ctx, "delete_user", "user:"+strconv.FormatInt(id, 10), before, nil, err)
+	return err
 }
 
-func (s *userService) GetUser(ctx context.Context, id int) (types.User, error) {
-	log.Printf("get user: %d\n", ctx.Value("requestId"))
-	return s.store.GetUser(ctx, id)
+func (s *userService) GetUser(ctx context.Context, id int64) (_ types.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUser")
+	defer func() { tracing.End(span, err) }()
+
+	logger.Ctx(ctx).Debugf("get user: %d", id)
+	user, err := s.store.GetUser(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return user, NotFound("user %d not found", id)
+	}
+	return user, err
 }
 
-func (s *userService) ListUser(ctx context.Context) ([]types.User, error) {
+func (s *userService) ListUser(ctx context.Context) (_ []types.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListUser")
+	defer func() { tracing.End(span, err) }()
+
 	return s.store.ListUser(ctx)
 }
+
+func (s *userService) ListUserPage(ctx context.Context, afterId int64, limit int) (_ []types.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListUserPage")
+	defer func() { tracing.End(span, err) }()
+
+	if limit <= 0 || limit > MaxUserPageLimit {
+		return nil, InvalidArgument("limit must be in [1, %d]", MaxUserPageLimit)
+	}
+	return s.store.ListUserPage(ctx, afterId, limit)
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, id int64, update types.ProfileUpdate) (_ types.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateProfile")
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateProfile(update); err != nil {
+		return types.User{UserId: types.InvalidUserId}, err
+	}
+	return s.updateUser(ctx, "update_profile", id, func(stores store.Stores, user *types.User) error {
+		applyProfile(user, update)
+		return nil
+	})
+}
+
+func (s *userService) UploadAvatar(ctx context.Context, id int64, data []byte) (_ types.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UploadAvatar")
+	defer func() { tracing.End(span, err) }()
+
+	if len(data) == 0 {
+		return types.User{UserId: types.InvalidUserId}, InvalidArgument("avatar is empty")
+	}
+	if len(data) > MaxAvatarSize {
+		return types.User{UserId: types.InvalidUserId}, InvalidArgument("avatar is larger than %d bytes", MaxAvatarSize)
+	}
+	contentType := http.DetectContentType(data)
+	if !slices.Contains(AvatarContentTypes, contentType) {
+		return types.User{UserId: types.InvalidUserId}, InvalidArgument("avatar must be one of %s, got %s", strings.Join(AvatarContentTypes, ", "), contentType)
+	}
+	return s.updateUser(ctx, "upload_avatar", id, func(stores store.Stores, user *types.User) error {
+		avatar := types.Avatar{UserId: id, ContentType: contentType, Data: data, UpdatedAt: time.Now().UTC()}
+		if err := stores.Avatars.StoreAvatar(ctx, avatar); err != nil {
+			return err
+		}
+		user.AvatarUrl = fmt.Sprintf(avatarUrlFormat, id, avatar.UpdatedAt.Unix())
+		return nil
+	})
+}
+
+// updateUser changes the user of id by update in a unit of work and records action in
+// the audit trail.
+func (s *userService) updateUser(ctx context.Context, action string, id int64, update func(stores store.Stores, user *types.User) error) (types.User, error) {
+	var before, after *types.User
+	err := s.units.Do(ctx, func(stores store.Stores) error {
+		user, err := stores.Users.GetUser(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return NotFound("user %d not found", id)
+		}
+		if err != nil {
+			return err
+		}
+		before = &user
+		updated := user
+		if err := update(stores, &updated); err != nil {
+			return err
+		}
+		err = stores.Users.UpdateUser(ctx, updated)
+		if errors.Is(err, store.ErrNicknameTaken) {
+			return Conflict("nickname %q is taken", updated.Nickname)
+		}
+		if err != nil {
+			return err
+		}
+		after = &updated
+		return nil
+	})
+	s.auditor.Record(ctx, action, "user:"+strconv.FormatInt(id, 10), before, after, err)
+	if err != nil {
+		return types.User{UserId: types.InvalidUserId}, err
+	}
+	return *after, nil
+}
+
+func (s *userService) GetAvatar(ctx context.Context, id int64) (_ types.Avatar, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetAvatar")
+	defer func() { tracing.End(span, err) }()
+
+	avatar, err := s.avatars.GetAvatar(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return avatar, NotFound("user %d has no avatar", id)
+	}
+	return avatar, err
+}
+
+// profileOf returns the profile of user as an update setting every field but the avatar
+// url, which is not given at registration.
+func profileOf(user types.User) types.ProfileUpdate {
+	return types.ProfileUpdate{
+		Nickname: &user.Nickname,
+		Sex:      &user.Sex,
+		Age:      &user.Age,
+		Birthday: &user.Birthday,
+		Bio:      &user.Bio,
+		Status:   &user.Status,
+	}
+}
+
+func applyProfile(user *types.User, update types.ProfileUpdate) {
+	setIf(&user.Nickname, update.Nickname)
+	setIf(&user.Sex, update.Sex)
+	setIf(&user.Age, update.Age)
+	setIf(&user.Birthday, update.Birthday)
+	setIf(&user.AvatarUrl, update.AvatarUrl)
+	setIf(&user.Bio, update.Bio)
+	setIf(&user.Status, update.Status)
+}
+
+func setIf[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+// validateProfile checks the fields of update which are set.
+func validateProfile(update types.ProfileUpdate) error {
+	if update.Nickname != nil {
+		nickname := *update.Nickname
+		if strings.TrimSpace(nickname) == "" {
+			return InvalidArgument("nickname must not be blank")
+		}
+		if utf8.RuneCountInString(nickname) > MaxNicknameLength {
+			return InvalidArgument("nickname must be at most %d characters", MaxNicknameLength)
+		}
+	}
+	if update.Sex != nil && *update.Sex != types.Male && *update.Sex != types.Female {
+		return InvalidArgument("invalid sex %d", *update.Sex)
+	}
+	if update.Age != nil && (*update.Age < 0 || *update.Age > MaxAge) {
+		return InvalidArgument("age must be in [0, %d]", MaxAge)
+	}
+	if update.Bio != nil && utf8.RuneCountInString(*update.Bio) > MaxBioLength {
+		return InvalidArgument("bio must be at most %d characters", MaxBioLength)
+	}
+	if update.Status != nil && utf8.RuneCountInString(*update.Status) > MaxStatusLength {
+		return InvalidArgument("status must be at most %d characters", MaxStatusLength)
+	}
+	if update.AvatarUrl != nil && *update.AvatarUrl != "" {
+		return validateAvatarUrl(*update.AvatarUrl)
+	}
+	return nil
+}
+
+// validateAvatarUrl accepts the http urls of external images, uploaded avatars are set
+// by UploadAvatar.
+func validateAvatarUrl(avatarUrl string) error {
+	if len(avatarUrl) > MaxAvatarUrlLength {
+		return InvalidArgument("avatar url must be at most %d bytes", MaxAvatarUrlLength)
+	}
+	u, err := url.Parse(avatarUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return InvalidArgument("avatar url must be an http or https url")
+	}
+	return nil
+}
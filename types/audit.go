@@ -0,0 +1,25 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records an administrative or moderation action, entries are never changed.
+type AuditEntry struct {
+	Id int64 `json:"id"`
+	// Actor performed the action, e.g. "admin:alice" or "ip:10.0.0.1"
+	Actor string `json:"actor"`
+	// Action is e.g. "ban_room" or "delete_user"
+	Action string `json:"action"`
+	// Target is what the action was performed on, e.g. "room:42"
+	Target    string `json:"target"`
+	RequestId string `json:"request_id,omitempty"`
+	// Before and After are the json of the target around the action, they are empty when
+	// the target did not exist before or does not exist after it.
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+	// Error is set when the action failed, failed attempts are audited too
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,7 +1,7 @@
 package types
 
 type Coffee struct {
-	Id           int    `json:"id"`
+	Id           int64  `json:"id"`
 	Name         string `json:"name"`
 	CoverUrl     string `json:"cover_url"`
 	Category     string `json:"category"`
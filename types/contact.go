@@ -0,0 +1,48 @@
+package types
+
+import "time"
+
+// ContactState is the relation of a user to one of its contacts.
+type ContactState int
+
+const (
+	// ContactRequested is a request of the user to be a contact, which is pending
+	ContactRequested ContactState = iota + 1
+	// ContactFriend is a contact accepted by both users, each of them has the relation
+	ContactFriend
+	// ContactBlocked rejects the requests and direct messages of the contact
+	ContactBlocked
+)
+
+func (s ContactState) String() string {
+	switch s {
+	case ContactRequested:
+		return "requested"
+	case ContactFriend:
+		return "friend"
+	case ContactBlocked:
+		return "blocked"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseContactState parses the name of a state as returned by String.
+func ParseContactState(name string) (ContactState, bool) {
+	for _, state := range []ContactState{ContactRequested, ContactFriend, ContactBlocked} {
+		if state.String() == name {
+			return state, true
+		}
+	}
+	return 0, false
+}
+
+// Contact is the relation of the user UserId to the user ContactId, the relations are
+// kept per user so that both users of a pair have their own.
+type Contact struct {
+	UserId    int64
+	ContactId int64
+	State     ContactState
+	// UpdatedAt is when the relation got its state
+	UpdatedAt time.Time
+}
@@ -22,13 +22,13 @@ const (
 type Message struct {
 	MsgType    MessageType // the type of the message
 	SignalType SignalType  // the type of the signal when msg type is MessageTypeSignal
-	TargetId   int
-	SenderId   int
+	TargetId   int64
+	SenderId   int64
 	Broadcast  bool // whether the message is broadcast to all units in the room
 	Contents   []*chat_service.Content
 }
 
-func NewMessage(msgType MessageType, targetId int, senderId int, contents []*chat_service.Content) *Message {
+func NewMessage(msgType MessageType, targetId int64, senderId int64, contents []*chat_service.Content) *Message {
 	return &Message{
 		MsgType:  msgType,
 		TargetId: targetId,
@@ -37,7 +37,7 @@ func NewMessage(msgType MessageType, targetId int, senderId int, contents []*cha
 	}
 }
 
-func NewSignalMessage(signalType SignalType, targetId int, senderId int, contents []*chat_service.Content) *Message {
+func NewSignalMessage(signalType SignalType, targetId int64, senderId int64, contents []*chat_service.Content) *Message {
 	return &Message{
 		MsgType:    MessageTypeSignal,
 		SignalType: signalType,
@@ -1,13 +1,17 @@
 package types
 
-import "github.com/TheChosenGay/coffee/proto/chat_service"
+import (
+	"time"
+
+	"github.com/TheChosenGay/coffee/proto/chat_service"
+)
 
 type Unit interface {
-	Id() int
+	Id() int64
 	NickName() string
 	// The callback function when the unit receives a message
-	Role(roomId int) (RoleType, error) // The Role of the unit in the room
-	SetRole(roomId int, role RoleType) error
+	Role(roomId int64) (RoleType, error) // The Role of the unit in the room
+	SetRole(roomId int64, role RoleType) error
 
 	// chat
 	SendMsg(msg *chat_service.ChatMessage) error
@@ -22,8 +26,21 @@ const (
 )
 
 type Room struct {
-	RoomId      int       `json:"room_id"`
+	RoomId      int64     `json:"room_id"`
 	MaxUnitSize int       `json:"max_unit_size"`
 	State       RoomState `json:"state"`
-	Units       []int     `json:"-" gorm:"serializer:json"` // all units of the room
+	// Version is incremented by every write of the room or of its members, a write of a
+	// room read at an older version fails.
+	Version int64 `json:"-" gorm:"not null;default:0"`
+	// Units are the ids of the members of the room, read from its members. They are
+	// changed by adding and removing members, not by writing the room.
+	Units []int64 `json:"-" gorm:"-"`
+}
+
+// RoomMember is the membership of a user in a room.
+type RoomMember struct {
+	RoomId   int64
+	UserId   int64
+	Role     RoleType
+	JoinedAt time.Time
 }
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type Sex int
 
 const InvalidUserId = -1
@@ -11,13 +13,37 @@ const (
 )
 
 type User struct {
-	UserId   int
+	UserId   int64
 	Nickname string
 	Sex      Sex
 	Age      int
 	Birthday int64
+	// AvatarUrl is an external image or the avatar uploaded by the user
+	AvatarUrl string
+	Bio       string
+	// Status is a short text shown next to the nickname
+	Status string
 }
 
 func (u User) IsValid() bool {
 	return u.UserId != InvalidUserId
 }
+
+// ProfileUpdate changes the profile of a user, nil fields are kept.
+type ProfileUpdate struct {
+	Nickname  *string
+	Sex       *Sex
+	Age       *int
+	Birthday  *int64
+	AvatarUrl *string
+	Bio       *string
+	Status    *string
+}
+
+// Avatar is an image uploaded by a user.
+type Avatar struct {
+	UserId      int64
+	ContentType string
+	Data        []byte
+	UpdatedAt   time.Time
+}